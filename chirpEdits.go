@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/spam"
+	"github.com/google/uuid"
+)
+
+// handlerEditChirp rewrites a chirp's body while the author's edit window is open. The new body
+// goes through the same length, moderation and spam checks as a new chirp.
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "you can only edit your own chirps", nil)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	ent, err := cfg.entitlements.For(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve entitlements", err)
+		return
+	}
+
+	now := time.Now()
+	if !ent.CanEdit(chirp.CreatedAt, now) {
+		if ent.EditWindow == 0 {
+			respondWithError(w, http.StatusForbidden, "editing chirps needs Chirpy Red", nil)
+			return
+		}
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("chirps can only be edited for %s after posting", ent.EditWindow), nil)
+		return
+	}
+
+	moderated, err := cfg.validateChirpBody(params.Body, chirpPolicy(ent))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	signals, decision, err := cfg.screenChirp(req.Context(), cfg.db, userID, moderated.Text)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+	if decision.Verdict == spam.VerdictReject {
+		err = recordSpamDecision(req.Context(), cfg.db, userID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, signals, decision)
+		if err != nil {
+			log.Println("could not record spam decision: ", err)
+		}
+		respondWithError(w, http.StatusBadRequest, errChirpSpam.Error(), errChirpSpam)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	edited, err := qtx.EditChirp(req.Context(), database.EditChirpParams{
+		Body:      moderated.Text,
+		UpdatedAt: now,
+		Hold:      decision.Verdict == spam.VerdictHold,
+		ID:        chirp.ID,
+		UserID:    userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+
+	if err := flagChirp(req.Context(), qtx, edited.ID, moderated); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+
+	err = recordSpamDecision(req.Context(), qtx, userID, uuid.NullUUID{UUID: edited.ID, Valid: true}, signals, decision)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not edit chirp", err)
+		return
+	}
+
+	chirps := []Chirp{chirpFromDB(edited)}
+	if err := cfg.attachPolls(req.Context(), chirps, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
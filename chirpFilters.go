@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/search"
+	"github.com/google/uuid"
+)
+
+const maxFilterAuthors = 50
+
+// parseChirpTimeline reads paging and sort for a chirp timeline, and with filters set every
+// GET /api/chirps filter too. Problems come back keyed by query param.
+func parseChirpTimeline(req *http.Request, filters bool) (database.ChirpFilter, page, map[string]string) {
+
+	query := req.URL.Query()
+	filter := database.ChirpFilter{}
+	fields := map[string]string{}
+
+	switch query.Get("sort") {
+	case "", "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		fields["sort"] = "must be asc or desc"
+	}
+
+	p := page{}
+	if _, err := parsePageSize(req); err != nil {
+		fields["limit"] = err.Error()
+	} else if p, err = parsePage(req); err != nil {
+		fields["cursor"] = err.Error()
+	}
+
+	if !filters {
+		return filter, p, fields
+	}
+
+	if authors := splitListParam(query["author_id"]); len(authors) > 0 {
+		if len(authors) > maxFilterAuthors {
+			fields["author_id"] = "at most 50 authors can be given"
+		}
+		filter.AuthorIDs = []uuid.UUID{}
+		for _, author := range authors {
+			authorID, err := uuid.Parse(author)
+			if err != nil {
+				fields["author_id"] = "must be valid user ids"
+				break
+			}
+			filter.AuthorIDs = append(filter.AuthorIDs, authorID)
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseFilterTime(query.Get("since")); err != nil {
+		fields["since"] = err.Error()
+	}
+	if filter.Until, err = parseFilterTime(query.Get("until")); err != nil {
+		fields["until"] = err.Error()
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Until.After(filter.Since) {
+		fields["until"] = "must be after since"
+	}
+
+	if filter.HasMedia, err = parseFilterBool(query.Get("has_media")); err != nil {
+		fields["has_media"] = err.Error()
+	}
+	if filter.IsReply, err = parseFilterBool(query.Get("is_reply")); err != nil {
+		fields["is_reply"] = err.Error()
+	}
+
+	if languages := splitListParam(query["language"]); len(languages) > 0 {
+		filter.Languages = []string{}
+		for _, language := range languages {
+			parsed, err := search.ParseLanguage(language)
+			if err != nil {
+				fields["language"] = err.Error()
+				break
+			}
+			filter.Languages = append(filter.Languages, parsed)
+		}
+	}
+
+	return filter, p, fields
+}
+
+// splitListParam accepts both ?a=1&a=2 and ?a=1,2
+func splitListParam(values []string) []string {
+	items := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseFilterTime reads since or until, a bare date is midnight UTC
+func parseFilterTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return dbTime(at), nil
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return dbTime(at), nil
+	}
+	return time.Time{}, errors.New("must be a date like 2006-01-02 or an RFC 3339 time")
+}
+
+func parseFilterBool(value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.New("must be true or false")
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilterTime(t *testing.T) {
+
+	cases := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-10-18", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-18T12:00:00Z", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{"2026-10-18T12:00:00+09:00", time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		got, err := parseFilterTime(c.value)
+		if err != nil {
+			t.Errorf("%s: %v", c.value, err)
+			continue
+		}
+		// the instant is kept, but as wall clock time in the server's zone like the created_at it filters
+		if !got.Equal(c.want) || got.Location() != time.Local {
+			t.Errorf("%s: expected %v in the server's zone, got %v", c.value, c.want, got)
+		}
+	}
+
+	if got, err := parseFilterTime(""); err != nil || !got.IsZero() {
+		t.Errorf("expected an empty value to mean no filter, got %v %v", got, err)
+	}
+	for _, value := range []string{"yesterday", "2026-13-01", "18/10/2026"} {
+		if _, err := parseFilterTime(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
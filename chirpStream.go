@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpStreamChannel = "chirp_stream"
+
+	// comments keep idle streams from being closed by proxies along the way
+	chirpStreamHeartbeat = 15 * time.Second
+	// a client that can't take a write this long is dropped, it can reconnect and resume
+	chirpStreamWriteTimeout = 10 * time.Second
+	// how long EventSource clients wait before reconnecting
+	chirpStreamRetry = 3 * time.Second
+	// how many missed events are read at a time when a client resumes
+	chirpStreamBacklogPage = 500
+	// missed events older than this can't be resumed
+	chirpStreamRetention = 24 * time.Hour
+	// how often the listener checks its connection is still alive
+	chirpStreamPingInterval = 90 * time.Second
+
+	jobPurgeChirpStream = "purge_chirp_stream"
+)
+
+func chirpStreamEventFromDB(event database.ChirpStreamEvent) stream.Event {
+	return stream.Event{
+		ID:       event.ID,
+		Type:     event.EventType,
+		AuthorID: event.AuthorID,
+		Unlisted: event.Unlisted,
+		Data:     event.Payload,
+	}
+}
+
+// recordChirpStreamEvent adds the change to the stream when the transaction commits, created
+// events carry the chirp and deleted ones its id and author
+func recordChirpStreamEvent(ctx context.Context, qtx *database.Queries, eventType string, chirp database.Chirp) error {
+
+	var data any = chirpFromDB(chirp)
+	if eventType == stream.TypeChirpDeleted {
+		data = struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{
+			ID:     chirp.ID,
+			UserID: chirp.UserID,
+		}
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return qtx.RecordChirpStreamEvent(ctx, database.RecordChirpStreamEventParams{
+		CreatedAt:  time.Now(),
+		EventType:  eventType,
+		ChirpID:    chirp.ID,
+		Visibility: chirp.Visibility,
+		Payload:    payload,
+		AuthorID:   chirp.UserID,
+	})
+}
+
+// runChirpStreamListener publishes every instance's chirp stream events to this instance's
+// clients until ctx is cancelled
+func (cfg *apiConfig) runChirpStreamListener(ctx context.Context, dbURL string) {
+
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("chirp stream listener: ", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(chirpStreamChannel); err != nil {
+		log.Println("could not listen for chirp stream events: ", err)
+		return
+	}
+
+	lastID, err := cfg.db.GetLatestChirpStreamEventID(ctx)
+	if err != nil {
+		log.Println("could not read the chirp stream position: ", err)
+	}
+
+	ticker := time.NewTicker(chirpStreamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go listener.Ping()
+		case notification := <-listener.Notify:
+			// a nil notification means the connection was re-established, anything sent
+			// while it was down is read from the table instead
+			if notification == nil {
+				lastID = cfg.catchUpChirpStream(ctx, lastID)
+				continue
+			}
+
+			id, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				log.Println("bad chirp stream notification: ", notification.Extra)
+				continue
+			}
+			event, err := cfg.db.GetChirpStreamEvent(ctx, id)
+			if err != nil {
+				log.Println("could not read chirp stream event ", id, ": ", err)
+				continue
+			}
+			cfg.chirpStream.Publish(chirpStreamEventFromDB(event))
+			lastID = max(lastID, id)
+		}
+	}
+}
+
+// catchUpChirpStream publishes the events after lastID and returns the last one published
+func (cfg *apiConfig) catchUpChirpStream(ctx context.Context, lastID int64) int64 {
+	for {
+		events, err := cfg.db.GetChirpStreamEventsAfter(ctx, database.GetChirpStreamEventsAfterParams{
+			AfterID:   lastID,
+			MaxEvents: chirpStreamBacklogPage,
+		})
+		if err != nil {
+			log.Println("could not catch up on chirp stream events: ", err)
+			return lastID
+		}
+		for _, event := range events {
+			cfg.chirpStream.Publish(chirpStreamEventFromDB(event))
+			lastID = event.ID
+		}
+		if len(events) < chirpStreamBacklogPage {
+			return lastID
+		}
+	}
+}
+
+// purgeChirpStream is the purge_chirp_stream job, it drops events too old to resume from
+func (cfg *apiConfig) purgeChirpStream(ctx context.Context, _ struct{}) error {
+	_, err := cfg.db.PurgeChirpStreamEvents(ctx, time.Now().Add(-chirpStreamRetention))
+	return err
+}
+
+// handlerStreamChirps pushes chirp.created and chirp.deleted events as server-sent events,
+// optionally only those by author_id. A client that sends Last-Event-ID (or last_event_id)
+// first gets the events it missed.
+func (cfg *apiConfig) handlerStreamChirps(w http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	filter := stream.Filter{}
+	if raw := query.Get("author_id"); raw != "" {
+		authorID, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author_id", err)
+			return
+		}
+		filter.AuthorID = authorID
+	}
+
+	lastID := int64(0)
+	resume := req.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = query.Get("last_event_id")
+	}
+	if resume != "" {
+		id, err := strconv.ParseInt(resume, 10, 64)
+		if err != nil || id < 0 {
+			respondWithError(w, http.StatusBadRequest, "invalid Last-Event-ID", err)
+			return
+		}
+		lastID = id
+	}
+
+	// subscribe before reading the backlog so nothing slips in between
+	sub, err := cfg.chirpStream.Subscribe(filter)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "server is shutting down", err)
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	send := func(write func() error) error {
+		// the deadline is per write, so a long lived stream isn't cut off by the server's write timeout
+		if err := rc.SetWriteDeadline(time.Now().Add(chirpStreamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err = send(func() error {
+		_, err := w.Write([]byte("retry: " + strconv.FormatInt(chirpStreamRetry.Milliseconds(), 10) + "\n\n"))
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	for resume != "" {
+		events, err := cfg.db.GetChirpStreamEventsAfter(req.Context(), database.GetChirpStreamEventsAfterParams{
+			AfterID:   lastID,
+			MaxEvents: chirpStreamBacklogPage,
+		})
+		if err != nil {
+			log.Println("could not read missed chirp stream events: ", err)
+			return
+		}
+		for _, row := range events {
+			lastID = row.ID
+			event := chirpStreamEventFromDB(row)
+			if !filter.Matches(event) {
+				continue
+			}
+			if err := send(func() error { return stream.Write(w, event) }); err != nil {
+				return
+			}
+		}
+		if len(events) < chirpStreamBacklogPage {
+			break
+		}
+	}
+
+	heartbeat := time.NewTicker(chirpStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := send(func() error { return stream.WriteComment(w, "heartbeat") }); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			// dropped for falling behind or because the server is shutting down, either way
+			// the client reconnects and resumes from the last event it got
+			if !ok {
+				return
+			}
+			// already sent from the backlog
+			if event.ID <= lastID {
+				continue
+			}
+			if err := send(func() error { return stream.Write(w, event) }); err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+	}
+}
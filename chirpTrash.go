@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	// how long a deleted chirp stays in its author's trash before it is purged for good
+	chirpTrashRetention = 30 * 24 * time.Hour
+	chirpPurgeInterval  = time.Hour
+)
+
+type TrashedChirp struct {
+	Chirp
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+func trashedChirpFromDB(chirp database.Chirp) TrashedChirp {
+	return TrashedChirp{
+		Chirp:     chirpFromDB(chirp),
+		DeletedAt: chirp.DeletedAt.Time,
+		PurgeAt:   chirp.DeletedAt.Time.Add(chirpTrashRetention),
+	}
+}
+
+func (cfg *apiConfig) handlerGetChirpTrash(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	trashParams := database.GetTrashedChirpsByAuthorParams{
+		UserID:       userID,
+		DeletedAfter: time.Now().Add(-chirpTrashRetention),
+		CursorTime:   p.cursor,
+		Backward:     p.backward,
+		CursorID:     p.cursorID,
+		PageSize:     p.size,
+	}
+
+	rows, err := cfg.db.GetTrashedChirpsByAuthor(req.Context(), trashParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve trash", err)
+		return
+	}
+	rows = reversePage(p, rows)
+
+	chirps := []TrashedChirp{}
+	for _, row := range rows {
+		chirps = append(chirps, trashedChirpFromDB(row))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(rows), func(i int) string {
+		return encodeCursor(rows[i].DeletedAt.Time, rows[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Chirps     []TrashedChirp `json:"chirps"`
+		NextCursor string         `json:"next_cursor,omitempty"`
+		PrevCursor string         `json:"prev_cursor,omitempty"`
+	}{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract chirp id from url", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not restore chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	restored, err := qtx.RestoreChirp(req.Context(), database.RestoreChirpParams{
+		UpdatedAt:    time.Now(),
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: time.Now().Add(-chirpTrashRetention),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "no chirp with that id in your trash", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not restore chirp", err)
+		return
+	}
+
+	// deleting it announced chirp.deleted, so coming back is announced as chirp.created.
+	// A held chirp was never announced either way.
+	if !restored.HeldForReview {
+		if err := enqueueChirpWebhook(req.Context(), qtx, outbound.EventChirpCreated, restored); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not restore chirp", err)
+			return
+		}
+		if err := recordChirpStreamEvent(req.Context(), qtx, stream.TypeChirpCreated, restored); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not restore chirp", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not restore chirp", err)
+		return
+	}
+
+	// replies and mentions were notified the first time, only timelines hear about it again
+	if !restored.HeldForReview {
+		cfg.publishTimelineChirp(context.WithoutCancel(req.Context()), restored)
+	}
+
+	chirps := []Chirp{chirpFromDB(restored)}
+	err = cfg.attachPolls(req.Context(), chirps, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve polls", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+// purgeTrashedChirps is the purge_trashed_chirps job, it permanently deletes chirps that have
+// been in the trash longer than chirpTrashRetention
+func (cfg *apiConfig) purgeTrashedChirps(ctx context.Context, _ struct{}) error {
+
+	purged, err := cfg.db.PurgeDeletedChirps(ctx, time.Now().Add(-chirpTrashRetention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("purged %d chirps from trash", purged)
+	}
+	return nil
+}
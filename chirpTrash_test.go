@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/google/uuid"
+)
+
+func TestDeleteChirpMovesItToTrash(t *testing.T) {
+
+	author := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "oops", UserID: author, Visibility: "public"}
+
+	cases := []struct {
+		name   string
+		user   uuid.UUID
+		status int
+	}{
+		{"author", author, http.StatusNoContent},
+		{"someone else", uuid.New(), http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.returns("GetOneChirp", row(chirp))
+
+			req := authorizedRequest(t, c.user, http.MethodDelete, "/api/chirps/"+chirp.ID.String(), "")
+			req.SetPathValue("chirpID", chirp.ID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerDeleteChirp(w, req)
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			if calls := fake.calledWith("DeleteChirp"); len(calls) != 0 {
+				t.Errorf("expected the chirp not to be deleted for good, got %v", calls)
+			}
+			trashed := fake.calledWith("SoftDeleteChirp")
+			if c.status != http.StatusNoContent {
+				if len(trashed) != 0 {
+					t.Errorf("expected the chirp left alone, got %v", trashed)
+				}
+				return
+			}
+			if len(trashed) != 1 || trashed[0][2] != chirp.ID.String() || trashed[0][1] != false {
+				t.Errorf("expected the chirp moved to the trash, got %v", trashed)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+
+		req := authorizedRequest(t, author, http.MethodDelete, "/api/chirps/"+chirp.ID.String(), "")
+		req.SetPathValue("chirpID", chirp.ID.String())
+		w := httptest.NewRecorder()
+		cfg.handlerDeleteChirp(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d %s", w.Code, w.Body)
+		}
+		if calls := fake.calledWith("SoftDeleteChirp"); len(calls) != 0 {
+			t.Errorf("expected nothing deleted, got %v", calls)
+		}
+	})
+}
+
+func TestGetChirpTrash(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	author := uuid.New()
+	deletedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	chirp := database.Chirp{
+		ID:         uuid.New(),
+		CreatedAt:  deletedAt.Add(-time.Hour),
+		UpdatedAt:  deletedAt.Add(-time.Hour),
+		Body:       "gone for now",
+		UserID:     author,
+		Visibility: "public",
+		DeletedAt:  sql.NullTime{Time: deletedAt, Valid: true},
+	}
+	fake.returns("GetTrashedChirpsByAuthor", row(chirp))
+
+	before := time.Now()
+	w := httptest.NewRecorder()
+	cfg.handlerGetChirpTrash(w, authorizedRequest(t, author, http.MethodGet, "/api/chirps/trash?limit=1", ""))
+	after := time.Now()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+	got := struct {
+		Chirps     []TrashedChirp `json:"chirps"`
+		NextCursor string         `json:"next_cursor"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Chirps) != 1 || got.Chirps[0].ID != chirp.ID || !got.Chirps[0].DeletedAt.Equal(deletedAt) {
+		t.Fatalf("expected the trashed chirp, got %+v", got.Chirps)
+	}
+	if want := deletedAt.Add(chirpTrashRetention); !got.Chirps[0].PurgeAt.Equal(want) {
+		t.Errorf("expected purge_at %v, got %v", want, got.Chirps[0].PurgeAt)
+	}
+	if want := encodeCursor(deletedAt, chirp.ID); got.NextCursor != want {
+		t.Errorf("expected a cursor at the deletion %q, got %q", want, got.NextCursor)
+	}
+
+	calls := fake.calledWith("GetTrashedChirpsByAuthor")
+	if len(calls) != 1 || calls[0][0] != author.String() {
+		t.Fatalf("expected the author's trash, got %v", calls)
+	}
+	cutoff, ok := calls[0][1].(time.Time)
+	if !ok || cutoff.Before(before.Add(-chirpTrashRetention)) || cutoff.After(after.Add(-chirpTrashRetention)) {
+		t.Errorf("expected chirps past retention left out, got a cutoff of %v", calls[0][1])
+	}
+}
+
+func TestTrashedChirpFromDB(t *testing.T) {
+
+	deletedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		Body:      "gone for now",
+		DeletedAt: sql.NullTime{Time: deletedAt, Valid: true},
+	}
+
+	trashed := trashedChirpFromDB(chirp)
+	if trashed.ID != chirp.ID || trashed.Body != chirp.Body {
+		t.Errorf("expected the chirp itself, got %+v", trashed.Chirp)
+	}
+	if !trashed.DeletedAt.Equal(deletedAt) {
+		t.Errorf("expected deleted_at %v, got %v", deletedAt, trashed.DeletedAt)
+	}
+	if want := deletedAt.AddDate(0, 0, 30); !trashed.PurgeAt.Equal(want) {
+		t.Errorf("expected purge_at 30 days after deletion, %v, got %v", want, trashed.PurgeAt)
+	}
+}
+
+func TestRestoreChirp(t *testing.T) {
+
+	author := uuid.New()
+
+	cases := []struct {
+		name     string
+		inTrash  bool
+		held     bool
+		status   int
+		announce bool
+	}{
+		{"restored", true, false, http.StatusOK, true},
+		{"held for review", true, true, http.StatusOK, false},
+		{"not in the trash", false, false, http.StatusNotFound, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			sub, err := cfg.realtime.Subscribe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+			sub.Join(realtime.ChannelChirps)
+
+			chirpID := uuid.New()
+			if c.inTrash {
+				fake.answer("RestoreChirp", func(args []driver.Value) fakeResult {
+					return fakeResult{rows: [][]driver.Value{row(database.Chirp{
+						ID:            chirpID,
+						CreatedAt:     time.Now(),
+						UpdatedAt:     args[0].(time.Time),
+						Body:          "back again",
+						UserID:        author,
+						Visibility:    "public",
+						HeldForReview: c.held,
+					})}}
+				})
+			}
+
+			req := authorizedRequest(t, author, http.MethodPost, "/api/chirps/"+chirpID.String()+"/restore", "")
+			req.SetPathValue("chirpID", chirpID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerRestoreChirp(w, req)
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+
+			restored := fake.calledWith("RestoreChirp")
+			if len(restored) != 1 || restored[0][1] != chirpID.String() || restored[0][2] != author.String() {
+				t.Errorf("expected the chirp restored from the author's trash, got %v", restored)
+			}
+
+			// deleting it announced chirp.deleted, so it comes back as a new chirp
+			webhooks := fake.calledWith("EnqueueWebhookDeliveries")
+			streamed := fake.calledWith("RecordChirpStreamEvent")
+			if !c.announce {
+				if len(webhooks) != 0 || len(streamed) != 0 {
+					t.Errorf("expected no events, got webhooks %v stream %v", webhooks, streamed)
+				}
+			} else {
+				if len(webhooks) != 1 || !containsValue(webhooks[0], outbound.EventChirpCreated) {
+					t.Errorf("expected a %s webhook, got %v", outbound.EventChirpCreated, webhooks)
+				}
+				if len(streamed) != 1 || !containsValue(streamed[0], stream.TypeChirpCreated) {
+					t.Errorf("expected a %s stream event, got %v", stream.TypeChirpCreated, streamed)
+				}
+			}
+			select {
+			case msg := <-sub.Messages():
+				if !c.announce {
+					t.Errorf("expected nothing on the timeline, got %v", msg)
+				} else if msg.Type != realtime.TypeChirpCreated {
+					t.Errorf("expected %s on the timeline, got %s", realtime.TypeChirpCreated, msg.Type)
+				}
+			default:
+				if c.announce {
+					t.Error("expected the chirp back on the timeline")
+				}
+			}
+
+			if c.status != http.StatusOK {
+				return
+			}
+			got := Chirp{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != chirpID || got.Body != "back again" {
+				t.Errorf("expected the restored chirp, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestPurgeTrashedChirps(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+
+	before := time.Now()
+	if err := cfg.purgeTrashedChirps(context.Background(), struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	purged := fake.calledWith("PurgeDeletedChirps")
+	if len(purged) != 1 {
+		t.Fatalf("expected one purge, got %v", purged)
+	}
+	cutoff, ok := purged[0][0].(time.Time)
+	if !ok || cutoff.Before(before.Add(-chirpTrashRetention)) || cutoff.After(after.Add(-chirpTrashRetention)) {
+		t.Errorf("expected chirps deleted more than %v ago to be purged, got a cutoff of %v", chirpTrashRetention, purged[0][0])
+	}
+}
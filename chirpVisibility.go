@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+)
+
+// filterVisibleChirps drops the chirps viewerID is not allowed to see on surface, keeping their order.
+// Chirps held for review or by shadow banned authors are only ever shown to the author. viewerID is uuid.Nil for
+// anonymous requests.
+func (cfg *apiConfig) filterVisibleChirps(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp, surface visibility.Surface) ([]database.Chirp, error) {
+
+	if len(chirps) == 0 {
+		return chirps, nil
+	}
+
+	authorIDs := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for _, chirp := range chirps {
+		if !seen[chirp.UserID] {
+			seen[chirp.UserID] = true
+			authorIDs = append(authorIDs, chirp.UserID)
+		}
+	}
+
+	protectedIDs, err := cfg.db.GetProtectedAmong(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	protected := map[uuid.UUID]bool{}
+	for _, id := range protectedIDs {
+		protected[id] = true
+	}
+
+	shadowBannedIDs, err := cfg.db.GetShadowBannedAmong(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+	shadowBanned := map[uuid.UUID]bool{}
+	for _, id := range shadowBannedIDs {
+		shadowBanned[id] = true
+	}
+
+	followed := map[uuid.UUID]bool{}
+	if viewerID != uuid.Nil {
+		followedIDs, err := cfg.db.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+			FollowerID:  viewerID,
+			FolloweeIds: authorIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range followedIDs {
+			followed[id] = true
+		}
+	}
+
+	visible := []database.Chirp{}
+	for _, chirp := range chirps {
+		isAuthor := viewerID != uuid.Nil && viewerID == chirp.UserID
+		if (shadowBanned[chirp.UserID] || chirp.HeldForReview) && !isAuthor {
+			continue
+		}
+		canView := visibility.CanView(
+			visibility.Chirp{
+				Level:           visibility.Level(chirp.Visibility),
+				AuthorProtected: protected[chirp.UserID],
+			},
+			visibility.Viewer{
+				IsAuthor:      isAuthor,
+				FollowsAuthor: followed[chirp.UserID],
+			},
+			surface,
+		)
+		if canView {
+			visible = append(visible, chirp)
+		}
+	}
+
+	return visible, nil
+}
+
+// canViewChirp is filterVisibleChirps for a single chirp fetched by id
+func (cfg *apiConfig) canViewChirp(ctx context.Context, viewerID uuid.UUID, chirp database.Chirp) (bool, error) {
+	visible, err := cfg.filterVisibleChirps(ctx, viewerID, []database.Chirp{chirp}, visibility.SurfaceDirect)
+	if err != nil {
+		return false, err
+	}
+	return len(visible) == 1, nil
+}
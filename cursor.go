@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// encodeCursor packs a keyset position into an opaque string for clients
+func encodeCursor(at time.Time, id uuid.UUID) string {
+	raw := at.Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	return at, id, nil
+}
+
+// parsePageSize reads the limit query param, falling back to defaultPageSize
+func parsePageSize(req *http.Request) (int32, error) {
+	limitString := req.URL.Query().Get("limit")
+	if limitString == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	return int32(limit), nil
+}
+
+// page is a keyset page request. Clients walk forwards with after (or the older cursor
+// param) and backwards with before, both take a cursor from a previous response.
+type page struct {
+	size     int32
+	cursor   sql.NullTime
+	cursorID uuid.NullUUID
+	// backward means cursor came from before. List queries take it as a flag that flips
+	// their cursor comparison and ORDER BY, and reversePage restores listing order.
+	backward bool
+}
+
+func parsePage(req *http.Request) (page, error) {
+
+	size, err := parsePageSize(req)
+	if err != nil {
+		return page{}, err
+	}
+	p := page{size: size}
+
+	query := req.URL.Query()
+	after := query.Get("after")
+	if after == "" {
+		after = query.Get("cursor")
+	}
+	before := query.Get("before")
+
+	cursor := after
+	switch {
+	case after != "" && before != "":
+		return page{}, errors.New("only one of after and before can be used")
+	case before != "":
+		cursor = before
+		p.backward = true
+	}
+
+	if cursor == "" {
+		return p, nil
+	}
+
+	cursorTime, cursorID, err := decodeCursor(cursor)
+	if err != nil {
+		return page{}, errors.New("invalid cursor")
+	}
+	p.cursor = sql.NullTime{Time: cursorTime, Valid: true}
+	p.cursorID = uuid.NullUUID{UUID: cursorID, Valid: true}
+
+	return p, nil
+}
+
+// reversePage puts rows fetched for a backward page back into listing order
+func reversePage[T any](p page, rows []T) []T {
+	if p.backward {
+		slices.Reverse(rows)
+	}
+	return rows
+}
+
+// cursors works out the cursors either side of a page of count rows in listing order,
+// cursorAt encodes the cursor of row i. An empty string means there is nothing more in
+// that direction, a full page is assumed to have more after it.
+func (p page) cursors(count int, cursorAt func(i int) string) (next, prev string) {
+
+	if count == 0 {
+		return "", ""
+	}
+
+	full := count == int(p.size)
+	if p.backward {
+		next = cursorAt(count - 1)
+		if full {
+			prev = cursorAt(0)
+		}
+		return next, prev
+	}
+
+	if full {
+		next = cursorAt(count - 1)
+	}
+	if p.cursor.Valid {
+		prev = cursorAt(0)
+	}
+	return next, prev
+}
+
+// setPageLinks advertises the neighbouring pages in an RFC 8288 Link header
+func setPageLinks(w http.ResponseWriter, req *http.Request, next, prev string) {
+
+	link := func(param, cursor, rel string) string {
+		query := req.URL.Query()
+		query.Del("cursor")
+		query.Del("after")
+		query.Del("before")
+		query.Set(param, cursor)
+		return "<" + req.URL.Path + "?" + query.Encode() + `>; rel="` + rel + `"`
+	}
+
+	if next != "" {
+		w.Header().Add("Link", link("after", next, "next"))
+	}
+	if prev != "" {
+		w.Header().Add("Link", link("before", prev, "prev"))
+	}
+}
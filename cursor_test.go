@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+
+	id := uuid.New()
+	cases := []time.Time{
+		time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 18, 12, 0, 0, 123456789, time.UTC),
+		time.Date(2026, 10, 18, 8, 0, 0, 0, time.FixedZone("EDT", -4*60*60)),
+	}
+
+	for _, at := range cases {
+		cursor := encodeCursor(at, id)
+		if _, err := url.ParseQuery("after=" + cursor); err != nil || url.QueryEscape(cursor) != cursor {
+			t.Errorf("expected %q to be safe in a url", cursor)
+		}
+		gotAt, gotID, err := decodeCursor(cursor)
+		if err != nil || !gotAt.Equal(at) || gotID != id {
+			t.Errorf("expected %v %s back, got %v %s %v", at, id, gotAt, gotID, err)
+		}
+	}
+}
+
+func TestDecodeMalformedCursor(t *testing.T) {
+
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	id := uuid.New().String()
+
+	cases := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("2026-10-18T12:00:00.5Z|" + id))},
+		{"no separator", encode("2026-10-18T12:00:00Z")},
+		{"too many parts", encode("2026-10-18T12:00:00Z|" + id + "|extra")},
+		{"bad time", encode("yesterday|" + id)},
+		{"bad id", encode("2026-10-18T12:00:00Z|not-a-uuid")},
+	}
+
+	for _, c := range cases {
+		if _, _, err := decodeCursor(c.cursor); err == nil || err.Error() != "malformed cursor" {
+			t.Errorf("%s: expected a malformed cursor, got %v", c.name, err)
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	cursor := encodeCursor(at, id)
+
+	cases := []struct {
+		name     string
+		query    string
+		size     int32
+		cursor   bool
+		backward bool
+		err      string
+	}{
+		{name: "first page", query: "", size: defaultPageSize},
+		{name: "limit", query: "limit=5", size: 5},
+		{name: "limit capped", query: "limit=1000", size: maxPageSize},
+		{name: "zero limit", query: "limit=0", err: "limit must be a positive integer"},
+		{name: "bad limit", query: "limit=ten", err: "limit must be a positive integer"},
+		{name: "after", query: "after=" + cursor, size: defaultPageSize, cursor: true},
+		{name: "old cursor param", query: "cursor=" + cursor, size: defaultPageSize, cursor: true},
+		{name: "before", query: "before=" + cursor + "&limit=3", size: 3, cursor: true, backward: true},
+		{name: "after and before", query: "after=" + cursor + "&before=" + cursor, err: "only one of after and before can be used"},
+		{name: "cursor and before", query: "cursor=" + cursor + "&before=" + cursor, err: "only one of after and before can be used"},
+		{name: "malformed after", query: "after=nope", err: "invalid cursor"},
+		{name: "malformed before", query: "before=nope", err: "invalid cursor"},
+	}
+
+	for _, c := range cases {
+		p, err := parsePage(httptest.NewRequest("GET", "/api/chirps?"+c.query, nil))
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: expected %q, got %v", c.name, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if p.size != c.size || p.backward != c.backward || p.cursor.Valid != c.cursor || p.cursorID.Valid != c.cursor {
+			t.Errorf("%s: got %+v", c.name, p)
+		}
+		if c.cursor && (!p.cursor.Time.Equal(at) || p.cursorID.UUID != id) {
+			t.Errorf("%s: expected the cursor at %v %s, got %v %s", c.name, at, id, p.cursor.Time, p.cursorID.UUID)
+		}
+	}
+}
+
+func TestReversePage(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		backward bool
+		want     []int
+	}{
+		{"forward", false, []int{1, 2, 3}},
+		{"backward", true, []int{3, 2, 1}},
+	}
+
+	for _, c := range cases {
+		if got := reversePage(page{backward: c.backward}, []int{1, 2, 3}); !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestPageCursors(t *testing.T) {
+
+	first := page{size: 3}
+	after := page{size: 3}
+	after.cursor.Valid = true
+	before := after
+	before.backward = true
+
+	cases := []struct {
+		name  string
+		p     page
+		count int
+		next  string
+		prev  string
+	}{
+		{"empty", after, 0, "", ""},
+		{"first page, full", first, 3, "row2", ""},
+		{"first page, last", first, 2, "", ""},
+		{"after, full", after, 3, "row2", "row0"},
+		{"after, last", after, 1, "", "row0"},
+		{"before, full", before, 3, "row2", "row0"},
+		{"before, first", before, 2, "row1", ""},
+	}
+
+	for _, c := range cases {
+		next, prev := c.p.cursors(c.count, func(i int) string {
+			return "row" + strconv.Itoa(i)
+		})
+		if next != c.next || prev != c.prev {
+			t.Errorf("%s: expected next %q prev %q, got %q %q", c.name, c.next, c.prev, next, prev)
+		}
+	}
+}
+
+func TestSetPageLinks(t *testing.T) {
+
+	cases := []struct {
+		name   string
+		target string
+		next   string
+		prev   string
+		want   []string
+	}{
+		{name: "no more pages", target: "/api/chirps", want: nil},
+		{name: "next", target: "/api/chirps?limit=5", next: "abc", want: []string{
+			`</api/chirps?after=abc&limit=5>; rel="next"`,
+		}},
+		{name: "both", target: "/api/chirps?author_id=x&before=old", next: "abc", prev: "def", want: []string{
+			`</api/chirps?after=abc&author_id=x>; rel="next"`,
+			`</api/chirps?author_id=x&before=def>; rel="prev"`,
+		}},
+		{name: "old cursor param dropped", target: "/api/chirps?cursor=old", prev: "def", want: []string{
+			`</api/chirps?before=def>; rel="prev"`,
+		}},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		setPageLinks(w, httptest.NewRequest("GET", c.target, nil), c.next, c.prev)
+		if got := w.Header().Values("Link"); !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/entitlements"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/moderation"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/colfarl/chirpy-server/internal/spam"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const testSecret = "test-secret"
+
+// fakeDB is a database/sql driver for handler tests. It answers sqlc queries by their name
+// and records every call, so a test can script what the database says and check what was
+// written without a Postgres server.
+type fakeDB struct {
+	mu      sync.Mutex
+	answers map[string]func(args []driver.Value) fakeResult
+	calls   []fakeCall
+}
+
+// fakeResult is what a query returns, rows for :one and :many queries and the number of
+// rows for :execrows
+type fakeResult struct {
+	rows [][]driver.Value
+	err  error
+}
+
+type fakeCall struct {
+	name string
+	args []driver.Value
+}
+
+var (
+	fakeDBs     sync.Map
+	fakeDBCount atomic.Int64
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// newFakeDB returns the fake and an *sql.DB that talks to it, a query nothing answers
+// returns no rows
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	fake := &fakeDB{answers: map[string]func(args []driver.Value) fakeResult{}}
+	dsn := strconv.FormatInt(fakeDBCount.Add(1), 10)
+	fakeDBs.Store(dsn, fake)
+
+	db, err := sql.Open("fakedb", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBs.Delete(dsn)
+	})
+	return fake, db
+}
+
+// answer has the query called name return what respond makes of its arguments
+func (f *fakeDB) answer(name string, respond func(args []driver.Value) fakeResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers[name] = respond
+}
+
+// returns has the query called name always return rows
+func (f *fakeDB) returns(name string, rows ...[]driver.Value) {
+	f.answer(name, func(args []driver.Value) fakeResult {
+		return fakeResult{rows: rows}
+	})
+}
+
+// calledWith returns the arguments of every call to the query called name, in order
+func (f *fakeDB) calledWith(name string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	args := [][]driver.Value{}
+	for _, call := range f.calls {
+		if call.name == name {
+			args = append(args, call.args)
+		}
+	}
+	return args
+}
+
+func (f *fakeDB) run(name string, args []driver.Value) fakeResult {
+	f.mu.Lock()
+	f.calls = append(f.calls, fakeCall{name: name, args: args})
+	respond := f.answers[name]
+	f.mu.Unlock()
+
+	if respond == nil {
+		return fakeResult{}
+	}
+	return respond(args)
+}
+
+// row lays values out as one result row the way sqlc selects them, a struct from the
+// database package becomes its fields in order
+func row(values ...any) []driver.Value {
+	out := []driver.Value{}
+	for _, value := range values {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Struct && v.Type().PkgPath() == reflect.TypeOf(database.User{}).PkgPath() {
+			for i := range v.NumField() {
+				out = append(out, row(v.Field(i).Interface())...)
+			}
+			continue
+		}
+		out = append(out, driverValue(value))
+	}
+	return out
+}
+
+func driverValue(value any) driver.Value {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case driver.Valuer:
+		converted, err := v.Value()
+		if err != nil {
+			panic(err)
+		}
+		return converted
+	case []string:
+		converted, _ := pq.Array(v).Value()
+		return converted
+	case []uuid.UUID:
+		ids := []string{}
+		for _, id := range v {
+			ids = append(ids, id.String())
+		}
+		converted, _ := pq.Array(ids).Value()
+		return converted
+	case time.Time, []byte:
+		return v
+	case json.RawMessage:
+		return []byte(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	panic(fmt.Sprintf("fakedb cannot return a %T", value))
+}
+
+// usersByID answers GetUserByID from users
+func usersByID(users ...database.User) func(args []driver.Value) fakeResult {
+	return usersByIDAt(0, users...)
+}
+
+// usersByIDAt answers a query that returns one of users, picked by the argument at position
+func usersByIDAt(position int, users ...database.User) func(args []driver.Value) fakeResult {
+	return func(args []driver.Value) fakeResult {
+		for _, user := range users {
+			if args[position] == user.ID.String() {
+				return fakeResult{rows: [][]driver.Value{row(user)}}
+			}
+		}
+		return fakeResult{}
+	}
+}
+
+func testUser() database.User {
+	id := uuid.New()
+	return database.User{
+		ID:        id,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Email:     id.String() + "@example.com",
+	}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	fake, _ := fakeDBs.Load(dsn)
+	return fakeConn{db: fake.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, name: queryName(query)}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	db   *fakeDB
+	name string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result := s.db.run(s.name, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(len(result.rows)), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	result := s.db.run(s.name, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{rows: result.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+// Columns only has to be as long as a row, sqlc scans by position
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	columns := make([]string, len(r.rows[0]))
+	for i := range columns {
+		columns[i] = "column" + strconv.Itoa(i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	if len(r.rows[0]) != len(dest) {
+		return fmt.Errorf("fakedb row has %d columns, the query scans %d", len(r.rows[0]), len(dest))
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// queryName reads the name sqlc puts at the top of every query, the queries ChirpFilter
+// builds have none and go by the method that runs them
+func queryName(query string) string {
+	first, _, _ := strings.Cut(query, "\n")
+	fields := strings.Fields(first)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	if strings.Contains(query, "\nFROM chirps\nWHERE ") {
+		return "FilterChirps"
+	}
+	return query
+}
+
+// authorizedRequest builds a request to target carrying an access token for userID
+func authorizedRequest(t *testing.T, userID uuid.UUID, method, target, body string) *http.Request {
+	token, err := auth.MakeJWT(userID, testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+// newTestConfig returns an apiConfig wired to a fresh fake database the way main wires
+// the real one
+func newTestConfig(t *testing.T) (*fakeDB, *apiConfig) {
+	fake, db := newFakeDB(t)
+	cfg := &apiConfig{
+		db:     database.New(db),
+		dbConn: db,
+		secret: testSecret,
+		events: events.NewBus(),
+	}
+	cfg.moderation = moderation.NewCache(cfg.loadModerationRules)
+	cfg.spam = spam.NewPipeline(spam.DefaultHoldScore, spam.DefaultRejectScore)
+	cfg.entitlements = entitlements.NewEngine(entitlements.DefaultPlans, subscriptionTiers{db: cfg.db})
+	// in process only, there is no Postgres to NOTIFY through
+	cfg.realtime = realtime.NewLocal()
+	cfg.subscribeNotifications(cfg.events)
+
+	// nobody is suspended unless a test says so
+	fake.answer("GetUserSuspension", suspensionsOf(nil))
+	// and nobody has blocked anybody
+	fake.returns("IsBlockedEitherWay", row(false))
+	return fake, cfg
+}
+
+// suspensionsOf answers GetUserSuspension, users not in suspended aren't suspended
+func suspensionsOf(suspended map[uuid.UUID]database.GetUserSuspensionRow) func(args []driver.Value) fakeResult {
+	return func(args []driver.Value) fakeResult {
+		suspension := suspended[uuid.MustParse(args[0].(string))]
+		return fakeResult{rows: [][]driver.Value{row(suspension)}}
+	}
+}
+
+// echoArgs answers an insert that returns the row it was given, column for argument
+func echoArgs(args []driver.Value) fakeResult {
+	return fakeResult{rows: [][]driver.Value{args}}
+}
+
+// createdChirp answers CreateChirp with the chirp it was asked to insert, which is not
+// deleted yet. deleted_at sits between visibility and the columns added after it.
+func createdChirp(args []driver.Value) fakeResult {
+	created := append(append(append(args[:7:7], nil), args[7:]...), false)
+	return fakeResult{rows: [][]driver.Value{created}}
+}
@@ -86,6 +86,23 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// JWTExpiresAt validates the token like ValidateJWT and returns when it stops being valid,
+// long lived connections use it to ask for a fresh token in time
+func JWTExpiresAt(tokenString, tokenSecret string) (time.Time, error) {
+
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keyFunc([]byte(tokenSecret)))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if claims.ExpiresAt == nil {
+		return time.Time{}, errors.New("token has no expiry")
+	}
+
+	return claims.ExpiresAt.Time, nil
+}
+
 func GetAPIKey(header http.Header) (string, error) {
 	str := header.Get("Authorization")
 	inputs := strings.Fields(str)
@@ -83,3 +83,23 @@ func TestMakeValidateJWT(t * testing.T) {
 		t.Errorf(`"%v" does not equal "%v" `, got, id)
 	}
 }
+
+func TestJWTExpiresAt(t *testing.T) {
+
+	tok, err := MakeJWT(uuid.New(), "super-secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt, err := JWTExpiresAt(tok, "super-secret")
+	if err != nil {
+		t.Errorf(`"%v" could not be validated`, tok)
+	}
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Errorf("expected the token to expire in an hour, got %v", until)
+	}
+
+	if _, err := JWTExpiresAt(tok, "diff-secret"); err == nil {
+		t.Errorf(`"%v" should not have been validated`, tok)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader holds one or more comma separated "v1=<hex>" signatures,
+	// a sender rotating secrets signs with each of them
+	WebhookSignatureHeader = "X-Polka-Signature"
+	// WebhookTimestampHeader is the unix time the sender signed the request at
+	WebhookTimestampHeader = "X-Polka-Timestamp"
+
+	// DefaultWebhookTolerance is how far a webhook's timestamp may be from now, a captured
+	// request can't be replayed once it has passed
+	DefaultWebhookTolerance = 5 * time.Minute
+
+	webhookSignatureVersion = "v1"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside of tolerance")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// SignWebhook is the hex HMAC-SHA256 of "<unix timestamp>.<body>" under secret,
+// binding the signature to the time it was sent as well as the raw body
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the signature headers on a webhook against its raw body. Any of secrets
+// may have signed it, so a new secret can be rolled out before the old one is retired.
+func VerifyWebhook(header http.Header, body []byte, secrets []string, tolerance time.Duration, now time.Time) error {
+
+	signatures := parseSignatures(header.Get(WebhookSignatureHeader))
+	rawTimestamp := header.Get(WebhookTimestampHeader)
+	if len(signatures) == 0 || rawTimestamp == "" {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	timestamp := time.Unix(unix, 0)
+	if timestamp.Before(now.Add(-tolerance)) || timestamp.After(now.Add(tolerance)) {
+		return ErrStaleTimestamp
+	}
+
+	// every pair is compared so the time taken doesn't depend on which one matched
+	matched := 0
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected := SignWebhook(secret, timestamp, body)
+		for _, signature := range signatures {
+			matched |= subtle.ConstantTimeCompare([]byte(expected), []byte(signature))
+		}
+	}
+	if matched != 1 {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func parseSignatures(header string) []string {
+	signatures := []string{}
+	for _, part := range strings.Split(header, ",") {
+		version, signature, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && version == webhookSignatureVersion && signature != "" {
+			signatures = append(signatures, signature)
+		}
+	}
+	return signatures
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, timestamp time.Time, body []byte) http.Header {
+	header := http.Header{}
+	header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(WebhookSignatureHeader, "v1="+SignWebhook(secret, timestamp, body))
+	return header
+}
+
+func TestVerifyWebhook(t *testing.T) {
+
+	now := time.Now()
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	secrets := []string{"new-secret", "old-secret"}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		err    error
+	}{
+		{"current secret", signedHeader("new-secret", now, body), body, nil},
+		{"rotated out secret still accepted", signedHeader("old-secret", now, body), body, nil},
+		{"within tolerance", signedHeader("new-secret", now.Add(-4*time.Minute), body), body, nil},
+		{"unknown secret", signedHeader("wrong-secret", now, body), body, ErrInvalidSignature},
+		{"tampered body", signedHeader("new-secret", now, body), []byte(`{"event":"user.upgraded"}`), ErrInvalidSignature},
+		{"replayed", signedHeader("new-secret", now.Add(-10*time.Minute), body), body, ErrStaleTimestamp},
+		{"from the future", signedHeader("new-secret", now.Add(10*time.Minute), body), body, ErrStaleTimestamp},
+		{"unsigned", http.Header{}, body, ErrMissingSignature},
+	}
+
+	for _, tc := range tests {
+		err := VerifyWebhook(tc.header, tc.body, secrets, DefaultWebhookTolerance, now)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestVerifyWebhookTimestampIsSigned(t *testing.T) {
+
+	now := time.Now()
+	body := []byte(`{}`)
+
+	// a replay with a fresh timestamp but the old signature must fail
+	header := signedHeader("secret", now.Add(-time.Hour), body)
+	header.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+
+	if err := VerifyWebhook(header, body, []string{"secret"}, DefaultWebhookTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerifyWebhookMultipleSignatures(t *testing.T) {
+
+	now := time.Now()
+	body := []byte(`{}`)
+
+	header := signedHeader("secret", now, body)
+	header.Set(WebhookSignatureHeader, "v1=deadbeef, "+header.Get(WebhookSignatureHeader))
+
+	if err := VerifyWebhook(header, body, []string{"secret"}, DefaultWebhookTolerance, now); err != nil {
+		t.Errorf("expected one matching signature to be enough, got %v", err)
+	}
+
+	if err := VerifyWebhook(header, body, []string{""}, DefaultWebhookTolerance, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an empty secret to never match, got %v", err)
+	}
+}
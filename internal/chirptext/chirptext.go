@@ -0,0 +1,76 @@
+// Package chirptext, measures chirps the way people read them rather than in bytes
+package chirptext
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is what every link counts for, however long it is
+const URLWeight = 23
+
+const (
+	StandardMaxLength  = 140
+	ChirpyRedMaxLength = 1000
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// Normalize puts a chirp in NFC so the same text is always stored, and measured, the same way
+func Normalize(body string) string {
+	return norm.NFC.String(body)
+}
+
+// Length counts a normalized chirp in grapheme clusters, so an emoji or an accented letter is
+// one character however many code points it takes, with every link counting as URLWeight
+func Length(body string) int {
+
+	length := 0
+	last := 0
+	for _, match := range urlPattern.FindAllStringIndex(body, -1) {
+		length += uniseg.GraphemeClusterCount(body[last:match[0]]) + URLWeight
+		last = match[1]
+	}
+
+	return length + uniseg.GraphemeClusterCount(body[last:])
+}
+
+// Policy is how long a user's chirps may be
+type Policy struct {
+	MaxLength int
+}
+
+// PolicyFor picks the length policy for a user's tier
+func PolicyFor(chirpyRed bool) Policy {
+	if chirpyRed {
+		return Policy{MaxLength: ChirpyRedMaxLength}
+	}
+	return Policy{MaxLength: StandardMaxLength}
+}
+
+// Remaining is how many characters are left under the limit, negative once it is passed
+func (p Policy) Remaining(body string) int {
+	return p.MaxLength - Length(Normalize(body))
+}
+
+// TooLongError reports a chirp over its policy's limit
+type TooLongError struct {
+	MaxLength int
+	Length    int
+}
+
+func (e *TooLongError) Error() string {
+	return fmt.Sprintf("chirp is too long, %d of %d characters", e.Length, e.MaxLength)
+}
+
+// Check normalizes body and returns it, or a *TooLongError when it doesn't fit
+func (p Policy) Check(body string) (string, error) {
+	body = Normalize(body)
+	if length := Length(body); length > p.MaxLength {
+		return "", &TooLongError{MaxLength: p.MaxLength, Length: length}
+	}
+	return body, nil
+}
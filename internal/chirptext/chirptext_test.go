@@ -0,0 +1,69 @@
+package chirptext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		body   string
+		length int
+	}{
+		{"ascii", "hello world", 11},
+		{"empty", "", 0},
+		{"accented", "café", 4},
+		{"decomposed accent", "cafe\u0301", 4},
+		{"emoji", "🎉🎉", 2},
+		{"flag", "🇯🇵", 1},
+		{"family emoji", "👨‍👩‍👧‍👦", 1},
+		{"skin tone", "👍🏽", 1},
+		{"cjk", "こんにちは", 5},
+		{"url", "https://example.com/a/very/long/path/that/goes/on/and/on", URLWeight},
+		{"text and urls", "see http://a.co and https://example.com/x", 4 + URLWeight + 5 + URLWeight},
+	}
+
+	for _, tc := range tests {
+		if got := Length(tc.body); got != tc.length {
+			t.Errorf("%s: Length(%q) = %d, want %d", tc.name, tc.body, got, tc.length)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if Normalize("cafe\u0301") != "caf\u00e9" {
+		t.Error("expected a decomposed accent to be composed")
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+
+	standard := PolicyFor(false)
+	red := PolicyFor(true)
+
+	atLimit := strings.Repeat("🎉", StandardMaxLength)
+	if _, err := standard.Check(atLimit); err != nil {
+		t.Errorf("expected %d emoji to fit, got %v", StandardMaxLength, err)
+	}
+
+	overLimit := atLimit + "!"
+	_, err := standard.Check(overLimit)
+	var tooLong *TooLongError
+	if !errors.As(err, &tooLong) || tooLong.Length != StandardMaxLength+1 {
+		t.Errorf("expected a TooLongError for %d characters, got %v", StandardMaxLength+1, err)
+	}
+
+	if _, err := red.Check(overLimit); err != nil {
+		t.Errorf("expected a Chirpy Red user to fit %d characters, got %v", StandardMaxLength+1, err)
+	}
+
+	if remaining := standard.Remaining("hello"); remaining != StandardMaxLength-5 {
+		t.Errorf("expected %d remaining, got %d", StandardMaxLength-5, remaining)
+	}
+	if remaining := standard.Remaining(overLimit); remaining != -1 {
+		t.Errorf("expected -1 remaining, got %d", remaining)
+	}
+}
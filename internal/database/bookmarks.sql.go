@@ -0,0 +1,125 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: bookmarks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createBookmark = `-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateBookmarkParams struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateBookmark(ctx context.Context, arg CreateBookmarkParams) error {
+	_, err := q.db.ExecContext(ctx, createBookmark, arg.UserID, arg.ChirpID, arg.CreatedAt)
+	return err
+}
+
+const deleteBookmark = `-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type DeleteBookmarkParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) DeleteBookmark(ctx context.Context, arg DeleteBookmarkParams) error {
+	_, err := q.db.ExecContext(ctx, deleteBookmark, arg.UserID, arg.ChirpID)
+	return err
+}
+
+const getBookmarkedChirps = `-- name: GetBookmarkedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.reply_to_id, chirps.visibility, chirps.deleted_at, chirps.language, chirps.media_urls, chirps.held_for_review, chirps.removed_by_moderator, bookmarks.created_at AS bookmarked_at
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = $1
+    AND chirps.deleted_at IS NULL
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (bookmarks.created_at, bookmarks.chirp_id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (bookmarks.created_at, bookmarks.chirp_id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN bookmarks.created_at END ASC,
+    CASE WHEN $3::boolean THEN bookmarks.chirp_id END ASC,
+    bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT $5
+`
+
+type GetBookmarkedChirpsParams struct {
+	UserID     uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+type GetBookmarkedChirpsRow struct {
+	Chirp        Chirp
+	BookmarkedAt time.Time
+}
+
+func (q *Queries) GetBookmarkedChirps(ctx context.Context, arg GetBookmarkedChirpsParams) ([]GetBookmarkedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkedChirps,
+		arg.UserID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBookmarkedChirpsRow
+	for rows.Next() {
+		var i GetBookmarkedChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ReplyToID,
+			&i.Chirp.Visibility,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Language,
+			pq.Array(&i.Chirp.MediaUrls),
+			&i.Chirp.HeldForReview,
+			&i.Chirp.RemovedByModerator,
+			&i.BookmarkedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
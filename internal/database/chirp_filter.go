@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// chirpColumns lists every column of chirps in the order Chirp is scanned
+const chirpColumns = "id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator"
+
+// ChirpFilter is a page of the chirps timeline narrowed by any combination of filters.
+// Zero values leave a filter off. It lives outside of sqlc because one static query per
+// combination of filters doesn't scale, Build still only ever emits placeholders.
+type ChirpFilter struct {
+	// AuthorIDs limits chirps to these authors when it isn't nil
+	AuthorIDs []uuid.UUID
+	// Since is inclusive and Until exclusive
+	Since     time.Time
+	Until     time.Time
+	HasMedia  *bool
+	IsReply   *bool
+	Languages []string
+
+	Ascending  bool
+	CursorTime sql.NullTime
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+// Build renders the filter as a parameterized query and its arguments
+func (f ChirpFilter) Build() (string, []any) {
+
+	conditions := []string{"deleted_at IS NULL"}
+	args := []any{}
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.AuthorIDs != nil {
+		conditions = append(conditions, "user_id = ANY("+arg(pq.Array(f.AuthorIDs))+"::uuid[])")
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(f.Since))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(f.Until))
+	}
+	if f.HasMedia != nil {
+		if *f.HasMedia {
+			conditions = append(conditions, "cardinality(media_urls) > 0")
+		} else {
+			conditions = append(conditions, "cardinality(media_urls) = 0")
+		}
+	}
+	if f.IsReply != nil {
+		if *f.IsReply {
+			conditions = append(conditions, "reply_to_id IS NOT NULL")
+		} else {
+			conditions = append(conditions, "reply_to_id IS NULL")
+		}
+	}
+	if f.Languages != nil {
+		conditions = append(conditions, "language = ANY("+arg(pq.Array(f.Languages))+"::text[])")
+	}
+
+	direction, comparison := "DESC", "<"
+	if f.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if f.CursorTime.Valid {
+		conditions = append(conditions, "(created_at, id) "+comparison+" ("+arg(f.CursorTime.Time)+"::timestamp, "+arg(f.CursorID.UUID)+"::uuid)")
+	}
+
+	query := "SELECT " + chirpColumns +
+		"\nFROM chirps" +
+		"\nWHERE " + strings.Join(conditions, "\n    AND ") +
+		"\nORDER BY created_at " + direction + ", id " + direction +
+		"\nLIMIT " + arg(f.PageSize)
+
+	return query, args
+}
+
+func (q *Queries) FilterChirps(ctx context.Context, f ChirpFilter) ([]Chirp, error) {
+	query, args := f.Build()
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.Language,
+			pq.Array(&i.MediaUrls),
+			&i.HeldForReview,
+			&i.RemovedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
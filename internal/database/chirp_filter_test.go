@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestChirpFilterBuildDefaults(t *testing.T) {
+
+	query, args := ChirpFilter{PageSize: 20}.Build()
+
+	want := "SELECT " + chirpColumns + "\nFROM chirps\nWHERE deleted_at IS NULL\nORDER BY created_at DESC, id DESC\nLIMIT $1"
+	if query != want {
+		t.Errorf("unexpected query:\n%s\nwant:\n%s", query, want)
+	}
+	if len(args) != 1 || args[0] != int32(20) {
+		t.Errorf("expected only the page size as an argument, got %v", args)
+	}
+}
+
+func TestChirpFilterBuildEveryFilter(t *testing.T) {
+
+	yes, no := true, false
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 1, 0)
+
+	query, args := ChirpFilter{
+		AuthorIDs:  []uuid.UUID{uuid.New(), uuid.New()},
+		Since:      since,
+		Until:      until,
+		HasMedia:   &yes,
+		IsReply:    &no,
+		Languages:  []string{"english", "spanish"},
+		Ascending:  true,
+		CursorTime: sql.NullTime{Time: since, Valid: true},
+		CursorID:   uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		PageSize:   10,
+	}.Build()
+
+	for _, fragment := range []string{
+		"user_id = ANY($1::uuid[])",
+		"created_at >= $2",
+		"created_at < $3",
+		"cardinality(media_urls) > 0",
+		"reply_to_id IS NULL",
+		"language = ANY($4::text[])",
+		"(created_at, id) > ($5::timestamp, $6::uuid)",
+		"ORDER BY created_at ASC, id ASC",
+		"LIMIT $7",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected query to contain %q:\n%s", fragment, query)
+		}
+	}
+
+	if len(args) != 7 {
+		t.Errorf("expected 7 arguments, got %d", len(args))
+	}
+}
+
+func TestChirpFilterBuildNeverInlinesValues(t *testing.T) {
+
+	query, _ := ChirpFilter{
+		Languages: []string{"english'; DROP TABLE chirps; --"},
+		PageSize:  20,
+	}.Build()
+
+	if strings.Contains(query, "DROP TABLE") {
+		t.Errorf("filter value leaked into the query:\n%s", query)
+	}
+}
+
+func TestChirpFilterBuildFlippedFilters(t *testing.T) {
+
+	yes, no := true, false
+	query, _ := ChirpFilter{
+		HasMedia: &no,
+		IsReply:  &yes,
+		PageSize: 20,
+	}.Build()
+
+	for _, fragment := range []string{"cardinality(media_urls) = 0", "reply_to_id IS NOT NULL"} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected query to contain %q:\n%s", fragment, query)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: chirp_stream.sql
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getChirpStreamEvent = `-- name: GetChirpStreamEvent :one
+SELECT id, created_at, event_type, chirp_id, author_id, unlisted, payload
+FROM chirp_stream_events
+WHERE id = $1
+`
+
+func (q *Queries) GetChirpStreamEvent(ctx context.Context, id int64) (ChirpStreamEvent, error) {
+	row := q.db.QueryRowContext(ctx, getChirpStreamEvent, id)
+	var i ChirpStreamEvent
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.EventType,
+		&i.ChirpID,
+		&i.AuthorID,
+		&i.Unlisted,
+		&i.Payload,
+	)
+	return i, err
+}
+
+const getChirpStreamEventsAfter = `-- name: GetChirpStreamEventsAfter :many
+SELECT id, created_at, event_type, chirp_id, author_id, unlisted, payload
+FROM chirp_stream_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type GetChirpStreamEventsAfterParams struct {
+	AfterID   int64
+	MaxEvents int32
+}
+
+func (q *Queries) GetChirpStreamEventsAfter(ctx context.Context, arg GetChirpStreamEventsAfterParams) ([]ChirpStreamEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpStreamEventsAfter, arg.AfterID, arg.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpStreamEvent
+	for rows.Next() {
+		var i ChirpStreamEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.EventType,
+			&i.ChirpID,
+			&i.AuthorID,
+			&i.Unlisted,
+			&i.Payload,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestChirpStreamEventID = `-- name: GetLatestChirpStreamEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint
+FROM chirp_stream_events
+`
+
+func (q *Queries) GetLatestChirpStreamEventID(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getLatestChirpStreamEventID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const purgeChirpStreamEvents = `-- name: PurgeChirpStreamEvents :execrows
+DELETE FROM chirp_stream_events
+WHERE created_at < $1::timestamp
+`
+
+func (q *Queries) PurgeChirpStreamEvents(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeChirpStreamEvents, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordChirpStreamEvent = `-- name: RecordChirpStreamEvent :exec
+WITH recorded AS (
+    INSERT INTO chirp_stream_events (created_at, event_type, chirp_id, author_id, unlisted, payload)
+    SELECT $1::timestamp, $2::text, $3::uuid, users.id,
+        $4::text = 'unlisted', $5::jsonb
+    FROM users
+    WHERE users.id = $6::uuid
+        AND NOT users.is_protected
+        AND users.shadow_banned_at IS NULL
+        AND $4::text IN ('public', 'unlisted')
+    RETURNING id
+)
+SELECT pg_notify('chirp_stream', id::text)
+FROM recorded
+`
+
+type RecordChirpStreamEventParams struct {
+	CreatedAt  time.Time
+	EventType  string
+	ChirpID    uuid.UUID
+	Visibility string
+	Payload    json.RawMessage
+	AuthorID   uuid.UUID
+}
+
+// only chirps anyone may see are streamed. The notification carries the event's id and is
+// delivered to every instance listening when the transaction commits.
+func (q *Queries) RecordChirpStreamEvent(ctx context.Context, arg RecordChirpStreamEventParams) error {
+	_, err := q.db.ExecContext(ctx, recordChirpStreamEvent,
+		arg.CreatedAt,
+		arg.EventType,
+		arg.ChirpID,
+		arg.Visibility,
+		arg.Payload,
+		arg.AuthorID,
+	)
+	return err
+}
@@ -7,29 +7,41 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, visibility, language, media_urls, held_for_review)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
 `
 
 type CreateChirpParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	ReplyToID     uuid.NullUUID
+	Visibility    string
+	Language      string
+	MediaUrls     []string
+	HeldForReview bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -39,6 +51,11 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.ReplyToID,
+		arg.Visibility,
+		arg.Language,
+		pq.Array(arg.MediaUrls),
+		arg.HeldForReview,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -47,28 +64,99 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.Language,
+		pq.Array(&i.MediaUrls),
+		&i.HeldForReview,
+		&i.RemovedByModerator,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1
+const editChirp = `-- name: EditChirp :one
+UPDATE chirps
+SET body = $1,
+    updated_at = $2,
+    held_for_review = held_for_review OR $3::boolean
+WHERE id = $4 AND user_id = $5 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
+type EditChirpParams struct {
+	Body      string
+	UpdatedAt time.Time
+	Hold      bool
+	ID        uuid.UUID
+	UserID    uuid.UUID
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id 
-FROM chirps
-ORDER BY created_at ASC
+// an edit that screens as suspicious holds the chirp, an edit never releases one
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp,
+		arg.Body,
+		arg.UpdatedAt,
+		arg.Hold,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.Language,
+		pq.Array(&i.MediaUrls),
+		&i.HeldForReview,
+		&i.RemovedByModerator,
+	)
+	return i, err
+}
+
+const getChirpsPageByAuthor = `-- name: GetChirpsPageByAuthor :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id, c.visibility, c.deleted_at, c.language, c.media_urls, c.held_for_review, c.removed_by_moderator
+FROM chirps c
+WHERE c.user_id = $1
+    AND c.deleted_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1
+        FROM pinned_chirps p
+        WHERE p.user_id = c.user_id AND p.chirp_id = c.id
+    )
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (c.created_at, c.id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (c.created_at, c.id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN c.created_at END ASC,
+    CASE WHEN $3::boolean THEN c.id END ASC,
+    c.created_at DESC, c.id DESC
+LIMIT $5
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetChirpsPageByAuthorParams struct {
+	UserID     uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetChirpsPageByAuthor(ctx context.Context, arg GetChirpsPageByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageByAuthor,
+		arg.UserID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +170,13 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.Language,
+			pq.Array(&i.MediaUrls),
+			&i.HeldForReview,
+			&i.RemovedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -96,14 +191,68 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getAllChirpsByAuthor = `-- name: GetAllChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id 
+const getOneChirp = `-- name: GetOneChirp :one
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator 
+FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getOneChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.Language,
+		pq.Array(&i.MediaUrls),
+		&i.HeldForReview,
+		&i.RemovedByModerator,
+	)
+	return i, err
+}
+
+const getTrashedChirpsByAuthor = `-- name: GetTrashedChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
 FROM chirps
 WHERE user_id = $1
+    AND deleted_at > $2::timestamp
+    AND NOT removed_by_moderator
+    AND (
+        $3::timestamp IS NULL
+        OR (NOT $4::boolean AND (deleted_at, id) < ($3::timestamp, $5::uuid))
+        OR ($4::boolean AND (deleted_at, id) > ($3::timestamp, $5::uuid))
+    )
+ORDER BY
+    CASE WHEN $4::boolean THEN deleted_at END ASC,
+    CASE WHEN $4::boolean THEN id END ASC,
+    deleted_at DESC, id DESC
+LIMIT $6
 `
 
-func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsByAuthor, userID)
+type GetTrashedChirpsByAuthorParams struct {
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+	CursorTime   sql.NullTime
+	Backward     bool
+	CursorID     uuid.NullUUID
+	PageSize     int32
+}
+
+func (q *Queries) GetTrashedChirpsByAuthor(ctx context.Context, arg GetTrashedChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTrashedChirpsByAuthor,
+		arg.UserID,
+		arg.DeletedAfter,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -117,6 +266,13 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.Language,
+			pq.Array(&i.MediaUrls),
+			&i.HeldForReview,
+			&i.RemovedByModerator,
 		); err != nil {
 			return nil, err
 		}
@@ -131,14 +287,43 @@ func (q *Queries) GetAllChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([
 	return items, nil
 }
 
-const getOneChirp = `-- name: GetOneChirp :one
-SELECT id, created_at, updated_at, body, user_id 
-FROM chirps
-WHERE id = $1
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= $1::timestamp
 `
 
-func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getOneChirp, id)
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = $1
+WHERE id = $2
+    AND user_id = $3
+    AND deleted_at > $4::timestamp
+    AND NOT removed_by_moderator
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
+`
+
+type RestoreChirpParams struct {
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.DeletedAfter,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -146,6 +331,32 @@ func (q *Queries) GetOneChirp(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.Language,
+		pq.Array(&i.MediaUrls),
+		&i.HeldForReview,
+		&i.RemovedByModerator,
 	)
 	return i, err
 }
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = $1::timestamp,
+    updated_at = $1::timestamp,
+    removed_by_moderator = $2
+WHERE id = $3 AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpParams struct {
+	DeletedAt          time.Time
+	RemovedByModerator bool
+	ID                 uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirp, arg.DeletedAt, arg.RemovedByModerator, arg.ID)
+	return err
+}
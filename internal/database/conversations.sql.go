@@ -0,0 +1,378 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: conversations.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addConversationMember = `-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+`
+
+type AddConversationMemberParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+}
+
+func (q *Queries) AddConversationMember(ctx context.Context, arg AddConversationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationMember, arg.ConversationID, arg.UserID, arg.JoinedAt)
+	return err
+}
+
+const createConversation = `-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group, direct_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING id, created_at, updated_at, created_by, is_group, direct_key
+`
+
+type CreateConversationParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.UUID
+	IsGroup   bool
+	DirectKey sql.NullString
+}
+
+// returns no rows when the one-to-one conversation for direct_key already exists
+func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.CreatedBy,
+		arg.IsGroup,
+		arg.DirectKey,
+	)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const createDirectMessage = `-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, conversation_id, sender_id, body
+`
+
+type CreateDirectMessageParams struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+func (q *Queries) CreateDirectMessage(ctx context.Context, arg CreateDirectMessageParams) (DirectMessage, error) {
+	row := q.db.QueryRowContext(ctx, createDirectMessage,
+		arg.ID,
+		arg.CreatedAt,
+		arg.ConversationID,
+		arg.SenderID,
+		arg.Body,
+	)
+	var i DirectMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const findDirectConversation = `-- name: FindDirectConversation :one
+SELECT id, created_at, updated_at, created_by, is_group, direct_key
+FROM conversations
+WHERE direct_key = $1::text
+`
+
+func (q *Queries) FindDirectConversation(ctx context.Context, directKey string) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, findDirectConversation, directKey)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const getConversationForMember = `-- name: GetConversationForMember :one
+SELECT c.id, c.created_at, c.updated_at, c.created_by, c.is_group, c.direct_key
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE c.id = $1 AND m.user_id = $2
+`
+
+type GetConversationForMemberParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetConversationForMember(ctx context.Context, arg GetConversationForMemberParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getConversationForMember, arg.ID, arg.UserID)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const listConversationMembers = `-- name: ListConversationMembers :many
+SELECT conversation_id, user_id, joined_at, last_read_at
+FROM conversation_members
+WHERE conversation_id = ANY($1::uuid[])
+ORDER BY joined_at ASC
+`
+
+func (q *Queries) ListConversationMembers(ctx context.Context, conversationIds []uuid.UUID) ([]ConversationMember, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationMembers, pq.Array(conversationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ConversationMember
+	for rows.Next() {
+		var i ConversationMember
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UserID,
+			&i.JoinedAt,
+			&i.LastReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationsForUser = `-- name: ListConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at, c.created_by, c.is_group, c.direct_key, (
+    SELECT COUNT(*)
+    FROM direct_messages d
+    WHERE d.conversation_id = c.id
+        AND d.sender_id <> m.user_id
+        AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at)
+) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (c.updated_at, c.id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (c.updated_at, c.id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN c.updated_at END ASC,
+    CASE WHEN $3::boolean THEN c.id END ASC,
+    c.updated_at DESC, c.id DESC
+LIMIT $5
+`
+
+type ListConversationsForUserParams struct {
+	UserID     uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+type ListConversationsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CreatedBy   uuid.UUID
+	IsGroup     bool
+	DirectKey   sql.NullString
+	UnreadCount int64
+}
+
+func (q *Queries) ListConversationsForUser(ctx context.Context, arg ListConversationsForUserParams) ([]ListConversationsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationsForUser,
+		arg.UserID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListConversationsForUserRow
+	for rows.Next() {
+		var i ListConversationsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatedBy,
+			&i.IsGroup,
+			&i.DirectKey,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDirectMessages = `-- name: ListDirectMessages :many
+SELECT d.id, d.created_at, d.conversation_id, d.sender_id, d.body
+FROM direct_messages d
+WHERE d.conversation_id = $1
+    AND NOT EXISTS (
+        SELECT 1
+        FROM blocks b
+        WHERE b.blocker_id = $2 AND b.blocked_id = d.sender_id
+    )
+    AND (
+        $3::timestamp IS NULL
+        OR (NOT $4::boolean AND (d.created_at, d.id) < ($3::timestamp, $5::uuid))
+        OR ($4::boolean AND (d.created_at, d.id) > ($3::timestamp, $5::uuid))
+    )
+ORDER BY
+    CASE WHEN $4::boolean THEN d.created_at END ASC,
+    CASE WHEN $4::boolean THEN d.id END ASC,
+    d.created_at DESC, d.id DESC
+LIMIT $6
+`
+
+type ListDirectMessagesParams struct {
+	ConversationID uuid.UUID
+	ViewerID       uuid.UUID
+	CursorTime     sql.NullTime
+	Backward       bool
+	CursorID       uuid.NullUUID
+	PageSize       int32
+}
+
+func (q *Queries) ListDirectMessages(ctx context.Context, arg ListDirectMessagesParams) ([]DirectMessage, error) {
+	rows, err := q.db.QueryContext(ctx, listDirectMessages,
+		arg.ConversationID,
+		arg.ViewerID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DirectMessage
+	for rows.Next() {
+		var i DirectMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markConversationRead = `-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = $1::timestamp
+WHERE conversation_id = $2 AND user_id = $3
+    AND (last_read_at IS NULL OR last_read_at < $1::timestamp)
+`
+
+type MarkConversationReadParams struct {
+	ReadAt         time.Time
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error {
+	_, err := q.db.ExecContext(ctx, markConversationRead, arg.ReadAt, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const touchConversation = `-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = $2
+WHERE id = $1
+`
+
+type TouchConversationParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) TouchConversation(ctx context.Context, arg TouchConversationParams) error {
+	_, err := q.db.ExecContext(ctx, touchConversation, arg.ID, arg.UpdatedAt)
+	return err
+}
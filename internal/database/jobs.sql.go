@@ -0,0 +1,298 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: jobs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = $1::timestamp,
+    updated_at = $2::timestamp
+WHERE id = (
+    SELECT id
+    FROM jobs
+    WHERE kind = ANY($3::text[])
+        AND (
+            (status = 'pending' AND run_at <= $2::timestamp)
+            OR (status = 'running' AND locked_until < $2::timestamp)
+        )
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, finished_at, unique_key
+`
+
+type ClaimJobParams struct {
+	LeaseUntil time.Time
+	Now        time.Time
+	Kinds      []string
+}
+
+// locks the next due job until lease_until, skipping jobs other workers hold
+func (q *Queries) ClaimJob(ctx context.Context, arg ClaimJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, claimJob, arg.LeaseUntil, arg.Now, pq.Array(arg.Kinds))
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedUntil,
+		&i.LastError,
+		&i.FinishedAt,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'completed',
+    locked_until = NULL,
+    last_error = NULL,
+    finished_at = $1::timestamp,
+    updated_at = $1::timestamp
+WHERE id = $2
+`
+
+type CompleteJobParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) CompleteJob(ctx context.Context, arg CompleteJobParams) error {
+	_, err := q.db.ExecContext(ctx, completeJob, arg.Now, arg.ID)
+	return err
+}
+
+const enqueueJob = `-- name: EnqueueJob :exec
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (unique_key) DO NOTHING
+`
+
+type EnqueueJobParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	MaxAttempts int32
+	RunAt       time.Time
+	UniqueKey   sql.NullString
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) error {
+	_, err := q.db.ExecContext(ctx, enqueueJob,
+		arg.ID,
+		arg.CreatedAt,
+		arg.Kind,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.RunAt,
+		arg.UniqueKey,
+	)
+	return err
+}
+
+const failJob = `-- name: FailJob :exec
+UPDATE jobs
+SET status = CASE WHEN $1::boolean THEN 'dead' ELSE 'pending' END,
+    run_at = $2::timestamp,
+    locked_until = NULL,
+    last_error = $3::text,
+    finished_at = CASE WHEN $1::boolean THEN $4::timestamp END,
+    updated_at = $4::timestamp
+WHERE id = $5
+`
+
+type FailJobParams struct {
+	Dead      bool
+	RunAt     time.Time
+	LastError string
+	Now       time.Time
+	ID        uuid.UUID
+}
+
+// a dead job stays put until an admin retries it, any other goes back in the queue at run_at
+func (q *Queries) FailJob(ctx context.Context, arg FailJobParams) error {
+	_, err := q.db.ExecContext(ctx, failJob,
+		arg.Dead,
+		arg.RunAt,
+		arg.LastError,
+		arg.Now,
+		arg.ID,
+	)
+	return err
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, finished_at, unique_key
+FROM jobs
+WHERE id = $1
+`
+
+func (q *Queries) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	row := q.db.QueryRowContext(ctx, getJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedUntil,
+		&i.LastError,
+		&i.FinishedAt,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const getJobs = `-- name: GetJobs :many
+SELECT id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, finished_at, unique_key
+FROM jobs
+WHERE ($1::text IS NULL OR status = $1::text)
+    AND ($2::text IS NULL OR kind = $2::text)
+    AND (
+        $3::timestamp IS NULL
+        OR (NOT $4::boolean AND (created_at, id) < ($3::timestamp, $5::uuid))
+        OR ($4::boolean AND (created_at, id) > ($3::timestamp, $5::uuid))
+    )
+ORDER BY
+    CASE WHEN $4::boolean THEN created_at END ASC,
+    CASE WHEN $4::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $6
+`
+
+type GetJobsParams struct {
+	Status     sql.NullString
+	Kind       sql.NullString
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetJobs(ctx context.Context, arg GetJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, getJobs,
+		arg.Status,
+		arg.Kind,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.FinishedAt,
+			&i.UniqueKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeFinishedJobs = `-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'completed' AND finished_at < $1::timestamp
+`
+
+func (q *Queries) PurgeFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeFinishedJobs, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryJob = `-- name: RetryJob :one
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = $1, finished_at = NULL, updated_at = $1
+WHERE id = $2 AND status = 'dead'
+RETURNING id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, locked_until, last_error, finished_at, unique_key
+`
+
+type RetryJobParams struct {
+	RunAt time.Time
+	ID    uuid.UUID
+}
+
+// puts a dead job back in the queue with a fresh set of attempts
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, retryJob, arg.RunAt, arg.ID)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedUntil,
+		&i.LastError,
+		&i.FinishedAt,
+		&i.UniqueKey,
+	)
+	return i, err
+}
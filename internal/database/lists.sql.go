@@ -0,0 +1,291 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: lists.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const addListMember = `-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, added_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type AddListMemberParams struct {
+	ListID  uuid.UUID
+	UserID  uuid.UUID
+	AddedAt time.Time
+}
+
+func (q *Queries) AddListMember(ctx context.Context, arg AddListMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addListMember, arg.ListID, arg.UserID, arg.AddedAt)
+	return err
+}
+
+const createList = `-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, owner_id, name
+`
+
+type CreateListParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Name      string
+}
+
+func (q *Queries) CreateList(ctx context.Context, arg CreateListParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, createList,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.OwnerID,
+		arg.Name,
+	)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const deleteList = `-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1
+`
+
+func (q *Queries) DeleteList(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteList, id)
+	return err
+}
+
+const getListForOwner = `-- name: GetListForOwner :one
+SELECT id, created_at, updated_at, owner_id, name
+FROM lists
+WHERE id = $1 AND owner_id = $2
+`
+
+type GetListForOwnerParams struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+}
+
+func (q *Queries) GetListForOwner(ctx context.Context, arg GetListForOwnerParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, getListForOwner, arg.ID, arg.OwnerID)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getListMembers = `-- name: GetListMembers :many
+SELECT list_id, user_id, added_at
+FROM list_members
+WHERE list_id = $1
+ORDER BY added_at ASC
+`
+
+func (q *Queries) GetListMembers(ctx context.Context, listID uuid.UUID) ([]ListMember, error) {
+	rows, err := q.db.QueryContext(ctx, getListMembers, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMember
+	for rows.Next() {
+		var i ListMember
+		if err := rows.Scan(&i.ListID, &i.UserID, &i.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getListMembersPage = `-- name: GetListMembersPage :many
+SELECT list_id, user_id, added_at
+FROM list_members
+WHERE list_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (added_at, user_id) > ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (added_at, user_id) < ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN added_at END DESC,
+    CASE WHEN $3::boolean THEN user_id END DESC,
+    added_at ASC, user_id ASC
+LIMIT $5
+`
+
+type GetListMembersPageParams struct {
+	ListID     uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetListMembersPage(ctx context.Context, arg GetListMembersPageParams) ([]ListMember, error) {
+	rows, err := q.db.QueryContext(ctx, getListMembersPage,
+		arg.ListID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMember
+	for rows.Next() {
+		var i ListMember
+		if err := rows.Scan(&i.ListID, &i.UserID, &i.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getListsByOwner = `-- name: GetListsByOwner :many
+SELECT id, created_at, updated_at, owner_id, name
+FROM lists
+WHERE owner_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, id) > ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, id) < ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END DESC,
+    CASE WHEN $3::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT $5
+`
+
+type GetListsByOwnerParams struct {
+	OwnerID    uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetListsByOwner(ctx context.Context, arg GetListsByOwnerParams) ([]List, error) {
+	rows, err := q.db.QueryContext(ctx, getListsByOwner,
+		arg.OwnerID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []List
+	for rows.Next() {
+		var i List
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.Name,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeListMember = `-- name: RemoveListMember :exec
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2
+`
+
+type RemoveListMemberParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) RemoveListMember(ctx context.Context, arg RemoveListMemberParams) error {
+	_, err := q.db.ExecContext(ctx, removeListMember, arg.ListID, arg.UserID)
+	return err
+}
+
+const renameList = `-- name: RenameList :one
+UPDATE lists
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, owner_id, name
+`
+
+type RenameListParams struct {
+	Name      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) RenameList(ctx context.Context, arg RenameListParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, renameList, arg.Name, arg.UpdatedAt, arg.ID)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+	)
+	return i, err
+}
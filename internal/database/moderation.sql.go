@@ -0,0 +1,295 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createModerationWord = `-- name: CreateModerationWord :one
+INSERT INTO moderation_words (id, created_at, updated_at, word, action)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, word, action
+`
+
+type CreateModerationWordParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Word      string
+	Action    string
+}
+
+func (q *Queries) CreateModerationWord(ctx context.Context, arg CreateModerationWordParams) (ModerationWord, error) {
+	row := q.db.QueryRowContext(ctx, createModerationWord,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Word,
+		arg.Action,
+	)
+	var i ModerationWord
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Word,
+		&i.Action,
+	)
+	return i, err
+}
+
+const deleteModerationWord = `-- name: DeleteModerationWord :one
+DELETE FROM moderation_words
+WHERE id = $1
+RETURNING id, created_at, updated_at, word, action
+`
+
+func (q *Queries) DeleteModerationWord(ctx context.Context, id uuid.UUID) (ModerationWord, error) {
+	row := q.db.QueryRowContext(ctx, deleteModerationWord, id)
+	var i ModerationWord
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Word,
+		&i.Action,
+	)
+	return i, err
+}
+
+const dismissChirpFlag = `-- name: DismissChirpFlag :execrows
+DELETE FROM chirp_flags
+WHERE chirp_id = $1
+`
+
+func (q *Queries) DismissChirpFlag(ctx context.Context, chirpID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, dismissChirpFlag, chirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const flagChirp = `-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, created_at, words)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (chirp_id) DO NOTHING
+`
+
+type FlagChirpParams struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Words     []string
+}
+
+func (q *Queries) FlagChirp(ctx context.Context, arg FlagChirpParams) error {
+	_, err := q.db.ExecContext(ctx, flagChirp, arg.ChirpID, arg.CreatedAt, pq.Array(arg.Words))
+	return err
+}
+
+const getChirpFlags = `-- name: GetChirpFlags :many
+SELECT chirp_flags.chirp_id, chirp_flags.created_at, chirp_flags.words, chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.reply_to_id, chirps.visibility, chirps.deleted_at, chirps.language, chirps.media_urls, chirps.held_for_review, chirps.removed_by_moderator
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+    AND (
+        $1::timestamp IS NULL
+        OR (NOT $2::boolean AND (chirp_flags.created_at, chirp_flags.chirp_id) > ($1::timestamp, $3::uuid))
+        OR ($2::boolean AND (chirp_flags.created_at, chirp_flags.chirp_id) < ($1::timestamp, $3::uuid))
+    )
+ORDER BY
+    CASE WHEN $2::boolean THEN chirp_flags.created_at END DESC,
+    CASE WHEN $2::boolean THEN chirp_flags.chirp_id END DESC,
+    chirp_flags.created_at ASC, chirp_flags.chirp_id ASC
+LIMIT $4
+`
+
+type GetChirpFlagsParams struct {
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+type GetChirpFlagsRow struct {
+	ChirpFlag ChirpFlag
+	Chirp     Chirp
+}
+
+func (q *Queries) GetChirpFlags(ctx context.Context, arg GetChirpFlagsParams) ([]GetChirpFlagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpFlags,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpFlagsRow
+	for rows.Next() {
+		var i GetChirpFlagsRow
+		if err := rows.Scan(
+			&i.ChirpFlag.ChirpID,
+			&i.ChirpFlag.CreatedAt,
+			pq.Array(&i.ChirpFlag.Words),
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ReplyToID,
+			&i.Chirp.Visibility,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Language,
+			pq.Array(&i.Chirp.MediaUrls),
+			&i.Chirp.HeldForReview,
+			&i.Chirp.RemovedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getModerationRules = `-- name: GetModerationRules :many
+SELECT word, action
+FROM moderation_words
+`
+
+type GetModerationRulesRow struct {
+	Word   string
+	Action string
+}
+
+func (q *Queries) GetModerationRules(ctx context.Context) ([]GetModerationRulesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetModerationRulesRow
+	for rows.Next() {
+		var i GetModerationRulesRow
+		if err := rows.Scan(&i.Word, &i.Action); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getModerationWords = `-- name: GetModerationWords :many
+SELECT id, created_at, updated_at, word, action
+FROM moderation_words
+WHERE (
+        $1::timestamp IS NULL
+        OR (NOT $2::boolean AND (created_at, id) > ($1::timestamp, $3::uuid))
+        OR ($2::boolean AND (created_at, id) < ($1::timestamp, $3::uuid))
+    )
+ORDER BY
+    CASE WHEN $2::boolean THEN created_at END DESC,
+    CASE WHEN $2::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetModerationWordsParams struct {
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetModerationWords(ctx context.Context, arg GetModerationWordsParams) ([]ModerationWord, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationWords,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationWord
+	for rows.Next() {
+		var i ModerationWord
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Word,
+			&i.Action,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateModerationWord = `-- name: UpdateModerationWord :one
+UPDATE moderation_words
+SET action = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, word, action
+`
+
+type UpdateModerationWordParams struct {
+	Action    string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateModerationWord(ctx context.Context, arg UpdateModerationWordParams) (ModerationWord, error) {
+	row := q.db.QueryRowContext(ctx, updateModerationWord, arg.Action, arg.UpdatedAt, arg.ID)
+	var i ModerationWord
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Word,
+		&i.Action,
+	)
+	return i, err
+}
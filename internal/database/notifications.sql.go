@@ -0,0 +1,266 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addNotificationActor = `-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (notification_id, actor_id)
+DO UPDATE SET created_at = EXCLUDED.created_at
+`
+
+type AddNotificationActorParams struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+func (q *Queries) AddNotificationActor(ctx context.Context, arg AddNotificationActorParams) error {
+	_, err := q.db.ExecContext(ctx, addNotificationActor, arg.NotificationID, arg.ActorID, arg.CreatedAt)
+	return err
+}
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const listNotifications = `-- name: ListNotifications :many
+SELECT n.id, n.created_at, n.updated_at, n.user_id, n.kind, n.chirp_id, n.group_key, n.read_at, (
+    SELECT COUNT(*)
+    FROM notification_actors a
+    WHERE a.notification_id = n.id
+) AS actor_count
+FROM notifications n
+WHERE n.user_id = $1
+    AND (NOT $2::boolean OR n.read_at IS NULL)
+    AND (
+        $3::timestamp IS NULL
+        OR (NOT $4::boolean AND (n.created_at, n.id) < ($3::timestamp, $5::uuid))
+        OR ($4::boolean AND (n.created_at, n.id) > ($3::timestamp, $5::uuid))
+    )
+ORDER BY
+    CASE WHEN $4::boolean THEN n.created_at END ASC,
+    CASE WHEN $4::boolean THEN n.id END ASC,
+    n.created_at DESC, n.id DESC
+LIMIT $6
+`
+
+type ListNotificationsParams struct {
+	UserID     uuid.UUID
+	UnreadOnly bool
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+type ListNotificationsRow struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Kind       string
+	ChirpID    uuid.NullUUID
+	GroupKey   string
+	ReadAt     sql.NullTime
+	ActorCount int64
+}
+
+// pages by created_at, which stays put while updated_at moves each time an actor joins the group
+func (q *Queries) ListNotifications(ctx context.Context, arg ListNotificationsParams) ([]ListNotificationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNotificationsRow
+	for rows.Next() {
+		var i ListNotificationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Kind,
+			&i.ChirpID,
+			&i.GroupKey,
+			&i.ReadAt,
+			&i.ActorCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRecentNotificationActors = `-- name: ListRecentNotificationActors :many
+SELECT ranked.notification_id, ranked.actor_id
+FROM (
+    SELECT notification_id, actor_id, row_number() OVER (
+        PARTITION BY notification_id
+        ORDER BY created_at DESC
+    ) AS position
+    FROM notification_actors
+    WHERE notification_id = ANY($1::uuid[])
+) ranked
+WHERE ranked.position <= $2::bigint
+ORDER BY ranked.notification_id, ranked.position
+`
+
+type ListRecentNotificationActorsParams struct {
+	NotificationIds []uuid.UUID
+	PerNotification int64
+}
+
+type ListRecentNotificationActorsRow struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+}
+
+func (q *Queries) ListRecentNotificationActors(ctx context.Context, arg ListRecentNotificationActorsParams) ([]ListRecentNotificationActorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listRecentNotificationActors, pq.Array(arg.NotificationIds), arg.PerNotification)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRecentNotificationActorsRow
+	for rows.Next() {
+		var i ListRecentNotificationActorsRow
+		if err := rows.Scan(&i.NotificationID, &i.ActorID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = $1::timestamp
+WHERE user_id = $2 AND read_at IS NULL
+`
+
+type MarkAllNotificationsReadParams struct {
+	ReadAt time.Time
+	UserID uuid.UUID
+}
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, arg MarkAllNotificationsReadParams) error {
+	_, err := q.db.ExecContext(ctx, markAllNotificationsRead, arg.ReadAt, arg.UserID)
+	return err
+}
+
+const markNotificationRead = `-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = $1::timestamp
+WHERE id = $2 AND user_id = $3 AND read_at IS NULL
+`
+
+type MarkNotificationReadParams struct {
+	ReadAt time.Time
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationRead, arg.ReadAt, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertNotification = `-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, kind, chirp_id, group_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = EXCLUDED.updated_at
+RETURNING id, created_at, updated_at, user_id, kind, chirp_id, group_key, read_at
+`
+
+type UpsertNotificationParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Kind      string
+	ChirpID   uuid.NullUUID
+	GroupKey  string
+}
+
+func (q *Queries) UpsertNotification(ctx context.Context, arg UpsertNotificationParams) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, upsertNotification,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Kind,
+		arg.ChirpID,
+		arg.GroupKey,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Kind,
+		&i.ChirpID,
+		&i.GroupKey,
+		&i.ReadAt,
+	)
+	return i, err
+}
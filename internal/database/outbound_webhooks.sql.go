@@ -0,0 +1,555 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: outbound_webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1::timestamp
+WHERE id IN (
+    SELECT d.id
+    FROM webhook_deliveries d
+    JOIN webhook_endpoints e ON e.id = d.endpoint_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= $2::timestamp AND e.enabled
+    ORDER BY d.next_attempt_at
+    LIMIT $3
+    FOR UPDATE OF d SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil    time.Time
+	Now           time.Time
+	MaxDeliveries int32
+}
+
+// leases due deliveries by pushing next_attempt_at past the send timeout, so a crashed
+// dispatcher's deliveries are picked up again once the lease runs out
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, scope, url, secret, event_types)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING id, created_at, updated_at, user_id, scope, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type CreateWebhookEndpointParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Scope      string
+	Url        string
+	Secret     string
+	EventTypes []string
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEndpoint,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Scope,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.EventTypes),
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Scope,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebhookEndpointParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookEndpoint, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableWebhookEndpoint = `-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = true, consecutive_failures = 0, disabled_at = NULL, updated_at = $1
+WHERE id = $2 AND user_id = $3
+RETURNING id, created_at, updated_at, user_id, scope, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type EnableWebhookEndpointParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) EnableWebhookEndpoint(ctx context.Context, arg EnableWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, enableWebhookEndpoint, arg.UpdatedAt, arg.ID, arg.UserID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Scope,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), $1::timestamp, $1::timestamp, id,
+    $2::uuid, $3::text, $4::jsonb, $1::timestamp
+FROM webhook_endpoints
+WHERE enabled
+    AND $3::text = ANY(event_types)
+    AND (scope = 'global' OR user_id = $5::uuid)
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	CreatedAt     time.Time
+	EventID       uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	SubjectUserID uuid.UUID
+}
+
+// one delivery for every enabled endpoint that subscribed to the event and may see it
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookDeliveries,
+		arg.CreatedAt,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.SubjectUserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at
+FROM webhook_deliveries
+WHERE endpoint_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END ASC,
+    CASE WHEN $3::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $5
+`
+
+type GetWebhookDeliveriesParams struct {
+	EndpointID uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries,
+		arg.EndpointID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at
+FROM webhook_deliveries
+WHERE id = $1 AND endpoint_id = $2
+`
+
+type GetWebhookDeliveryParams struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+}
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, arg.ID, arg.EndpointID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getWebhookDeliveryAttempts = `-- name: GetWebhookDeliveryAttempts :many
+SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at
+`
+
+func (q *Queries) GetWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]WebhookDeliveryAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveryAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.AttemptedAt,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT id, created_at, updated_at, user_id, scope, url, secret, event_types, enabled, consecutive_failures, disabled_at
+FROM webhook_endpoints
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEndpoint, id)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Scope,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getWebhookEndpointsByUser = `-- name: GetWebhookEndpointsByUser :many
+SELECT id, created_at, updated_at, user_id, scope, url, secret, event_types, enabled, consecutive_failures, disabled_at
+FROM webhook_endpoints
+WHERE user_id = $1
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END ASC,
+    CASE WHEN $3::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $5
+`
+
+type GetWebhookEndpointsByUserParams struct {
+	UserID     uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetWebhookEndpointsByUser(ctx context.Context, arg GetWebhookEndpointsByUserParams) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookEndpointsByUser,
+		arg.UserID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Scope,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.EventTypes),
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	AttemptedAt time.Time
+	StatusCode  sql.NullInt32
+	Error       sql.NullString
+	DurationMs  int32
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookDeliveryAttempt,
+		arg.ID,
+		arg.DeliveryID,
+		arg.AttemptedAt,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const recordWebhookEndpointFailure = `-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND consecutive_failures + 1 < $1::integer,
+    disabled_at = CASE
+        WHEN enabled AND consecutive_failures + 1 >= $1::integer THEN $2::timestamp
+        ELSE disabled_at
+    END,
+    updated_at = $2::timestamp
+WHERE id = $3
+RETURNING id, created_at, updated_at, user_id, scope, url, secret, event_types, enabled, consecutive_failures, disabled_at
+`
+
+type RecordWebhookEndpointFailureParams struct {
+	DisableAfter int32
+	Now          time.Time
+	ID           uuid.UUID
+}
+
+// switches the endpoint off once it has failed disable_after times in a row
+func (q *Queries) RecordWebhookEndpointFailure(ctx context.Context, arg RecordWebhookEndpointFailureParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookEndpointFailure, arg.DisableAfter, arg.Now, arg.ID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Scope,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.EventTypes),
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const resetWebhookEndpointFailures = `-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures > 0
+`
+
+func (q *Queries) ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetWebhookEndpointFailures, id)
+	return err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $1,
+    attempts = attempts + 1,
+    next_attempt_at = $2,
+    last_status_code = $3,
+    last_error = $4,
+    delivered_at = $5,
+    updated_at = $6
+WHERE id = $7
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status         string
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, updateWebhookDelivery,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.DeliveredAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
@@ -0,0 +1,112 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: pinned_chirps.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countPinnedChirps = `-- name: CountPinnedChirps :one
+SELECT COUNT(*)
+FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1 AND chirps.deleted_at IS NULL
+`
+
+func (q *Queries) CountPinnedChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPinnedChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getPinnedChirps = `-- name: GetPinnedChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.reply_to_id, chirps.visibility, chirps.deleted_at, chirps.language, chirps.media_urls, chirps.held_for_review, chirps.removed_by_moderator
+FROM pinned_chirps
+JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1 AND chirps.deleted_at IS NULL
+ORDER BY pinned_chirps.pinned_at DESC
+`
+
+type GetPinnedChirpsRow struct {
+	Chirp Chirp
+}
+
+func (q *Queries) GetPinnedChirps(ctx context.Context, userID uuid.UUID) ([]GetPinnedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPinnedChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPinnedChirpsRow
+	for rows.Next() {
+		var i GetPinnedChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ReplyToID,
+			&i.Chirp.Visibility,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Language,
+			pq.Array(&i.Chirp.MediaUrls),
+			&i.Chirp.HeldForReview,
+			&i.Chirp.RemovedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pinChirp = `-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type PinChirpParams struct {
+	UserID   uuid.UUID
+	ChirpID  uuid.UUID
+	PinnedAt time.Time
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, pinChirp, arg.UserID, arg.ChirpID, arg.PinnedAt)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :exec
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type UnpinChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, unpinChirp, arg.UserID, arg.ChirpID)
+	return err
+}
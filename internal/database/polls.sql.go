@@ -0,0 +1,230 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: polls.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const castPollVote = `-- name: CastPollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, user_id) DO NOTHING
+`
+
+type CastPollVoteParams struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CastPollVote(ctx context.Context, arg CastPollVoteParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, castPollVote,
+		arg.ChirpID,
+		arg.UserID,
+		arg.OptionID,
+		arg.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createPoll = `-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+RETURNING chirp_id, created_at, closes_at
+`
+
+type CreatePollParams struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+func (q *Queries) CreatePoll(ctx context.Context, arg CreatePollParams) (Poll, error) {
+	row := q.db.QueryRowContext(ctx, createPoll, arg.ChirpID, arg.CreatedAt, arg.ClosesAt)
+	var i Poll
+	err := row.Scan(&i.ChirpID, &i.CreatedAt, &i.ClosesAt)
+	return i, err
+}
+
+const createPollOption = `-- name: CreatePollOption :one
+INSERT INTO poll_options (id, chirp_id, position, label)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, chirp_id, position, label
+`
+
+type CreatePollOptionParams struct {
+	ID       uuid.UUID
+	ChirpID  uuid.UUID
+	Position int32
+	Label    string
+}
+
+func (q *Queries) CreatePollOption(ctx context.Context, arg CreatePollOptionParams) (PollOption, error) {
+	row := q.db.QueryRowContext(ctx, createPollOption,
+		arg.ID,
+		arg.ChirpID,
+		arg.Position,
+		arg.Label,
+	)
+	var i PollOption
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.Position,
+		&i.Label,
+	)
+	return i, err
+}
+
+const getPoll = `-- name: GetPoll :one
+SELECT chirp_id, created_at, closes_at
+FROM polls
+WHERE chirp_id = $1
+`
+
+func (q *Queries) GetPoll(ctx context.Context, chirpID uuid.UUID) (Poll, error) {
+	row := q.db.QueryRowContext(ctx, getPoll, chirpID)
+	var i Poll
+	err := row.Scan(&i.ChirpID, &i.CreatedAt, &i.ClosesAt)
+	return i, err
+}
+
+const getPollOptionTallies = `-- name: GetPollOptionTallies :many
+SELECT o.id, o.chirp_id, o.position, o.label, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+WHERE o.chirp_id = ANY($1::uuid[])
+GROUP BY o.id
+ORDER BY o.chirp_id, o.position
+`
+
+type GetPollOptionTalliesRow struct {
+	ID       uuid.UUID
+	ChirpID  uuid.UUID
+	Position int32
+	Label    string
+	Votes    int64
+}
+
+func (q *Queries) GetPollOptionTallies(ctx context.Context, chirpIds []uuid.UUID) ([]GetPollOptionTalliesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPollOptionTallies, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPollOptionTalliesRow
+	for rows.Next() {
+		var i GetPollOptionTalliesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Position,
+			&i.Label,
+			&i.Votes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPollsForChirps = `-- name: GetPollsForChirps :many
+SELECT chirp_id, created_at, closes_at
+FROM polls
+WHERE chirp_id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetPollsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]Poll, error) {
+	rows, err := q.db.QueryContext(ctx, getPollsForChirps, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Poll
+	for rows.Next() {
+		var i Poll
+		if err := rows.Scan(&i.ChirpID, &i.CreatedAt, &i.ClosesAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVotesByUser = `-- name: GetVotesByUser :many
+SELECT chirp_id, user_id, option_id, created_at
+FROM poll_votes
+WHERE user_id = $1 AND chirp_id = ANY($2::uuid[])
+`
+
+type GetVotesByUserParams struct {
+	UserID   uuid.UUID
+	ChirpIds []uuid.UUID
+}
+
+func (q *Queries) GetVotesByUser(ctx context.Context, arg GetVotesByUserParams) ([]PollVote, error) {
+	rows, err := q.db.QueryContext(ctx, getVotesByUser, arg.UserID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PollVote
+	for rows.Next() {
+		var i PollVote
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.UserID,
+			&i.OptionID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
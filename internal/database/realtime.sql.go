@@ -0,0 +1,20 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: realtime.sql
+
+package database
+
+import (
+	"context"
+)
+
+const notifyRealtime = `-- name: NotifyRealtime :exec
+SELECT pg_notify('realtime', $1::text)
+`
+
+// relays a websocket message to every instance, payloads are limited to 8000 bytes
+func (q *Queries) NotifyRealtime(ctx context.Context, payload string) error {
+	_, err := q.db.ExecContext(ctx, notifyRealtime, payload)
+	return err
+}
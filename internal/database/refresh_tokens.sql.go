@@ -91,3 +91,19 @@ func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) error
 	_, err := q.db.ExecContext(ctx, revokeToken, arg.Token, arg.UpdatedAt)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = $2, revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokensParams struct {
+	UserID    uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, arg.UserID, arg.UpdatedAt)
+	return err
+}
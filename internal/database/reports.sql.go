@@ -0,0 +1,391 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: reports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimReport = `-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed', claimed_by = $1, updated_at = $2
+WHERE id = $3 AND status = 'open'
+RETURNING id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details, status, claimed_by, resolution, closed_at
+`
+
+type ClaimReportParams struct {
+	ModeratorID uuid.NullUUID
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) ClaimReport(ctx context.Context, arg ClaimReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, claimReport, arg.ModeratorID, arg.UpdatedAt, arg.ID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.TargetUserID,
+		&i.TargetChirpID,
+		&i.ChirpBody,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.Resolution,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const closeReport = `-- name: CloseReport :one
+UPDATE reports
+SET status = $1,
+    resolution = $2,
+    claimed_by = $3::uuid,
+    closed_at = $4::timestamp,
+    updated_at = $4
+WHERE id = $5
+    AND (status = 'open' OR (status = 'claimed' AND claimed_by = $3::uuid))
+RETURNING id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details, status, claimed_by, resolution, closed_at
+`
+
+type CloseReportParams struct {
+	Status      string
+	Resolution  sql.NullString
+	ModeratorID uuid.UUID
+	ClosedAt    time.Time
+	ID          uuid.UUID
+}
+
+// a claimed report can only be closed by the moderator who claimed it
+func (q *Queries) CloseReport(ctx context.Context, arg CloseReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, closeReport,
+		arg.Status,
+		arg.Resolution,
+		arg.ModeratorID,
+		arg.ClosedAt,
+		arg.ID,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.TargetUserID,
+		&i.TargetChirpID,
+		&i.ChirpBody,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.Resolution,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details, status, claimed_by, resolution, closed_at
+`
+
+type CreateReportParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ReporterID    uuid.UUID
+	TargetUserID  uuid.UUID
+	TargetChirpID uuid.NullUUID
+	ChirpBody     sql.NullString
+	Reason        string
+	Details       string
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.ReporterID,
+		arg.TargetUserID,
+		arg.TargetChirpID,
+		arg.ChirpBody,
+		arg.Reason,
+		arg.Details,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.TargetUserID,
+		&i.TargetChirpID,
+		&i.ChirpBody,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.Resolution,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getModerationActions = `-- name: GetModerationActions :many
+SELECT id, created_at, moderator_id, report_id, action, target_user_id, target_chirp_id, note
+FROM moderation_actions
+WHERE (
+        $1::timestamp IS NULL
+        OR (NOT $2::boolean AND (created_at, id) < ($1::timestamp, $3::uuid))
+        OR ($2::boolean AND (created_at, id) > ($1::timestamp, $3::uuid))
+    )
+ORDER BY
+    CASE WHEN $2::boolean THEN created_at END ASC,
+    CASE WHEN $2::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetModerationActionsParams struct {
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetModerationActions(ctx context.Context, arg GetModerationActionsParams) ([]ModerationAction, error) {
+	rows, err := q.db.QueryContext(ctx, getModerationActions,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationAction
+	for rows.Next() {
+		var i ModerationAction
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ModeratorID,
+			&i.ReportID,
+			&i.Action,
+			&i.TargetUserID,
+			&i.TargetChirpID,
+			&i.Note,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReport = `-- name: GetReport :one
+SELECT id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details, status, claimed_by, resolution, closed_at
+FROM reports
+WHERE id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.TargetUserID,
+		&i.TargetChirpID,
+		&i.ChirpBody,
+		&i.Reason,
+		&i.Details,
+		&i.Status,
+		&i.ClaimedBy,
+		&i.Resolution,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getReportQueue = `-- name: GetReportQueue :many
+SELECT id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details, status, claimed_by, resolution, closed_at
+FROM reports
+WHERE status = ANY($1::text[])
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, id) > ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, id) < ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END DESC,
+    CASE WHEN $3::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT $5
+`
+
+type GetReportQueueParams struct {
+	Statuses   []string
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetReportQueue(ctx context.Context, arg GetReportQueueParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, getReportQueue,
+		pq.Array(arg.Statuses),
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.TargetUserID,
+			&i.TargetChirpID,
+			&i.ChirpBody,
+			&i.Reason,
+			&i.Details,
+			&i.Status,
+			&i.ClaimedBy,
+			&i.Resolution,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const logModerationAction = `-- name: LogModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, action, target_user_id, target_chirp_id, note)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+`
+
+type LogModerationActionParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ModeratorID   uuid.NullUUID
+	ReportID      uuid.NullUUID
+	Action        string
+	TargetUserID  uuid.NullUUID
+	TargetChirpID uuid.NullUUID
+	Note          string
+}
+
+func (q *Queries) LogModerationAction(ctx context.Context, arg LogModerationActionParams) error {
+	_, err := q.db.ExecContext(ctx, logModerationAction,
+		arg.ID,
+		arg.CreatedAt,
+		arg.ModeratorID,
+		arg.ReportID,
+		arg.Action,
+		arg.TargetUserID,
+		arg.TargetChirpID,
+		arg.Note,
+	)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = $1::timestamp,
+    suspended_until = $2,
+    suspension_reason = $3::text,
+    updated_at = $1
+WHERE id = $4
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type SuspendUserParams struct {
+	SuspendedAt    time.Time
+	SuspendedUntil sql.NullTime
+	Reason         string
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser,
+		arg.SuspendedAt,
+		arg.SuspendedUntil,
+		arg.Reason,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,297 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: scheduled_chirps.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const cancelPendingScheduledChirp = `-- name: CancelPendingScheduledChirp :execrows
+UPDATE scheduled_chirps
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled')
+`
+
+type CancelPendingScheduledChirpParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) CancelPendingScheduledChirp(ctx context.Context, arg CancelPendingScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelPendingScheduledChirp, arg.ID, arg.UserID, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimDueScheduledChirp = `-- name: ClaimDueScheduledChirp :one
+SELECT id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, chirp_id, last_error, visibility
+FROM scheduled_chirps
+WHERE status = 'scheduled' AND publish_at <= $1
+ORDER BY publish_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) ClaimDueScheduledChirp(ctx context.Context, publishAt sql.NullTime) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, claimDueScheduledChirp, publishAt)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.ReplyToID,
+		&i.PublishAt,
+		&i.Status,
+		&i.ChirpID,
+		&i.LastError,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, visibility)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, chirp_id, last_error, visibility
+`
+
+type CreateScheduledChirpParams struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Body       string
+	ReplyToID  uuid.NullUUID
+	PublishAt  sql.NullTime
+	Status     string
+	Visibility string
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Body,
+		arg.ReplyToID,
+		arg.PublishAt,
+		arg.Status,
+		arg.Visibility,
+	)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.ReplyToID,
+		&i.PublishAt,
+		&i.Status,
+		&i.ChirpID,
+		&i.LastError,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getScheduledChirpForUser = `-- name: GetScheduledChirpForUser :one
+SELECT id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, chirp_id, last_error, visibility
+FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2
+`
+
+type GetScheduledChirpForUserParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetScheduledChirpForUser(ctx context.Context, arg GetScheduledChirpForUserParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledChirpForUser, arg.ID, arg.UserID)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.ReplyToID,
+		&i.PublishAt,
+		&i.Status,
+		&i.ChirpID,
+		&i.LastError,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getScheduledChirpsByUser = `-- name: GetScheduledChirpsByUser :many
+SELECT id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, chirp_id, last_error, visibility
+FROM scheduled_chirps
+WHERE user_id = $1
+    AND ($2::text IS NULL OR status = $2::text)
+    AND (
+        $3::timestamp IS NULL
+        OR (NOT $4::boolean AND (created_at, id) < ($3::timestamp, $5::uuid))
+        OR ($4::boolean AND (created_at, id) > ($3::timestamp, $5::uuid))
+    )
+ORDER BY
+    CASE WHEN $4::boolean THEN created_at END ASC,
+    CASE WHEN $4::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $6
+`
+
+type GetScheduledChirpsByUserParams struct {
+	UserID     uuid.UUID
+	Status     sql.NullString
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetScheduledChirpsByUser(ctx context.Context, arg GetScheduledChirpsByUserParams) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledChirpsByUser,
+		arg.UserID,
+		arg.Status,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.ReplyToID,
+			&i.PublishAt,
+			&i.Status,
+			&i.ChirpID,
+			&i.LastError,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markScheduledChirpFailed = `-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET status = 'failed', last_error = $1::text, updated_at = $2
+WHERE id = $3 AND status = 'scheduled'
+`
+
+type MarkScheduledChirpFailedParams struct {
+	LastError string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) MarkScheduledChirpFailed(ctx context.Context, arg MarkScheduledChirpFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markScheduledChirpFailed, arg.LastError, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const markScheduledChirpPublished = `-- name: MarkScheduledChirpPublished :exec
+UPDATE scheduled_chirps
+SET status = 'published', chirp_id = $1::uuid, updated_at = $2, last_error = NULL
+WHERE id = $3
+`
+
+type MarkScheduledChirpPublishedParams struct {
+	ChirpID   uuid.UUID
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) MarkScheduledChirpPublished(ctx context.Context, arg MarkScheduledChirpPublishedParams) error {
+	_, err := q.db.ExecContext(ctx, markScheduledChirpPublished, arg.ChirpID, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const updatePendingScheduledChirp = `-- name: UpdatePendingScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $1, reply_to_id = $2, publish_at = $3, status = $4, visibility = $5, updated_at = $6
+WHERE id = $7 AND user_id = $8 AND status IN ('draft', 'scheduled')
+RETURNING id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, chirp_id, last_error, visibility
+`
+
+type UpdatePendingScheduledChirpParams struct {
+	Body       string
+	ReplyToID  uuid.NullUUID
+	PublishAt  sql.NullTime
+	Status     string
+	Visibility string
+	UpdatedAt  time.Time
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) UpdatePendingScheduledChirp(ctx context.Context, arg UpdatePendingScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, updatePendingScheduledChirp,
+		arg.Body,
+		arg.ReplyToID,
+		arg.PublishAt,
+		arg.Status,
+		arg.Visibility,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.ReplyToID,
+		&i.PublishAt,
+		&i.Status,
+		&i.ChirpID,
+		&i.LastError,
+		&i.Visibility,
+	)
+	return i, err
+}
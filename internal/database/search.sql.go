@@ -0,0 +1,140 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: search.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.reply_to_id, chirps.visibility, chirps.deleted_at, chirps.language, chirps.media_urls, chirps.held_for_review, chirps.removed_by_moderator,
+    ts_rank_cd(chirp_search_vector(chirps.language, chirps.body), to_tsquery($1::text::regconfig, $2::text))::real AS rank,
+    -- matches are marked with private use characters the body can't contain, so the body can be
+    -- escaped before the marks become <mark> tags
+    ts_headline(chirps.language::regconfig, translate(chirps.body, E'\uE000\uE001', ''), to_tsquery($1::text::regconfig, $2::text),
+        E'StartSel="\uE000", StopSel="\uE001", MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+FROM chirps
+WHERE chirps.deleted_at IS NULL
+    AND (
+        $2::text = ''
+        OR chirp_search_vector(chirps.language, chirps.body) @@ to_tsquery($1::text::regconfig, $2::text)
+    )
+    AND ($3::uuid IS NULL OR chirps.user_id = $3::uuid)
+    AND ($4::timestamp IS NULL OR chirps.created_at >= $4::timestamp)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT $5
+`
+
+type SearchChirpsParams struct {
+	Language string
+	Query    string
+	AuthorID uuid.NullUUID
+	Since    sql.NullTime
+	PageSize int32
+}
+
+type SearchChirpsRow struct {
+	Chirp   Chirp
+	Rank    float32
+	Snippet string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Language,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ReplyToID,
+			&i.Chirp.Visibility,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.Language,
+			pq.Array(&i.Chirp.MediaUrls),
+			&i.Chirp.HeldForReview,
+			&i.Chirp.RemovedByModerator,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, handle, display_name,
+    ts_rank_cd(user_search_vector(handle, display_name), to_tsquery('simple', $1::text))::real AS rank
+FROM users
+WHERE user_search_vector(handle, display_name) @@ to_tsquery('simple', $1::text)
+ORDER BY rank DESC, handle ASC
+LIMIT $2
+`
+
+type SearchUsersParams struct {
+	Query    string
+	PageSize int32
+}
+
+type SearchUsersRow struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	Rank        float32
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.Query, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersRow
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
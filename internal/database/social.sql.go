@@ -0,0 +1,387 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: social.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const approveAllFollowRequests = `-- name: ApproveAllFollowRequests :many
+UPDATE follows
+SET status = 'accepted'
+WHERE followee_id = $1 AND status = 'pending'
+RETURNING follower_id, followee_id, created_at, status
+`
+
+func (q *Queries) ApproveAllFollowRequests(ctx context.Context, followeeID uuid.UUID) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, approveAllFollowRequests, followeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(
+			&i.FollowerID,
+			&i.FolloweeID,
+			&i.CreatedAt,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const approveFollowRequest = `-- name: ApproveFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted'
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+`
+
+type ApproveFollowRequestParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) ApproveFollowRequest(ctx context.Context, arg ApproveFollowRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, approveFollowRequest, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const blockUser = `-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type BlockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) BlockUser(ctx context.Context, arg BlockUserParams) error {
+	_, err := q.db.ExecContext(ctx, blockUser, arg.BlockerID, arg.BlockedID, arg.CreatedAt)
+	return err
+}
+
+const createRechirp = `-- name: CreateRechirp :execrows
+INSERT INTO rechirps (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateRechirpParams struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createRechirp, arg.UserID, arg.ChirpID, arg.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+DELETE FROM rechirps
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type DeleteRechirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const followUser = `-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at, status)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT DO NOTHING
+`
+
+type FollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+	Status     string
+}
+
+func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, followUser,
+		arg.FollowerID,
+		arg.FolloweeID,
+		arg.CreatedAt,
+		arg.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFollowedAmong = `-- name: GetFollowedAmong :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = $1
+    AND status = 'accepted'
+    AND followee_id = ANY($2::uuid[])
+`
+
+type GetFollowedAmongParams struct {
+	FollowerID  uuid.UUID
+	FolloweeIds []uuid.UUID
+}
+
+func (q *Queries) GetFollowedAmong(ctx context.Context, arg GetFollowedAmongParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedAmong, arg.FollowerID, pq.Array(arg.FolloweeIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var followee_id uuid.UUID
+		if err := rows.Scan(&followee_id); err != nil {
+			return nil, err
+		}
+		items = append(items, followee_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPendingFollowRequests = `-- name: GetPendingFollowRequests :many
+SELECT follower_id, followee_id, created_at, status
+FROM follows
+WHERE followee_id = $1 AND status = 'pending'
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, follower_id) > ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, follower_id) < ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END DESC,
+    CASE WHEN $3::boolean THEN follower_id END DESC,
+    created_at ASC, follower_id ASC
+LIMIT $5
+`
+
+type GetPendingFollowRequestsParams struct {
+	FolloweeID uuid.UUID
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetPendingFollowRequests(ctx context.Context, arg GetPendingFollowRequestsParams) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, getPendingFollowRequests,
+		arg.FolloweeID,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(
+			&i.FollowerID,
+			&i.FolloweeID,
+			&i.CreatedAt,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isBlockedEitherWay = `-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = ANY($2::uuid[]))
+        OR (blocked_id = $1 AND blocker_id = ANY($2::uuid[]))
+)
+`
+
+type IsBlockedEitherWayParams struct {
+	UserID   uuid.UUID
+	OtherIds []uuid.UUID
+}
+
+func (q *Queries) IsBlockedEitherWay(ctx context.Context, arg IsBlockedEitherWayParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlockedEitherWay, arg.UserID, pq.Array(arg.OtherIds))
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const likeChirp = `-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING
+`
+
+type LikeChirpParams struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.UserID, arg.ChirpID, arg.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rejectFollowRequest = `-- name: RejectFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+`
+
+type RejectFollowRequestParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) RejectFollowRequest(ctx context.Context, arg RejectFollowRequestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rejectFollowRequest, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeFollowsBetween = `-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followee_id = $2)
+    OR (follower_id = $2 AND followee_id = $1)
+`
+
+type RemoveFollowsBetweenParams struct {
+	UserA uuid.UUID
+	UserB uuid.UUID
+}
+
+func (q *Queries) RemoveFollowsBetween(ctx context.Context, arg RemoveFollowsBetweenParams) error {
+	_, err := q.db.ExecContext(ctx, removeFollowsBetween, arg.UserA, arg.UserB)
+	return err
+}
+
+const unblockUser = `-- name: UnblockUser :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2
+`
+
+type UnblockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) UnblockUser(ctx context.Context, arg UnblockUserParams) error {
+	_, err := q.db.ExecContext(ctx, unblockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
+
+const unfollowUser = `-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2
+`
+
+type UnfollowUserParams struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+}
+
+func (q *Queries) UnfollowUser(ctx context.Context, arg UnfollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unfollowUser, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unlikeChirp = `-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type UnlikeChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlikeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
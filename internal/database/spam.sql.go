@@ -0,0 +1,271 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: spam.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countChirpsByAuthorSince = `-- name: CountChirpsByAuthorSince :one
+SELECT COUNT(*)
+FROM chirps
+WHERE user_id = $1 AND created_at >= $2::timestamp
+`
+
+type CountChirpsByAuthorSinceParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+func (q *Queries) CountChirpsByAuthorSince(ctx context.Context, arg CountChirpsByAuthorSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByAuthorSince, arg.UserID, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getHeldChirps = `-- name: GetHeldChirps :many
+SELECT id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
+FROM chirps
+WHERE held_for_review AND deleted_at IS NULL
+    AND (
+        $1::timestamp IS NULL
+        OR (NOT $2::boolean AND (created_at, id) > ($1::timestamp, $3::uuid))
+        OR ($2::boolean AND (created_at, id) < ($1::timestamp, $3::uuid))
+    )
+ORDER BY
+    CASE WHEN $2::boolean THEN created_at END DESC,
+    CASE WHEN $2::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetHeldChirpsParams struct {
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetHeldChirps(ctx context.Context, arg GetHeldChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getHeldChirps,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.Language,
+			pq.Array(&i.MediaUrls),
+			&i.HeldForReview,
+			&i.RemovedByModerator,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentSpamHashes = `-- name: GetRecentSpamHashes :many
+SELECT user_id, simhash
+FROM spam_decisions
+WHERE created_at >= $1::timestamp
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetRecentSpamHashesParams struct {
+	Since     time.Time
+	MaxHashes int32
+}
+
+type GetRecentSpamHashesRow struct {
+	UserID  uuid.UUID
+	Simhash int64
+}
+
+func (q *Queries) GetRecentSpamHashes(ctx context.Context, arg GetRecentSpamHashesParams) ([]GetRecentSpamHashesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentSpamHashes, arg.Since, arg.MaxHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRecentSpamHashesRow
+	for rows.Next() {
+		var i GetRecentSpamHashesRow
+		if err := rows.Scan(&i.UserID, &i.Simhash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSpamDecisions = `-- name: GetSpamDecisions :many
+SELECT id, created_at, user_id, chirp_id, body, simhash, score, verdict, hits
+FROM spam_decisions
+WHERE ($1::text IS NULL OR verdict = $1::text)
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (created_at, id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (created_at, id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN created_at END ASC,
+    CASE WHEN $3::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT $5
+`
+
+type GetSpamDecisionsParams struct {
+	Verdict    sql.NullString
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetSpamDecisions(ctx context.Context, arg GetSpamDecisionsParams) ([]SpamDecision, error) {
+	rows, err := q.db.QueryContext(ctx, getSpamDecisions,
+		arg.Verdict,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SpamDecision
+	for rows.Next() {
+		var i SpamDecision
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.ChirpID,
+			&i.Body,
+			&i.Simhash,
+			&i.Score,
+			&i.Verdict,
+			&i.Hits,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordSpamDecision = `-- name: RecordSpamDecision :exec
+INSERT INTO spam_decisions (id, created_at, user_id, chirp_id, body, simhash, score, verdict, hits)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+`
+
+type RecordSpamDecisionParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ChirpID   uuid.NullUUID
+	Body      string
+	Simhash   int64
+	Score     float64
+	Verdict   string
+	Hits      json.RawMessage
+}
+
+func (q *Queries) RecordSpamDecision(ctx context.Context, arg RecordSpamDecisionParams) error {
+	_, err := q.db.ExecContext(ctx, recordSpamDecision,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.ChirpID,
+		arg.Body,
+		arg.Simhash,
+		arg.Score,
+		arg.Verdict,
+		arg.Hits,
+	)
+	return err
+}
+
+const releaseHeldChirp = `-- name: ReleaseHeldChirp :one
+UPDATE chirps
+SET held_for_review = false
+WHERE id = $1 AND held_for_review AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id, visibility, deleted_at, language, media_urls, held_for_review, removed_by_moderator
+`
+
+func (q *Queries) ReleaseHeldChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, releaseHeldChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.Language,
+		pq.Array(&i.MediaUrls),
+		&i.HeldForReview,
+		&i.RemovedByModerator,
+	)
+	return i, err
+}
@@ -0,0 +1,158 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: subscriptions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const expireSubscriptions = `-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = $1::timestamp
+WHERE status <> 'expired' AND expires_at <= $1::timestamp
+RETURNING user_id
+`
+
+func (q *Queries) ExpireSubscriptions(ctx context.Context, now time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireSubscriptions, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSubscriptionByUser = `-- name: GetSubscriptionByUser :one
+SELECT id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at, canceled_at
+FROM subscriptions
+WHERE user_id = $1
+`
+
+func (q *Queries) GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionByUser, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Tier,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.ExpiresAt,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const lockSubscriptionByUser = `-- name: LockSubscriptionByUser :one
+SELECT id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at, canceled_at
+FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockSubscriptionByUser(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, lockSubscriptionByUser, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Tier,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.ExpiresAt,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const saveSubscription = `-- name: SaveSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at, canceled_at)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+ON CONFLICT (user_id) DO UPDATE
+SET tier = EXCLUDED.tier,
+    status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    expires_at = EXCLUDED.expires_at,
+    canceled_at = EXCLUDED.canceled_at,
+    updated_at = EXCLUDED.updated_at
+RETURNING id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at, canceled_at
+`
+
+type SaveSubscriptionParams struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UserID             uuid.UUID
+	Tier               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	ExpiresAt          time.Time
+	CanceledAt         sql.NullTime
+}
+
+func (q *Queries) SaveSubscription(ctx context.Context, arg SaveSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, saveSubscription,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Tier,
+		arg.Status,
+		arg.CurrentPeriodStart,
+		arg.CurrentPeriodEnd,
+		arg.ExpiresAt,
+		arg.CanceledAt,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Tier,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.ExpiresAt,
+		&i.CanceledAt,
+	)
+	return i, err
+}
@@ -7,9 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -20,7 +22,7 @@ VALUES (
     $3,
     $4
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
 `
 
 type CreateUserParams struct {
@@ -44,21 +46,29 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
 const createUserWithPassWord = `-- name: CreateUserWithPassWord :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
 `
 
 type CreateUserWithPassWordParams struct {
@@ -67,6 +77,7 @@ type CreateUserWithPassWordParams struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUserWithPassWord(ctx context.Context, arg CreateUserWithPassWordParams) (User, error) {
@@ -76,6 +87,7 @@ func (q *Queries) CreateUserWithPassWord(ctx context.Context, arg CreateUserWith
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -84,7 +96,14 @@ func (q *Queries) CreateUserWithPassWord(ctx context.Context, arg CreateUserWith
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -98,8 +117,66 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const getProtectedAmong = `-- name: GetProtectedAmong :many
+SELECT id
+FROM users
+WHERE is_protected AND id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetProtectedAmong(ctx context.Context, userIds []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getProtectedAmong, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getShadowBannedAmong = `-- name: GetShadowBannedAmong :many
+SELECT id
+FROM users
+WHERE shadow_banned_at IS NOT NULL AND id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetShadowBannedAmong(ctx context.Context, userIds []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getShadowBannedAmong, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
 FROM users
 WHERE email = $1
 `
@@ -113,13 +190,20 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
 FROM users
 WHERE id = $1
 `
@@ -133,7 +217,257 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const getUserSuspension = `-- name: GetUserSuspension :one
+SELECT suspended_at, suspended_until, suspension_reason
+FROM users
+WHERE id = $1
+`
+
+type GetUserSuspensionRow struct {
+	SuspendedAt      sql.NullTime
+	SuspendedUntil   sql.NullTime
+	SuspensionReason sql.NullString
+}
+
+func (q *Queries) GetUserSuspension(ctx context.Context, id uuid.UUID) (GetUserSuspensionRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserSuspension, id)
+	var i GetUserSuspensionRow
+	err := row.Scan(&i.SuspendedAt, &i.SuspendedUntil, &i.SuspensionReason)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+FROM users
+WHERE handle = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Handle,
+			&i.IsProtected,
+			&i.DisplayName,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspendedUntil,
+			&i.SuspensionReason,
+			&i.ShadowBannedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const liftSuspension = `-- name: LiftSuspension :one
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = $1
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type LiftSuspensionParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) LiftSuspension(ctx context.Context, arg LiftSuspensionParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, liftSuspension, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const lockUser = `-- name: LockUser :exec
+SELECT id
+FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
+const setShadowBan = `-- name: SetShadowBan :one
+UPDATE users
+SET shadow_banned_at = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type SetShadowBanParams struct {
+	ShadowBannedAt sql.NullTime
+	UpdatedAt      time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) SetShadowBan(ctx context.Context, arg SetShadowBanParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setShadowBan, arg.ShadowBannedAt, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const setUserDisplayName = `-- name: SetUserDisplayName :one
+UPDATE users
+SET display_name = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type SetUserDisplayNameParams struct {
+	DisplayName sql.NullString
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserDisplayName(ctx context.Context, arg SetUserDisplayNameParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDisplayName, arg.DisplayName, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const setUserHandle = `-- name: SetUserHandle :one
+UPDATE users
+SET handle = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type SetUserHandleParams struct {
+	Handle    sql.NullString
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserHandle(ctx context.Context, arg SetUserHandleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserHandle, arg.Handle, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const setUserProtected = `-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
+`
+
+type SetUserProtectedParams struct {
+	IsProtected bool
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserProtected(ctx context.Context, arg SetUserProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserProtected, arg.IsProtected, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -142,7 +476,7 @@ const updateUserLogin = `-- name: UpdateUserLogin :one
 UPDATE users
 SET  email = $1, hashed_password = $2, updated_at = $4
 where id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, is_protected, display_name, role, suspended_at, suspended_until, suspension_reason, shadow_banned_at
 `
 
 type UpdateUserLoginParams struct {
@@ -166,18 +500,14 @@ func (q *Queries) UpdateUserLogin(ctx context.Context, arg UpdateUserLoginParams
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.IsProtected,
+		&i.DisplayName,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
-
-const upgradeChirpyRed = `-- name: UpgradeChirpyRed :exec
-UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1
-`
-
-func (q *Queries) UpgradeChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeChirpyRed, id)
-	return err
-}
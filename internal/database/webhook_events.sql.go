@@ -0,0 +1,218 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const claimWebhookEvent = `-- name: ClaimWebhookEvent :one
+SELECT id, received_at, updated_at, provider, event_id, event_type, payload, status, attempts, last_error, processed_at
+FROM webhook_events
+WHERE id = $1 AND status IN ('pending', 'failed')
+FOR UPDATE SKIP LOCKED
+`
+
+// locks an event that still needs processing, concurrent deliveries of the same event skip it
+func (q *Queries) ClaimWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.ReceivedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const completeWebhookEvent = `-- name: CompleteWebhookEvent :exec
+UPDATE webhook_events
+SET status = $1, attempts = attempts + 1, last_error = NULL, processed_at = $2, updated_at = $2
+WHERE id = $3
+`
+
+type CompleteWebhookEventParams struct {
+	Status      string
+	ProcessedAt sql.NullTime
+	ID          uuid.UUID
+}
+
+func (q *Queries) CompleteWebhookEvent(ctx context.Context, arg CompleteWebhookEventParams) error {
+	_, err := q.db.ExecContext(ctx, completeWebhookEvent, arg.Status, arg.ProcessedAt, arg.ID)
+	return err
+}
+
+const failWebhookEvent = `-- name: FailWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'failed', attempts = attempts + 1, last_error = $1, updated_at = $2
+WHERE id = $3
+`
+
+type FailWebhookEventParams struct {
+	LastError sql.NullString
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) FailWebhookEvent(ctx context.Context, arg FailWebhookEventParams) error {
+	_, err := q.db.ExecContext(ctx, failWebhookEvent, arg.LastError, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, received_at, updated_at, provider, event_id, event_type, payload, status, attempts, last_error, processed_at
+FROM webhook_events
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.ReceivedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
+
+const getWebhookEvents = `-- name: GetWebhookEvents :many
+SELECT id, received_at, updated_at, provider, event_id, event_type, payload, status, attempts, last_error, processed_at
+FROM webhook_events
+WHERE ($1::text IS NULL OR status = $1::text)
+    AND (
+        $2::timestamp IS NULL
+        OR (NOT $3::boolean AND (received_at, id) < ($2::timestamp, $4::uuid))
+        OR ($3::boolean AND (received_at, id) > ($2::timestamp, $4::uuid))
+    )
+ORDER BY
+    CASE WHEN $3::boolean THEN received_at END ASC,
+    CASE WHEN $3::boolean THEN id END ASC,
+    received_at DESC, id DESC
+LIMIT $5
+`
+
+type GetWebhookEventsParams struct {
+	Status     sql.NullString
+	CursorTime sql.NullTime
+	Backward   bool
+	CursorID   uuid.NullUUID
+	PageSize   int32
+}
+
+func (q *Queries) GetWebhookEvents(ctx context.Context, arg GetWebhookEventsParams) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookEvents,
+		arg.Status,
+		arg.CursorTime,
+		arg.Backward,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReceivedAt,
+			&i.UpdatedAt,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.ProcessedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookEvent = `-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, received_at, updated_at, provider, event_id, event_type, payload)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (provider, event_id) DO UPDATE
+SET updated_at = webhook_events.updated_at
+RETURNING id, received_at, updated_at, provider, event_id, event_type, payload, status, attempts, last_error, processed_at
+`
+
+type RecordWebhookEventParams struct {
+	ID         uuid.UUID
+	ReceivedAt time.Time
+	Provider   string
+	EventID    string
+	EventType  string
+	Payload    json.RawMessage
+}
+
+// a redelivered event returns the row stored the first time
+func (q *Queries) RecordWebhookEvent(ctx context.Context, arg RecordWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookEvent,
+		arg.ID,
+		arg.ReceivedAt,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.ReceivedAt,
+		&i.UpdatedAt,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ProcessedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,105 @@
+// Package entitlements, decides what each subscription tier is allowed to do
+package entitlements
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/chirptext"
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+// Entitlements are the capabilities and limits of one tier
+type Entitlements struct {
+	Tier           subscription.Tier
+	MaxChirpLength int
+	// EditWindow is how long after posting a chirp can be edited, zero means chirps can't be edited
+	EditWindow      time.Duration
+	MaxMedia        int
+	MaxPinnedChirps int
+	ChirpsPerHour   int
+}
+
+// CanEdit reports whether a chirp posted at createdAt can still be edited at now
+func (e Entitlements) CanEdit(createdAt, now time.Time) bool {
+	return e.EditWindow > 0 && now.Sub(createdAt) < e.EditWindow
+}
+
+// MarshalJSON writes the edit window in seconds so clients don't need to parse go durations
+func (e Entitlements) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Tier              subscription.Tier `json:"tier"`
+		MaxChirpLength    int               `json:"max_chirp_length"`
+		EditWindowSeconds int               `json:"edit_window_seconds"`
+		MaxMedia          int               `json:"max_media"`
+		MaxPinnedChirps   int               `json:"max_pinned_chirps"`
+		ChirpsPerHour     int               `json:"chirps_per_hour"`
+	}{
+		Tier:              e.Tier,
+		MaxChirpLength:    e.MaxChirpLength,
+		EditWindowSeconds: int(e.EditWindow / time.Second),
+		MaxMedia:          e.MaxMedia,
+		MaxPinnedChirps:   e.MaxPinnedChirps,
+		ChirpsPerHour:     e.ChirpsPerHour,
+	})
+}
+
+// Plans maps each tier to its entitlements
+type Plans map[subscription.Tier]Entitlements
+
+// DefaultPlans are the free and Chirpy Red tiers
+var DefaultPlans = Plans{
+	subscription.TierFree: {
+		Tier:            subscription.TierFree,
+		MaxChirpLength:  chirptext.StandardMaxLength,
+		MaxMedia:        4,
+		MaxPinnedChirps: 3,
+		ChirpsPerHour:   60,
+	},
+	subscription.TierRed: {
+		Tier:            subscription.TierRed,
+		MaxChirpLength:  chirptext.ChirpyRedMaxLength,
+		EditWindow:      30 * time.Minute,
+		MaxMedia:        10,
+		MaxPinnedChirps: 10,
+		ChirpsPerHour:   300,
+	},
+}
+
+// For is tier's entitlements, a tier without a plan gets the free plan
+func (p Plans) For(tier subscription.Tier) Entitlements {
+	if e, ok := p[tier]; ok {
+		return e
+	}
+	return p[subscription.TierFree]
+}
+
+// TierSource looks up the tier a user's perks currently come from
+type TierSource interface {
+	Tier(ctx context.Context, userID uuid.UUID) (subscription.Tier, error)
+}
+
+// Resolver is how the rest of the server asks what a user may do
+type Resolver interface {
+	For(ctx context.Context, userID uuid.UUID) (Entitlements, error)
+}
+
+// Engine resolves users' entitlements from their tier
+type Engine struct {
+	plans Plans
+	tiers TierSource
+}
+
+func NewEngine(plans Plans, tiers TierSource) *Engine {
+	return &Engine{plans: plans, tiers: tiers}
+}
+
+func (e *Engine) For(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	tier, err := e.tiers.Tier(ctx, userID)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	return e.plans.For(tier), nil
+}
@@ -0,0 +1,77 @@
+package entitlements
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+type fixedTiers map[uuid.UUID]subscription.Tier
+
+func (f fixedTiers) Tier(ctx context.Context, userID uuid.UUID) (subscription.Tier, error) {
+	if tier, ok := f[userID]; ok {
+		return tier, nil
+	}
+	return subscription.TierFree, nil
+}
+
+func TestEngine(t *testing.T) {
+
+	red := uuid.New()
+	engine := NewEngine(DefaultPlans, fixedTiers{red: subscription.TierRed})
+
+	free, err := engine.For(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := engine.For(context.Background(), red)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if free.Tier != subscription.TierFree || paid.Tier != subscription.TierRed {
+		t.Fatalf("expected free and red plans, got %v and %v", free.Tier, paid.Tier)
+	}
+	if paid.MaxChirpLength <= free.MaxChirpLength || paid.MaxPinnedChirps <= free.MaxPinnedChirps || paid.MaxMedia <= free.MaxMedia {
+		t.Error("expected Chirpy Red to raise every limit")
+	}
+	if DefaultPlans.For(subscription.Tier("platinum")).Tier != subscription.TierFree {
+		t.Error("expected unknown tiers to fall back to the free plan")
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+
+	posted := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	free := DefaultPlans.For(subscription.TierFree)
+	red := DefaultPlans.For(subscription.TierRed)
+
+	if free.CanEdit(posted, posted.Add(time.Second)) {
+		t.Error("expected free users not to edit chirps")
+	}
+	if !red.CanEdit(posted, posted.Add(red.EditWindow-time.Second)) {
+		t.Error("expected edits inside the window to be allowed")
+	}
+	if red.CanEdit(posted, posted.Add(red.EditWindow)) {
+		t.Error("expected edits once the window closes to be refused")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+
+	raw, err := json.Marshal(Entitlements{Tier: subscription.TierRed, EditWindow: 90 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string]any{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["edit_window_seconds"] != float64(90) || decoded["tier"] != "red" {
+		t.Errorf("unexpected json %s", raw)
+	}
+}
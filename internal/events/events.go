@@ -0,0 +1,80 @@
+// Package events, an in-process bus that lets handlers react to things users do
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Kind string
+
+const (
+	KindFollow  Kind = "follow"
+	KindLike    Kind = "like"
+	KindReply   Kind = "reply"
+	KindMention Kind = "mention"
+	KindRechirp Kind = "rechirp"
+
+	KindFollowRequest Kind = "follow_request"
+
+	// KindWarning is a moderator warning, it has no ActorID so moderators stay anonymous
+	KindWarning Kind = "warning"
+)
+
+// Event describes something ActorID did that concerns UserID.
+// ChirpID is uuid.Nil for events that are not about a chirp.
+type Event struct {
+	Kind       Kind
+	ActorID    uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.UUID
+	OccurredAt time.Time
+}
+
+type Handler func(ctx context.Context, event Event) error
+
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Kind][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		handlers: map[Kind][]Handler{},
+	}
+}
+
+// Subscribe registers handler to be called for every published event of the given kinds
+func (b *Bus) Subscribe(handler Handler, kinds ...Kind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, kind := range kinds {
+		b.handlers[kind] = append(b.handlers[kind], handler)
+	}
+}
+
+// Publish runs every handler subscribed to event.Kind in the order they subscribed.
+// A failing handler is logged and does not stop the others, producers never see the error.
+// Handlers keep ctx's values but not its cancellation, the change they react to has already
+// happened even if the request that made it has gone away.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	ctx = context.WithoutCancel(ctx)
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers[event.Kind]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			log.Println("event handler for ", event.Kind, " failed: ", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishDispatchesByKind(t *testing.T) {
+	bus := NewBus()
+
+	var likes, follows int
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		likes++
+		return nil
+	}, KindLike)
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		follows++
+		return nil
+	}, KindFollow, KindMention)
+
+	bus.Publish(context.Background(), Event{Kind: KindLike, ActorID: uuid.New()})
+	bus.Publish(context.Background(), Event{Kind: KindFollow, ActorID: uuid.New()})
+	bus.Publish(context.Background(), Event{Kind: KindMention, ActorID: uuid.New()})
+	bus.Publish(context.Background(), Event{Kind: KindRechirp, ActorID: uuid.New()})
+
+	if likes != 1 {
+		t.Errorf(`expected 1 like event, got %d`, likes)
+	}
+	if follows != 2 {
+		t.Errorf(`expected 2 follow/mention events, got %d`, follows)
+	}
+}
+
+func TestPublishContinuesAfterError(t *testing.T) {
+	bus := NewBus()
+
+	called := false
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		return errors.New("boom")
+	}, KindReply)
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		called = true
+		if e.OccurredAt.IsZero() {
+			t.Errorf(`OccurredAt should be filled in by Publish`)
+		}
+		return nil
+	}, KindReply)
+
+	bus.Publish(context.Background(), Event{Kind: KindReply})
+
+	if !called {
+		t.Errorf(`second handler should run after the first one fails`)
+	}
+}
+
+func TestPublishOutlivesCancelledContext(t *testing.T) {
+	bus := NewBus()
+
+	var handlerErr error
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		handlerErr = ctx.Err()
+		return nil
+	}, KindFollow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(ctx, Event{Kind: KindFollow})
+
+	if handlerErr != nil {
+		t.Errorf(`handlers should not see the producer's cancellation, got %v`, handlerErr)
+	}
+}
@@ -0,0 +1,146 @@
+// Package jobs, runs background work from a queue kept in Postgres so it survives restarts
+// and is shared by every server instance
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status is where a job is in the queue
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	// StatusDead ran out of attempts and waits for someone to retry it by hand
+	StatusDead Status = "dead"
+)
+
+const (
+	DefaultMaxAttempts = 5
+
+	firstRetry = 10 * time.Second
+	maxRetry   = time.Hour
+)
+
+// Job is a unit of work taken from the queue
+type Job struct {
+	ID      uuid.UUID
+	Kind    string
+	Payload json.RawMessage
+	// Attempts counts this one, a job's first run has Attempts 1
+	Attempts    int
+	MaxAttempts int
+}
+
+// NewJob is a job to add to the queue
+type NewJob struct {
+	Kind        string
+	Payload     json.RawMessage
+	RunAt       time.Time
+	MaxAttempts int
+	// UniqueKey, when set, makes enqueuing a second job with the same key a no-op
+	UniqueKey string
+}
+
+// New builds a job of kind that runs as soon as a worker is free, payload is encoded as json
+func New(kind string, payload any) (NewJob, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return NewJob{}, err
+	}
+	return NewJob{Kind: kind, Payload: raw, MaxAttempts: DefaultMaxAttempts}, nil
+}
+
+// Store is the queue table
+type Store interface {
+	Enqueue(ctx context.Context, job NewJob) error
+	// Claim locks the next due job of one of kinds until leaseUntil, jobs whose lease has run
+	// out are due again so a crashed worker's jobs aren't lost. ok is false when nothing is due.
+	Claim(ctx context.Context, kinds []string, now, leaseUntil time.Time) (job Job, ok bool, err error)
+	Complete(ctx context.Context, job Job, now time.Time) error
+	// Fail records err, the job runs again at retryAt unless dead
+	Fail(ctx context.Context, job Job, err error, retryAt time.Time, dead bool) error
+}
+
+// Backoff is how long a job waits after failing attempts times, doubling from 10 seconds up to an hour
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	wait := firstRetry
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxRetry {
+			return maxRetry
+		}
+	}
+	return wait
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler's error as one retrying won't fix, the job goes straight to dead
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+type handlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// Register adds the handler for jobs of kind, their payload is decoded into a T for it
+func Register[T any](r *Runner, kind string, handle func(ctx context.Context, payload T) error) {
+	r.handlers[kind] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &payload); err != nil {
+				return Permanent(fmt.Errorf("decoding %s payload: %w", kind, err))
+			}
+		}
+		return handle(ctx, payload)
+	}
+}
+
+// recurring is a job enqueued once every period, across every instance
+type recurring struct {
+	kind     string
+	every    time.Duration
+	lastSlot time.Time
+}
+
+// Every enqueues a job of kind with an empty payload once per every. Instances agree on the
+// slots, since they are aligned to the clock, and the unique key keeps them from doubling up.
+func (r *Runner) Every(kind string, every time.Duration) {
+	r.recurring = append(r.recurring, &recurring{kind: kind, every: every})
+}
+
+func (rec *recurring) due(now time.Time) (NewJob, bool) {
+	slot := now.Truncate(rec.every)
+	if slot.Equal(rec.lastSlot) {
+		return NewJob{}, false
+	}
+	rec.lastSlot = slot
+	return NewJob{
+		Kind:        rec.kind,
+		Payload:     json.RawMessage("{}"),
+		RunAt:       slot,
+		MaxAttempts: 1,
+		UniqueKey:   fmt.Sprintf("%s@%d", rec.kind, slot.Unix()),
+	}, true
+}
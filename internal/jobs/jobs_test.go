@@ -0,0 +1,244 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type storedJob struct {
+	Job
+	status    Status
+	runAt     time.Time
+	uniqueKey string
+	lastError error
+}
+
+// memoryStore is a queue that hands out due jobs oldest first
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*storedJob
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, job NewJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.jobs {
+		if job.UniqueKey != "" && existing.uniqueKey == job.UniqueKey {
+			return nil
+		}
+	}
+	s.jobs = append(s.jobs, &storedJob{
+		Job:       Job{ID: uuid.New(), Kind: job.Kind, Payload: job.Payload, MaxAttempts: job.MaxAttempts},
+		status:    StatusPending,
+		runAt:     job.RunAt,
+		uniqueKey: job.UniqueKey,
+	})
+	return nil
+}
+
+func (s *memoryStore) Claim(ctx context.Context, kinds []string, now, leaseUntil time.Time) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.status == StatusPending && !job.runAt.After(now) {
+			job.status = StatusRunning
+			job.Attempts++
+			return job.Job, true, nil
+		}
+	}
+	return Job{}, false, nil
+}
+
+func (s *memoryStore) find(id uuid.UUID) *storedJob {
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, job Job, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.find(job.ID).status = StatusCompleted
+	return nil
+}
+
+func (s *memoryStore) Fail(ctx context.Context, job Job, err error, retryAt time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.find(job.ID)
+	stored.lastError = err
+	stored.runAt = retryAt
+	stored.status = StatusPending
+	if dead {
+		stored.status = StatusDead
+	}
+	return nil
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestTypedHandler(t *testing.T) {
+
+	store := &memoryStore{}
+	runner := NewRunner(store)
+
+	got := ""
+	Register(runner, "greet", func(ctx context.Context, payload greeting) error {
+		got = payload.Name
+		return nil
+	})
+
+	job, err := New("greet", greeting{Name: "chirpy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Enqueue(context.Background(), job)
+
+	ran, err := runner.RunOnce(context.Background())
+	if err != nil || !ran {
+		t.Fatalf("expected the job to run, got %v %v", ran, err)
+	}
+	if got != "chirpy" {
+		t.Errorf("expected the payload to be decoded, got %q", got)
+	}
+	if store.jobs[0].status != StatusCompleted {
+		t.Errorf("expected the job to complete, got %s", store.jobs[0].status)
+	}
+	if ran, _ := runner.RunOnce(context.Background()); ran {
+		t.Error("expected nothing left to run")
+	}
+}
+
+func TestRetriesThenDeadLetter(t *testing.T) {
+
+	store := &memoryStore{}
+	runner := NewRunner(store)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	runner.Now = func() time.Time { return now }
+
+	calls := 0
+	Register(runner, "flaky", func(ctx context.Context, payload struct{}) error {
+		calls++
+		return errors.New("upstream unavailable")
+	})
+
+	job, _ := New("flaky", struct{}{})
+	job.MaxAttempts = 3
+	store.Enqueue(context.Background(), job)
+
+	runner.RunOnce(context.Background())
+	stored := store.jobs[0]
+	if stored.status != StatusPending || !stored.runAt.Equal(now.Add(Backoff(1))) {
+		t.Fatalf("expected a retry after %v, got %s at %v", Backoff(1), stored.status, stored.runAt)
+	}
+	if ran, _ := runner.RunOnce(context.Background()); ran {
+		t.Error("expected the retry to wait for its backoff")
+	}
+
+	for i := 0; i < 2; i++ {
+		now = stored.runAt
+		runner.RunOnce(context.Background())
+	}
+	if stored.status != StatusDead || calls != 3 {
+		t.Errorf("expected the job to be dead after 3 attempts, got %s after %d", stored.status, calls)
+	}
+}
+
+func TestPermanentErrorsAndPanics(t *testing.T) {
+
+	store := &memoryStore{}
+	runner := NewRunner(store)
+
+	Register(runner, "bad_input", func(ctx context.Context, payload struct{}) error {
+		return Permanent(errors.New("user no longer exists"))
+	})
+	Register(runner, "panics", func(ctx context.Context, payload struct{}) error {
+		panic("boom")
+	})
+
+	for _, kind := range []string{"bad_input", "panics"} {
+		job, _ := New(kind, struct{}{})
+		job.MaxAttempts = 1
+		store.Enqueue(context.Background(), job)
+		runner.RunOnce(context.Background())
+	}
+
+	if store.jobs[0].status != StatusDead {
+		t.Errorf("expected a permanent error to dead letter straight away, got %s", store.jobs[0].status)
+	}
+	if store.jobs[1].status != StatusDead || store.jobs[1].lastError == nil {
+		t.Errorf("expected a panic to fail the job, got %s", store.jobs[1].status)
+	}
+}
+
+func TestRecurringSlots(t *testing.T) {
+
+	rec := &recurring{kind: "purge", every: time.Hour}
+	now := time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)
+
+	first, due := rec.due(now)
+	if !due || !first.RunAt.Equal(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected a job for the 10:00 slot, got %v %v", due, first.RunAt)
+	}
+	if _, due := rec.due(now.Add(30 * time.Minute)); due {
+		t.Error("expected one job per slot")
+	}
+	next, due := rec.due(now.Add(time.Hour))
+	if !due || next.UniqueKey == first.UniqueKey {
+		t.Error("expected a new job with its own key in the next slot")
+	}
+
+	// a second instance computes the same key, so the store keeps one job per slot
+	other := &recurring{kind: "purge", every: time.Hour}
+	duplicate, _ := other.due(now)
+	store := &memoryStore{}
+	store.Enqueue(context.Background(), first)
+	store.Enqueue(context.Background(), duplicate)
+	if len(store.jobs) != 1 {
+		t.Errorf("expected instances to share recurring jobs, got %d", len(store.jobs))
+	}
+}
+
+func TestRunDrainsRunningJobs(t *testing.T) {
+
+	store := &memoryStore{}
+	runner := NewRunner(store)
+	runner.PollInterval = 10 * time.Millisecond
+
+	started := make(chan struct{})
+	finished := false
+	Register(runner, "slow", func(ctx context.Context, payload struct{}) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished = ctx.Err() == nil
+		return nil
+	})
+
+	job, _ := New("slow", struct{}{})
+	store.Enqueue(context.Background(), job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if !finished || store.jobs[0].status != StatusCompleted {
+		t.Error("expected the running job to finish before Run returned")
+	}
+}
@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Runner claims jobs from the store and runs them on a pool of workers
+type Runner struct {
+	store     Store
+	handlers  map[string]handlerFunc
+	recurring []*recurring
+
+	Workers int
+	// PollInterval is how long an idle worker waits before looking for work again
+	PollInterval time.Duration
+	// JobTimeout bounds a single run, it is also how long a claimed job stays locked
+	JobTimeout time.Duration
+	// DrainTimeout is how long Run lets running jobs finish after it is told to stop
+	DrainTimeout time.Duration
+	Now          func() time.Time
+}
+
+func NewRunner(store Store) *Runner {
+	return &Runner{
+		store:        store,
+		handlers:     map[string]handlerFunc{},
+		Workers:      4,
+		PollInterval: time.Second,
+		JobTimeout:   5 * time.Minute,
+		DrainTimeout: 30 * time.Second,
+		Now:          time.Now,
+	}
+}
+
+func (r *Runner) kinds() []string {
+	kinds := []string{}
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// RunOnce claims and runs one due job, reporting false when there was none
+func (r *Runner) RunOnce(ctx context.Context) (bool, error) {
+
+	now := r.Now()
+	job, ok, err := r.store.Claim(ctx, r.kinds(), now, now.Add(r.JobTimeout))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	runErr := r.run(ctx, job)
+	now = r.Now()
+	if runErr == nil {
+		return true, r.store.Complete(ctx, job, now)
+	}
+
+	dead := isPermanent(runErr) || job.Attempts >= job.MaxAttempts
+	return true, r.store.Fail(ctx, job, runErr, now.Add(Backoff(job.Attempts)), dead)
+}
+
+// run calls the job's handler, a panic fails the job rather than the worker
+func (r *Runner) run(ctx context.Context, job Job) (err error) {
+
+	handle, ok := r.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for %s jobs", job.Kind))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.JobTimeout)
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("%s job panicked: %v", job.Kind, recovered)
+		}
+	}()
+	return handle(ctx, job.Payload)
+}
+
+// Run works the queue until ctx is cancelled. It then stops claiming jobs and waits up to
+// DrainTimeout for running ones to finish before cancelling them.
+func (r *Runner) Run(ctx context.Context) {
+
+	// jobs outlive ctx so a shutdown lets them finish, jobCtx is cancelled once draining gives up
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	wg := sync.WaitGroup{}
+	for range r.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx, jobCtx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.enqueueRecurring(ctx)
+	}()
+
+	<-ctx.Done()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(r.DrainTimeout):
+		log.Println("jobs still running after drain timeout, cancelling them")
+		cancelJobs()
+		<-drained
+	}
+}
+
+// work runs jobs back to back while there are any, then polls
+func (r *Runner) work(ctx, jobCtx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		ran, err := r.RunOnce(jobCtx)
+		if err != nil {
+			log.Println("job runner: ", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+func (r *Runner) enqueueRecurring(ctx context.Context) {
+
+	if len(r.recurring) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, rec := range r.recurring {
+			job, due := rec.due(r.Now())
+			if !due {
+				continue
+			}
+			if err := r.store.Enqueue(ctx, job); err != nil {
+				log.Println("could not enqueue recurring job: ", err)
+				// try again on the next tick
+				rec.lastSlot = time.Time{}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
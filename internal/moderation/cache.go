@@ -0,0 +1,37 @@
+package moderation
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Loader reads the current word list
+type Loader func(ctx context.Context) ([]Rule, error)
+
+// Cache holds the compiled filter for the word list so it isn't rebuilt per request.
+// Refresh swaps in a new one whenever the list changes, readers never block.
+type Cache struct {
+	load   Loader
+	filter atomic.Pointer[Filter]
+}
+
+func NewCache(load Loader) *Cache {
+	c := &Cache{load: load}
+	c.filter.Store(NewFilter(nil))
+	return c
+}
+
+// Refresh rebuilds the filter from the word list, on error the previous filter stays in use
+func (c *Cache) Refresh(ctx context.Context) error {
+	rules, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	c.filter.Store(NewFilter(rules))
+	return nil
+}
+
+// Filter is the most recently loaded filter
+func (c *Cache) Filter() *Filter {
+	return c.filter.Load()
+}
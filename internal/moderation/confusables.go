@@ -0,0 +1,69 @@
+package moderation
+
+// lookalikes maps characters used to disguise a word to the letter they stand in for: leetspeak
+// digits and symbols, and letters from other scripts that render like latin ones
+var lookalikes = map[rune]rune{
+	// leetspeak
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+
+	// cyrillic
+	'а': 'a',
+	'в': 'b',
+	'е': 'e',
+	'ё': 'e',
+	'і': 'i',
+	'ј': 'j',
+	'к': 'k',
+	'м': 'm',
+	'н': 'h',
+	'о': 'o',
+	'р': 'p',
+	'с': 'c',
+	'т': 't',
+	'у': 'y',
+	'х': 'x',
+	'ѕ': 's',
+	'ԁ': 'd',
+	'ԛ': 'q',
+	'ԝ': 'w',
+
+	// greek
+	'α': 'a',
+	'β': 'b',
+	'ε': 'e',
+	'η': 'n',
+	'ι': 'i',
+	'κ': 'k',
+	'ν': 'v',
+	'ο': 'o',
+	'ρ': 'p',
+	'τ': 't',
+	'υ': 'u',
+	'χ': 'x',
+
+	// latin letters that don't decompose
+	'ł': 'l',
+	'ø': 'o',
+	'đ': 'd',
+	'ħ': 'h',
+	'ı': 'i',
+	'ß': 's',
+}
+
+// invisible characters are dropped entirely, they are used to split a word without changing how it looks
+var invisible = map[rune]bool{
+	'\u00ad': true, // soft hyphen
+	'\u200b': true, // zero width space
+	'\u200c': true, // zero width non-joiner
+	'\u200d': true, // zero width joiner
+	'\u2060': true, // word joiner
+	'\ufeff': true, // zero width no-break space
+}
@@ -0,0 +1,95 @@
+package moderation
+
+// matcher is an Aho-Corasick automaton over runes, it finds every occurrence of every pattern
+// in one pass over the text however many patterns there are
+type matcher struct {
+	nodes   []node
+	lengths []int
+}
+
+type node struct {
+	next map[rune]int
+	// fail is the node for the longest proper suffix of this node's path that is also a path
+	fail int
+	// patterns ending here, including those reached through fail links
+	outputs []int
+}
+
+type match struct {
+	pattern int
+	// start and end index the searched runes, end is exclusive
+	start int
+	end   int
+}
+
+func newMatcher(patterns [][]rune) *matcher {
+
+	m := &matcher{nodes: []node{{next: map[rune]int{}}}}
+	lengths := make([]int, len(patterns))
+
+	for i, pattern := range patterns {
+		lengths[i] = len(pattern)
+		current := 0
+		for _, r := range pattern {
+			next, ok := m.nodes[current].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, node{next: map[rune]int{}})
+				m.nodes[current].next[r] = next
+			}
+			current = next
+		}
+		m.nodes[current].outputs = append(m.nodes[current].outputs, i)
+	}
+
+	// fail links are set breadth first so a node's are ready before its children need them
+	queue := []int{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[current].next {
+			fail := m.nodes[current].fail
+			for fail != 0 && !m.has(fail, r) {
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[r]; ok && next != child {
+				m.nodes[child].fail = next
+			}
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[m.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+
+	m.lengths = lengths
+	return m
+}
+
+func (m *matcher) has(n int, r rune) bool {
+	_, ok := m.nodes[n].next[r]
+	return ok
+}
+
+// find returns every match in text, overlapping ones included, ordered by where they end
+func (m *matcher) find(text []rune) []match {
+
+	matches := []match{}
+	current := 0
+	for i, r := range text {
+		for current != 0 && !m.has(current, r) {
+			current = m.nodes[current].fail
+		}
+		current = m.nodes[current].next[r]
+
+		for _, pattern := range m.nodes[current].outputs {
+			matches = append(matches, match{
+				pattern: pattern,
+				start:   i + 1 - m.lengths[pattern],
+				end:     i + 1,
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,198 @@
+// Package moderation, finds words from the moderation list in user text however they are disguised
+package moderation
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Action is what happens to text containing a word
+type Action string
+
+const (
+	ActionNone Action = ""
+	// ActionMask replaces the word with asterisks
+	ActionMask Action = "mask"
+	// ActionFlag publishes the text as written and queues it for review
+	ActionFlag Action = "flag"
+	// ActionReject refuses the text
+	ActionReject Action = "reject"
+)
+
+// severity orders actions so the strictest match decides what happens to the text
+var severity = map[Action]int{
+	ActionNone:   0,
+	ActionMask:   1,
+	ActionFlag:   2,
+	ActionReject: 3,
+}
+
+var ErrInvalidAction = errors.New("action must be mask, flag or reject")
+
+func ParseAction(raw string) (Action, error) {
+	switch action := Action(raw); action {
+	case ActionMask, ActionFlag, ActionReject:
+		return action, nil
+	}
+	return ActionNone, ErrInvalidAction
+}
+
+const mask = "****"
+
+// Rule is one entry of the word list
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+// Result is text after the filter has run
+type Result struct {
+	// Text has every masked word replaced
+	Text string
+	// Action is the strictest action of any word found
+	Action Action
+	// Words are the list entries that matched, each once
+	Words []string
+}
+
+// Filter matches text against a word list in a single pass
+type Filter struct {
+	matcher *matcher
+	rules   []Rule
+}
+
+// NewFilter compiles rules, words that fold to nothing are skipped
+func NewFilter(rules []Rule) *Filter {
+	kept := []Rule{}
+	patterns := [][]rune{}
+	for _, rule := range rules {
+		pattern := []rune{}
+		for _, r := range rule.Word {
+			pattern = append(pattern, fold(r)...)
+		}
+		if len(pattern) == 0 {
+			continue
+		}
+		kept = append(kept, rule)
+		patterns = append(patterns, pattern)
+	}
+	return &Filter{
+		matcher: newMatcher(patterns),
+		rules:   kept,
+	}
+}
+
+// Check finds every listed word in text. Matching ignores case, accents, zero width characters,
+// lookalike letters from other scripts and common leetspeak, and only counts whole words, so
+// "kerfuffle!" matches kerfuffle but "scunthorpe" doesn't match a shorter word inside it.
+func (f *Filter) Check(text string) Result {
+
+	result := Result{Text: text, Words: []string{}}
+	if f == nil || len(f.rules) == 0 {
+		return result
+	}
+
+	folded, spans := foldText(text)
+
+	// a span of the original text for each masked match, by byte offset
+	masked := [][2]int{}
+	seen := map[int]bool{}
+	for _, match := range f.matcher.find(folded) {
+		if !isBoundary(folded, match.start-1) || !isBoundary(folded, match.end) {
+			continue
+		}
+
+		rule := f.rules[match.pattern]
+		if severity[rule.Action] > severity[result.Action] {
+			result.Action = rule.Action
+		}
+		if !seen[match.pattern] {
+			seen[match.pattern] = true
+			result.Words = append(result.Words, rule.Word)
+		}
+		if rule.Action == ActionMask {
+			masked = append(masked, [2]int{spans[match.start][0], spans[match.end-1][1]})
+		}
+	}
+
+	result.Text = applyMasks(text, masked)
+	return result
+}
+
+// applyMasks replaces each span in text, overlapping spans are merged first
+func applyMasks(text string, spans [][2]int) string {
+
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(spans); i++ {
+		start, end := spans[i][0], spans[i][1]
+		for i+1 < len(spans) && spans[i+1][0] < end {
+			i++
+			end = max(end, spans[i][1])
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(mask)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// isBoundary reports whether position i of folded text is outside of a word
+func isBoundary(folded []rune, i int) bool {
+	if i < 0 || i >= len(folded) {
+		return true
+	}
+	return !unicode.IsLetter(folded[i]) && !unicode.IsDigit(folded[i])
+}
+
+// foldText folds text rune by rune, recording the byte span of the original each folded rune came from.
+// Runes that fold to nothing, like combining accents, are absorbed into the span before them so a
+// mask covers them too.
+func foldText(text string) ([]rune, [][2]int) {
+
+	folded := []rune{}
+	spans := [][2]int{}
+	for offset, r := range text {
+		end := offset + len(string(r))
+		runes := fold(r)
+		if len(runes) == 0 && len(spans) > 0 {
+			spans[len(spans)-1][1] = end
+			continue
+		}
+		for _, f := range runes {
+			folded = append(folded, f)
+			spans = append(spans, [2]int{offset, end})
+		}
+	}
+	return folded, spans
+}
+
+// fold reduces a rune to the plain lowercase letters it is meant to look like
+func fold(r rune) []rune {
+	if invisible[r] {
+		return nil
+	}
+
+	runes := []rune{}
+	for _, d := range norm.NFKD.String(string(r)) {
+		if unicode.Is(unicode.Mn, d) {
+			continue
+		}
+		d = unicode.ToLower(d)
+		if replacement, ok := lookalikes[d]; ok {
+			d = replacement
+		}
+		runes = append(runes, d)
+	}
+	return runes
+}
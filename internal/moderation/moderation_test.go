@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func defaultFilter() *Filter {
+	return NewFilter([]Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionFlag},
+		{Word: "grawlix", Action: ActionReject},
+	})
+}
+
+func TestCheckMasks(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "what a kerfuffle today", "what a **** today"},
+		{"punctuation", "kerfuffle! it was a sharbert.", "****! it was a ****."},
+		{"case", "KerFuffle", "****"},
+		{"leetspeak", "k3rfuffl3 and $harb3rt", "**** and ****"},
+		{"accents", "k\u00e9rf\u00fcffle", "****"},
+		{"decomposed accents", "ke\u0301rfuffle", "****"},
+		{"cyrillic lookalikes", "k\u0435rfuffl\u0435", "****"},
+		{"zero width space", "kerf\u200buffle", "****"},
+		{"inside a longer word", "kerfuffles and unsharbertly", "kerfuffles and unsharbertly"},
+		{"clean", "nothing to see here", "nothing to see here"},
+	}
+
+	filter := defaultFilter()
+	for _, tc := range tests {
+		if got := filter.Check(tc.text).Text; got != tc.want {
+			t.Errorf("%s: Check(%q) = %q, want %q", tc.name, tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestCheckStrictestActionWins(t *testing.T) {
+
+	filter := defaultFilter()
+
+	result := filter.Check("kerfuffle at fornax")
+	if result.Action != ActionFlag {
+		t.Errorf("expected flag, got %q", result.Action)
+	}
+	if result.Text != "**** at fornax" {
+		t.Errorf("expected only the masked word replaced, got %q", result.Text)
+	}
+	if !reflect.DeepEqual(result.Words, []string{"kerfuffle", "fornax"}) {
+		t.Errorf("unexpected words %v", result.Words)
+	}
+
+	if result := filter.Check("fornax grawlix fornax"); result.Action != ActionReject {
+		t.Errorf("expected reject, got %q", result.Action)
+	}
+
+	if result := filter.Check("all fine"); result.Action != ActionNone || len(result.Words) != 0 {
+		t.Errorf("expected no matches, got %+v", result)
+	}
+}
+
+func TestMatcherOverlappingPatterns(t *testing.T) {
+
+	m := newMatcher([][]rune{[]rune("he"), []rune("she"), []rune("hers"), []rune("his")})
+	matches := m.find([]rune("ushers"))
+
+	want := []match{
+		{pattern: 1, start: 1, end: 4},
+		{pattern: 0, start: 2, end: 4},
+		{pattern: 2, start: 2, end: 6},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got %+v, want %+v", matches, want)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	if action, err := ParseAction("reject"); err != nil || action != ActionReject {
+		t.Errorf("expected reject, got %q %v", action, err)
+	}
+	if _, err := ParseAction("delete"); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("expected ErrInvalidAction, got %v", err)
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+
+	rules := []Rule{}
+	cache := NewCache(func(ctx context.Context) ([]Rule, error) {
+		return rules, nil
+	})
+
+	if cache.Filter().Check("kerfuffle").Text != "kerfuffle" {
+		t.Error("expected an empty filter before the first refresh")
+	}
+
+	rules = []Rule{{Word: "kerfuffle", Action: ActionMask}}
+	if err := cache.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Filter().Check("kerfuffle").Text != "****" {
+		t.Error("expected the refreshed filter to mask")
+	}
+}
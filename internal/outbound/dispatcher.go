@@ -0,0 +1,82 @@
+package outbound
+
+import (
+	"context"
+	"time"
+)
+
+// delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusFailed was given up on after MaxAttempts
+	StatusFailed = "failed"
+)
+
+// Outcome is what happens to a delivery after an attempt
+type Outcome struct {
+	Result      Result
+	AttemptedAt time.Time
+	Status      string
+	// NextAttemptAt is when a pending delivery is retried
+	NextAttemptAt time.Time
+}
+
+// Store is the outbox deliveries are read from and written back to
+type Store interface {
+	// ClaimDue hands out up to limit deliveries due at now, a delivery claimed by one
+	// dispatcher isn't handed to another until it has had time to finish
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]Delivery, error)
+	// Record saves an attempt and its outcome
+	Record(ctx context.Context, delivery Delivery, outcome Outcome) error
+}
+
+// Dispatcher works through the outbox
+type Dispatcher struct {
+	Store     Store
+	Sender    *Sender
+	BatchSize int
+	Now       func() time.Time
+}
+
+func NewDispatcher(store Store, sender *Sender) *Dispatcher {
+	return &Dispatcher{
+		Store:     store,
+		Sender:    sender,
+		BatchSize: 20,
+		Now:       time.Now,
+	}
+}
+
+// RunOnce attempts every delivery that is due and returns how many it attempted
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+
+	due, err := d.Store.ClaimDue(ctx, d.Now(), d.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range due {
+		result := d.Sender.Send(ctx, delivery)
+		if err := d.Store.Record(ctx, delivery, NextOutcome(delivery.Attempts, result, d.Now())); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// NextOutcome decides what happens to a delivery that had failed attempts times before result
+func NextOutcome(attempts int, result Result, now time.Time) Outcome {
+	outcome := Outcome{Result: result, AttemptedAt: now}
+	attempts++
+	switch {
+	case result.OK():
+		outcome.Status = StatusDelivered
+	case attempts >= MaxAttempts:
+		outcome.Status = StatusFailed
+	default:
+		outcome.Status = StatusPending
+		outcome.NextAttemptAt = now.Add(Backoff(attempts))
+	}
+	return outcome
+}
@@ -0,0 +1,238 @@
+// Package outbound, delivers Chirpy events to webhook endpoints integrators register
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader is "v1=<hex>", the HMAC-SHA256 of "<timestamp>.<body>" under the endpoint's secret
+	SignatureHeader = "X-Chirpy-Signature"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	EventHeader     = "X-Chirpy-Event"
+	// DeliveryHeader is the same on every retry of a delivery, so receivers can drop duplicates
+	DeliveryHeader = "X-Chirpy-Delivery"
+)
+
+// event types endpoints can subscribe to
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserUpgraded = "user.upgraded"
+)
+
+var EventTypes = []string{EventChirpCreated, EventChirpDeleted, EventUserUpgraded}
+
+var (
+	ErrInvalidURL = errors.New("webhook url must be an absolute http or https url")
+	// ErrPrivateAddress is an endpoint inside the server's own network, where deliveries could
+	// reach services that were never meant to be public
+	ErrPrivateAddress = errors.New("webhook url must not point at a loopback, private or link-local address")
+)
+
+// sharedAddressSpace is 100.64.0.0/10, carrier-grade NAT, which net.IP.IsPrivate leaves out
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicAddress reports whether ip may receive deliveries, anything but loopback, private,
+// link-local, unspecified or multicast addresses
+func PublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
+
+// Guard keeps deliveries to public addresses. Endpoints are checked when they are registered
+// and again on every connection, since a name can be pointed somewhere else after registering.
+type Guard struct {
+	// AllowPrivate lets endpoints on private addresses through, for local development and tests
+	// against httptest receivers
+	AllowPrivate bool
+	// Resolver looks up endpoint hosts, net.DefaultResolver when nil
+	Resolver *net.Resolver
+}
+
+// ValidateURL checks an endpoint url before it is registered, every address its host
+// resolves to must be public
+func (g Guard) ValidateURL(ctx context.Context, raw string) error {
+	parsed, err := url.ParseRequestURI(raw)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return ErrInvalidURL
+	}
+	if g.AllowPrivate {
+		return nil
+	}
+
+	resolver := g.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook host %s", parsed.Hostname())
+	}
+	for _, addr := range addrs {
+		if !PublicAddress(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// control runs on every connection a sender dials, once the host has been resolved
+func (g Guard) control(network, address string, _ syscall.RawConn) error {
+	if g.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !PublicAddress(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// ValidEventType reports whether endpoints can subscribe to eventType
+func ValidEventType(eventType string) bool {
+	for _, known := range EventTypes {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is given up on
+	MaxAttempts = 8
+	// DisableAfter is how many failed attempts in a row switch an endpoint off
+	DisableAfter = 15
+
+	DefaultTimeout = 10 * time.Second
+
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+	// only this much of a failed response is kept for the delivery log
+	maxLoggedBody = 512
+)
+
+// Backoff is how long to wait before retrying a delivery that has failed attempts times,
+// doubling from 30 seconds up to 6 hours
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	wait := firstRetry
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxRetry {
+			return maxRetry
+		}
+	}
+	return wait
+}
+
+// Delivery is one event on its way to one endpoint
+type Delivery struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+	// Attempts is how many times it has been tried already
+	Attempts  int
+	URL       string
+	Secret    string
+	EventType string
+	Payload   []byte
+}
+
+// Result is how one attempt at a delivery went
+type Result struct {
+	// StatusCode is zero when no response came back
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// OK reports whether the endpoint accepted the delivery
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// Sender signs and posts deliveries
+type Sender struct {
+	Client *http.Client
+	Now    func() time.Time
+}
+
+func NewSender(timeout time.Duration, guard Guard) *Sender {
+	dialer := &net.Dialer{Timeout: timeout, Control: guard.control}
+	return &Sender{
+		Client: &http.Client{
+			Timeout: timeout,
+			// no proxy, the guard would check the proxy's address rather than the endpoint's
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// a redirect is answered as the endpoint's response, following it would deliver
+			// somewhere that was never registered
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Now: time.Now,
+	}
+}
+
+// Send makes one attempt at a delivery, any 2xx response counts as accepted
+func (s *Sender) Send(ctx context.Context, d Delivery) Result {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	now := s.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, d.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, "v1="+auth.SignWebhook(d.Secret, now, d.Payload))
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	result := Result{Duration: time.Since(start)}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBody))
+		result.Err = fmt.Errorf("endpoint responded %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return result
+	}
+
+	// drain what's left so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxLoggedBody))
+	return result
+}
@@ -0,0 +1,284 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/google/uuid"
+)
+
+// receiver is a local endpoint that checks signatures and fails the first failures requests
+type receiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+
+	mu       sync.Mutex
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	unix, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		r.t.Errorf("bad timestamp header: %v", err)
+	}
+	want := "v1=" + auth.SignWebhook(r.secret, time.Unix(unix, 0), body)
+	if got := req.Header.Get(SignatureHeader); got != want {
+		r.t.Errorf("signature %q, want %q", got, want)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, req)
+	r.bodies = append(r.bodies, body)
+	if len(r.received) <= r.failures {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memoryStore is an outbox that hands out every pending delivery that is due
+type memoryStore struct {
+	deliveries map[uuid.UUID]*Delivery
+	outcomes   map[uuid.UUID][]Outcome
+}
+
+func newMemoryStore(deliveries ...Delivery) *memoryStore {
+	s := &memoryStore{deliveries: map[uuid.UUID]*Delivery{}, outcomes: map[uuid.UUID][]Outcome{}}
+	for i := range deliveries {
+		s.deliveries[deliveries[i].ID] = &deliveries[i]
+	}
+	return s
+}
+
+func (s *memoryStore) last(id uuid.UUID) Outcome {
+	outcomes := s.outcomes[id]
+	if len(outcomes) == 0 {
+		return Outcome{Status: StatusPending}
+	}
+	return outcomes[len(outcomes)-1]
+}
+
+func (s *memoryStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	due := []Delivery{}
+	for id, delivery := range s.deliveries {
+		last := s.last(id)
+		if last.Status == StatusPending && !last.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (s *memoryStore) Record(ctx context.Context, delivery Delivery, outcome Outcome) error {
+	s.outcomes[delivery.ID] = append(s.outcomes[delivery.ID], outcome)
+	s.deliveries[delivery.ID].Attempts++
+	return nil
+}
+
+// localReceivers lets the sender reach httptest servers on loopback
+var localReceivers = Guard{AllowPrivate: true}
+
+func TestSendSignsDelivery(t *testing.T) {
+
+	recv := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	delivery := Delivery{
+		ID:        uuid.New(),
+		URL:       server.URL,
+		Secret:    "whsec_test",
+		EventType: EventChirpCreated,
+		Payload:   []byte(`{"type":"chirp.created"}`),
+	}
+	result := NewSender(time.Second, localReceivers).Send(context.Background(), delivery)
+	if !result.OK() || result.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected delivery to be accepted, got %+v", result)
+	}
+
+	req := recv.received[0]
+	if req.Header.Get(EventHeader) != EventChirpCreated || req.Header.Get(DeliveryHeader) != delivery.ID.String() {
+		t.Errorf("missing event headers: %v", req.Header)
+	}
+	if string(recv.bodies[0]) != string(delivery.Payload) {
+		t.Errorf("body %s, want %s", recv.bodies[0], delivery.Payload)
+	}
+}
+
+func TestSendReportsFailures(t *testing.T) {
+
+	recv := &receiver{t: t, secret: "s", failures: 1}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	result := NewSender(time.Second, localReceivers).Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL, Secret: "s", Payload: []byte("{}")})
+	if result.OK() || result.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a 503 failure, got %+v", result)
+	}
+
+	server.Close()
+	result = NewSender(time.Second, localReceivers).Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL, Secret: "s", Payload: []byte("{}")})
+	if result.OK() || result.StatusCode != 0 {
+		t.Errorf("expected a connection failure, got %+v", result)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+
+	recv := &receiver{t: t, secret: "s", failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	delivery := Delivery{ID: uuid.New(), URL: server.URL, Secret: "s", EventType: EventChirpDeleted, Payload: []byte("{}")}
+	store := newMemoryStore(delivery)
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dispatcher := NewDispatcher(store, NewSender(time.Second, localReceivers))
+	dispatcher.Now = func() time.Time { return now }
+
+	run := func() int {
+		attempted, err := dispatcher.RunOnce(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return attempted
+	}
+
+	if run() != 1 {
+		t.Fatal("expected the first attempt straight away")
+	}
+	if next := store.last(delivery.ID).NextAttemptAt; !next.Equal(now.Add(Backoff(1))) {
+		t.Errorf("expected a retry after %v, got %v", Backoff(1), next.Sub(now))
+	}
+	if run() != 0 {
+		t.Error("expected no attempt before the backoff passes")
+	}
+
+	now = now.Add(Backoff(1))
+	run()
+	now = now.Add(Backoff(2))
+	run()
+
+	if status := store.last(delivery.ID).Status; status != StatusDelivered {
+		t.Errorf("expected delivery on the third attempt, got %s", status)
+	}
+	if len(recv.received) != 3 {
+		t.Errorf("expected 3 requests, got %d", len(recv.received))
+	}
+	for _, req := range recv.received {
+		if req.Header.Get(DeliveryHeader) != delivery.ID.String() {
+			t.Error("expected every retry to carry the same delivery id")
+		}
+	}
+}
+
+func TestNextOutcomeGivesUp(t *testing.T) {
+
+	now := time.Now()
+	failed := Result{StatusCode: http.StatusInternalServerError, Err: io.ErrUnexpectedEOF}
+
+	if outcome := NextOutcome(MaxAttempts-2, failed, now); outcome.Status != StatusPending {
+		t.Errorf("expected a retry, got %s", outcome.Status)
+	}
+	if outcome := NextOutcome(MaxAttempts-1, failed, now); outcome.Status != StatusFailed {
+		t.Errorf("expected the delivery to be given up on, got %s", outcome.Status)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	if Backoff(1) != 30*time.Second || Backoff(2) != time.Minute || Backoff(3) != 2*time.Minute {
+		t.Errorf("expected backoff to double from 30s, got %v %v %v", Backoff(1), Backoff(2), Backoff(3))
+	}
+	if Backoff(40) != 6*time.Hour {
+		t.Errorf("expected backoff to be capped at 6h, got %v", Backoff(40))
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+
+	for _, raw := range []string{"https://example.com/hooks", "http://localhost:8080/in"} {
+		if err := localReceivers.ValidateURL(context.Background(), raw); err != nil {
+			t.Errorf("expected %s to be valid", raw)
+		}
+	}
+	for _, raw := range []string{"", "example.com/hooks", "ftp://example.com", "/relative"} {
+		if err := localReceivers.ValidateURL(context.Background(), raw); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("expected %s to be rejected, got %v", raw, err)
+		}
+	}
+}
+
+func TestValidateURLRejectsPrivateAddresses(t *testing.T) {
+
+	private := []string{
+		"http://localhost:8080/in",
+		"http://127.0.0.1/in",
+		"http://[::1]/in",
+		"http://0.0.0.0/in",
+		"http://10.0.0.5/in",
+		"http://172.16.1.1/in",
+		"http://192.168.1.10/in",
+		"http://100.64.0.1/in",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/in",
+		"http://[fd00::1]/in",
+		"http://[::ffff:127.0.0.1]/in",
+	}
+	for _, raw := range private {
+		if err := (Guard{}).ValidateURL(context.Background(), raw); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("expected %s to be rejected as private, got %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"https://93.184.216.34/hooks", "https://[2606:2800:220:1::]/hooks"} {
+		if err := (Guard{}).ValidateURL(context.Background(), raw); err != nil {
+			t.Errorf("expected %s to be allowed, got %v", raw, err)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+
+	recv := &receiver{t: t, secret: "s"}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	// the url passed registration, but the address it is dialed at is loopback
+	result := NewSender(time.Second, Guard{}).Send(context.Background(), Delivery{ID: uuid.New(), URL: server.URL, Secret: "s", Payload: []byte("{}")})
+	if result.OK() || !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Errorf("expected the connection refused, got %+v", result)
+	}
+	if len(recv.received) != 0 {
+		t.Errorf("expected nothing delivered, got %d requests", len(recv.received))
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+
+	recv := &receiver{t: t, secret: "s"}
+	target := httptest.NewServer(recv)
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+
+	result := NewSender(time.Second, localReceivers).Send(context.Background(), Delivery{ID: uuid.New(), URL: redirect.URL, Secret: "s", Payload: []byte("{}")})
+	if result.OK() || result.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("expected the redirect to count as a failure, got %+v", result)
+	}
+	if len(recv.received) != 0 {
+		t.Errorf("expected the redirect not to be followed, got %d requests", len(recv.received))
+	}
+}
@@ -0,0 +1,255 @@
+// Package realtime, the message envelope and topics of the websocket API and the pub/sub
+// that carries messages to the connections that want them.
+//
+// Every frame, in either direction, is a JSON Envelope. Clients send:
+//
+//	{"type": "auth", "id": "1", "data": {"token": "<access token>"}}
+//	{"type": "subscribe", "id": "2", "topic": "timeline"}
+//	{"type": "unsubscribe", "id": "3", "topic": "typing:<conversation id>"}
+//	{"type": "typing", "topic": "typing:<conversation id>"}
+//	{"type": "ping", "id": "4"}
+//
+// and the server answers requests that carry an id with an "ack" (or "pong") echoing it. It
+// sends "authenticated", "reauth_required" before the token expires, "error", a "heartbeat"
+// every 30 seconds, and the events of subscribed topics with their topic set.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// client requests
+const (
+	TypeAuth        = "auth"
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeTyping      = "typing"
+	TypePing        = "ping"
+)
+
+// server messages, besides the events of each topic
+const (
+	TypeAuthenticated  = "authenticated"
+	TypeReauthRequired = "reauth_required"
+	TypeAck            = "ack"
+	TypePong           = "pong"
+	TypeError          = "error"
+	TypeHeartbeat      = "heartbeat"
+
+	TypeChirpCreated = "chirp.created"
+	TypeNotification = "notification"
+)
+
+// topics a client can subscribe to, typing topics are TopicTypingPrefix and a conversation id
+const (
+	TopicTimeline      = "timeline"
+	TopicNotifications = "notifications"
+	TopicTypingPrefix  = "typing:"
+)
+
+// DefaultBuffer is how many messages a subscription may fall behind before it is dropped
+const DefaultBuffer = 64
+
+var (
+	ErrUnknownTopic = errors.New("topic must be timeline, notifications or typing:<conversation id>")
+	// ErrSlowConsumer means the subscription stopped keeping up and was dropped
+	ErrSlowConsumer = errors.New("subscriber fell too far behind")
+	ErrClosed       = errors.New("pub/sub is closed")
+)
+
+// Envelope is every message on the socket
+type Envelope struct {
+	Type string `json:"type"`
+	// ID is chosen by the client for a request and echoed on the reply
+	ID    string          `json:"id,omitempty"`
+	Topic string          `json:"topic,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Topic is a parsed client topic
+type Topic struct {
+	Name string
+	// ConversationID is set for typing topics
+	ConversationID uuid.UUID
+}
+
+func ParseTopic(raw string) (Topic, error) {
+	switch raw {
+	case TopicTimeline, TopicNotifications:
+		return Topic{Name: raw}, nil
+	}
+	if id, ok := strings.CutPrefix(raw, TopicTypingPrefix); ok {
+		conversationID, err := uuid.Parse(id)
+		if err != nil {
+			return Topic{}, ErrUnknownTopic
+		}
+		return Topic{Name: raw, ConversationID: conversationID}, nil
+	}
+	return Topic{}, ErrUnknownTopic
+}
+
+// channels messages are published on, a client topic maps to one of these
+const ChannelChirps = "chirps"
+
+func NotificationsChannel(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+func TypingChannel(conversationID uuid.UUID) string {
+	return "typing:" + conversationID.String()
+}
+
+// Message is published on a channel and delivered to every subscription that joined it
+type Message struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// PubSub carries messages between publishers and subscriptions, implementations that span
+// instances relay what they receive into a Local
+type PubSub interface {
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe opens a subscription on no channels, Join picks what it receives
+	Subscribe() (*Subscription, error)
+	// Close ends every subscription with ErrClosed
+	Close()
+}
+
+// Local is a PubSub within this process. Publishing never waits on a subscription, one whose
+// queue is full is dropped with ErrSlowConsumer.
+type Local struct {
+	// Buffer is the size of each new subscription's queue
+	Buffer int
+
+	mu       sync.Mutex
+	channels map[string]map[*Subscription]struct{}
+	subs     map[*Subscription]struct{}
+	closed   bool
+}
+
+func NewLocal() *Local {
+	return &Local{
+		Buffer:   DefaultBuffer,
+		channels: map[string]map[*Subscription]struct{}{},
+		subs:     map[*Subscription]struct{}{},
+	}
+}
+
+func (l *Local) Publish(ctx context.Context, msg Message) error {
+	l.Deliver(msg)
+	return nil
+}
+
+// Deliver hands msg to the subscriptions on its channel in this process
+func (l *Local) Deliver(msg Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sub := range l.channels[msg.Channel] {
+		select {
+		case sub.messages <- msg:
+		default:
+			l.drop(sub, ErrSlowConsumer)
+		}
+	}
+}
+
+func (l *Local) Subscribe() (*Subscription, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil, ErrClosed
+	}
+	sub := &Subscription{
+		local:    l,
+		messages: make(chan Message, l.Buffer),
+		channels: map[string]struct{}{},
+	}
+	l.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+func (l *Local) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	for sub := range l.subs {
+		l.drop(sub, ErrClosed)
+	}
+}
+
+// drop ends sub, the caller holds l.mu
+func (l *Local) drop(sub *Subscription, err error) {
+	if _, ok := l.subs[sub]; !ok {
+		return
+	}
+	delete(l.subs, sub)
+	for channel := range sub.channels {
+		l.leave(sub, channel)
+	}
+	sub.err = err
+	close(sub.messages)
+}
+
+// leave takes sub off channel, the caller holds l.mu
+func (l *Local) leave(sub *Subscription, channel string) {
+	delete(sub.channels, channel)
+	delete(l.channels[channel], sub)
+	if len(l.channels[channel]) == 0 {
+		delete(l.channels, channel)
+	}
+}
+
+// Subscription receives the messages on the channels it joined
+type Subscription struct {
+	local    *Local
+	messages chan Message
+	channels map[string]struct{}
+	err      error
+}
+
+// Messages is closed when the subscription ends, Err then says why
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+func (s *Subscription) Err() error {
+	s.local.mu.Lock()
+	defer s.local.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) Join(channel string) {
+	s.local.mu.Lock()
+	defer s.local.mu.Unlock()
+
+	if _, ok := s.local.subs[s]; !ok {
+		return
+	}
+	s.channels[channel] = struct{}{}
+	if s.local.channels[channel] == nil {
+		s.local.channels[channel] = map[*Subscription]struct{}{}
+	}
+	s.local.channels[channel][s] = struct{}{}
+}
+
+func (s *Subscription) Leave(channel string) {
+	s.local.mu.Lock()
+	defer s.local.mu.Unlock()
+	s.local.leave(s, channel)
+}
+
+func (s *Subscription) Close() {
+	s.local.mu.Lock()
+	defer s.local.mu.Unlock()
+	s.local.drop(s, nil)
+}
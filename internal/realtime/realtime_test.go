@@ -0,0 +1,111 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseTopic(t *testing.T) {
+
+	conversationID := uuid.New()
+
+	for _, raw := range []string{TopicTimeline, TopicNotifications} {
+		if topic, err := ParseTopic(raw); err != nil || topic.Name != raw {
+			t.Errorf("expected %s to parse, got %v", raw, err)
+		}
+	}
+	topic, err := ParseTopic(TopicTypingPrefix + conversationID.String())
+	if err != nil || topic.ConversationID != conversationID {
+		t.Errorf("expected a typing topic for %s, got %+v %v", conversationID, topic, err)
+	}
+	for _, raw := range []string{"", "chirps", "typing:", "typing:nope"} {
+		if _, err := ParseTopic(raw); !errors.Is(err, ErrUnknownTopic) {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestJoinAndLeave(t *testing.T) {
+
+	local := NewLocal()
+	sub, _ := local.Subscribe()
+
+	local.Publish(context.Background(), Message{Channel: ChannelChirps, Type: TypeChirpCreated})
+	sub.Join(ChannelChirps)
+	sub.Join(TypingChannel(uuid.Nil))
+	local.Publish(context.Background(), Message{Channel: ChannelChirps, Type: TypeChirpCreated})
+	local.Publish(context.Background(), Message{Channel: TypingChannel(uuid.Nil), Type: TypeTyping})
+	sub.Leave(ChannelChirps)
+	local.Publish(context.Background(), Message{Channel: ChannelChirps, Type: TypeChirpCreated})
+
+	got := drain(sub)
+	if len(got) != 2 || got[0] != TypeChirpCreated || got[1] != TypeTyping {
+		t.Errorf("expected only messages on joined channels, got %v", got)
+	}
+}
+
+func TestSlowSubscriptionIsDropped(t *testing.T) {
+
+	local := NewLocal()
+	local.Buffer = 1
+
+	slow, _ := local.Subscribe()
+	slow.Join(ChannelChirps)
+	fast, _ := local.Subscribe()
+	fast.Join(ChannelChirps)
+
+	local.Deliver(Message{Channel: ChannelChirps})
+	<-fast.Messages()
+	local.Deliver(Message{Channel: ChannelChirps})
+
+	if got := drain(slow); len(got) != 1 || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("expected the slow subscription to be dropped after its queue filled, got %v %v", got, slow.Err())
+	}
+	if _, ok := <-fast.Messages(); !ok {
+		t.Error("expected the fast subscription to keep receiving")
+	}
+
+	// a dropped subscription can't join again
+	slow.Join(ChannelChirps)
+	local.Deliver(Message{Channel: ChannelChirps})
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+
+	local := NewLocal()
+	sub, _ := local.Subscribe()
+	sub.Join(ChannelChirps)
+
+	left, _ := local.Subscribe()
+	left.Close()
+	left.Close()
+	if left.Err() != nil {
+		t.Errorf("expected a closed subscription to end cleanly, got %v", left.Err())
+	}
+
+	local.Close()
+	if _, ok := <-sub.Messages(); ok || !errors.Is(sub.Err(), ErrClosed) {
+		t.Errorf("expected closing to end subscriptions, got %v", sub.Err())
+	}
+	if _, err := local.Subscribe(); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected a closed pub/sub to refuse subscriptions, got %v", err)
+	}
+}
+
+func drain(sub *Subscription) []string {
+	types := []string{}
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return types
+			}
+			types = append(types, msg.Type)
+		default:
+			return types
+		}
+	}
+}
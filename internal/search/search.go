@@ -0,0 +1,185 @@
+// Package search, turns a user's search box input into a Postgres tsquery and filters
+package search
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultLanguage is the text search configuration used when none is given
+const DefaultLanguage = "english"
+
+// the Postgres text search configurations chirps can be stemmed with
+var languages = map[string]bool{
+	"simple":     true,
+	"english":    true,
+	"spanish":    true,
+	"french":     true,
+	"german":     true,
+	"italian":    true,
+	"portuguese": true,
+	"dutch":      true,
+}
+
+// ParseLanguage reads a text search language from user input, an empty string means DefaultLanguage
+func ParseLanguage(language string) (string, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return DefaultLanguage, nil
+	}
+	if !languages[language] {
+		return "", errors.New("language must be one of simple, english, spanish, french, german, italian, portuguese or dutch")
+	}
+	return language, nil
+}
+
+// Query is a parsed search
+type Query struct {
+	// TSQuery is the text for to_tsquery, empty when the search only used operators
+	TSQuery string
+	// FromHandle limits results to chirps by this handle
+	FromHandle string
+	// Since limits results to chirps created at or after it, zero when unset
+	Since time.Time
+}
+
+var ErrEmptyQuery = errors.New("search query is empty")
+
+// Parse reads a search box query.
+//
+//   - words must all match, stemmed: cats matches cat
+//   - "quoted words" must appear next to each other in order
+//   - word* matches any word starting with word
+//   - -word excludes results containing word
+//   - OR between two terms matches either
+//   - from:handle and since:2006-01-02 (or RFC 3339) filter the results
+func Parse(raw string) (Query, error) {
+
+	query := Query{}
+	terms := []string{}
+	pendingOr := false
+
+	for _, token := range tokenize(raw) {
+
+		if !token.quoted {
+			lower := strings.ToLower(token.text)
+			switch {
+			case strings.HasPrefix(lower, "from:"):
+				query.FromHandle = strings.TrimPrefix(token.text[len("from:"):], "@")
+				if query.FromHandle == "" {
+					return Query{}, errors.New("from: needs a handle")
+				}
+				continue
+			case strings.HasPrefix(lower, "since:"):
+				since, err := parseSince(token.text[len("since:"):])
+				if err != nil {
+					return Query{}, err
+				}
+				query.Since = since
+				continue
+			case token.text == "OR":
+				pendingOr = len(terms) > 0
+				continue
+			}
+		}
+
+		term := termFor(token)
+		if term == "" {
+			continue
+		}
+
+		if pendingOr {
+			terms[len(terms)-1] = "(" + terms[len(terms)-1] + " | " + term + ")"
+			pendingOr = false
+			continue
+		}
+		terms = append(terms, term)
+	}
+
+	query.TSQuery = strings.Join(terms, " & ")
+	if query.TSQuery == "" && query.FromHandle == "" && query.Since.IsZero() {
+		return Query{}, ErrEmptyQuery
+	}
+	return query, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+	negate bool
+}
+
+// tokenize splits on whitespace, keeping "quoted phrases" together
+func tokenize(raw string) []token {
+
+	tokens := []token{}
+	current := strings.Builder{}
+	quoted := false
+	negate := false
+
+	flush := func() {
+		if current.Len() > 0 || quoted {
+			tokens = append(tokens, token{text: current.String(), quoted: quoted, negate: negate})
+		}
+		current.Reset()
+		quoted = false
+		negate = false
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"' && quoted:
+			flush()
+		case r == '"' && current.Len() == 0:
+			quoted = true
+		case r == '-' && current.Len() == 0 && !quoted && !negate:
+			negate = true
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// termFor turns a token into tsquery syntax, dropping anything that isn't a letter or digit
+// so user input can never be read as tsquery operators
+func termFor(t token) string {
+
+	prefix := !t.quoted && strings.HasSuffix(t.text, "*")
+
+	words := strings.FieldsFunc(t.text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+
+	term := words[0]
+	if len(words) > 1 {
+		term = "(" + strings.Join(words, " <-> ") + ")"
+	}
+	if t.negate {
+		term = "!" + term
+	}
+	return term
+}
+
+func parseSince(value string) (time.Time, error) {
+	if since, err := time.Parse("2006-01-02", value); err == nil {
+		return since, nil
+	}
+	if since, err := time.Parse(time.RFC3339, value); err == nil {
+		return since, nil
+	}
+	return time.Time{}, errors.New("since: must be a date like 2006-01-02")
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		raw   string
+		query Query
+	}{
+		{"single word", "cats", Query{TSQuery: "cats"}},
+		{"words are anded", "cats dogs", Query{TSQuery: "cats & dogs"}},
+		{"phrase", `"big red dog"`, Query{TSQuery: "(big <-> red <-> dog)"}},
+		{"prefix", "chir*", Query{TSQuery: "chir:*"}},
+		{"negation", "cats -dogs", Query{TSQuery: "cats & !dogs"}},
+		{"negated phrase", `cats -"hot dogs"`, Query{TSQuery: "cats & !(hot <-> dogs)"}},
+		{"or", "cats OR dogs birds", Query{TSQuery: "(cats | dogs) & birds"}},
+		{"leading or is ignored", "OR cats", Query{TSQuery: "cats"}},
+		{"operators are stripped", "a&b | !c:*", Query{TSQuery: "(a <-> b) & c:*"}},
+		{"hyphenated word", "e-mail", Query{TSQuery: "(e <-> mail)"}},
+		{"unicode", "café", Query{TSQuery: "café"}},
+		{"from", "from:@alice cats", Query{TSQuery: "cats", FromHandle: "alice"}},
+		{"since date", "since:2024-03-01 cats", Query{TSQuery: "cats", Since: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		{"operators only", "from:alice", Query{FromHandle: "alice"}},
+		{"quoted operator is text", `"from:alice"`, Query{TSQuery: "(from <-> alice)"}},
+	}
+
+	for _, tc := range tests {
+		got, err := Parse(tc.raw)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if got.TSQuery != tc.query.TSQuery || got.FromHandle != tc.query.FromHandle || !got.Since.Equal(tc.query.Since) {
+			t.Errorf("%s: Parse(%q) = %+v, want %+v", tc.name, tc.raw, got, tc.query)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	for _, raw := range []string{"", "   ", "&|!", `""`} {
+		if _, err := Parse(raw); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Parse(%q): expected ErrEmptyQuery, got %v", raw, err)
+		}
+	}
+
+	for _, raw := range []string{"since:yesterday", "from:", "from:@"} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q): expected an error", raw)
+		}
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+
+	if got, err := ParseLanguage(""); err != nil || got != DefaultLanguage {
+		t.Errorf(`ParseLanguage("") = %q, %v`, got, err)
+	}
+	if got, err := ParseLanguage("Spanish"); err != nil || got != "spanish" {
+		t.Errorf(`ParseLanguage("Spanish") = %q, %v`, got, err)
+	}
+	if _, err := ParseLanguage("klingon"); err == nil {
+		t.Error(`ParseLanguage("klingon"): expected an error`)
+	}
+}
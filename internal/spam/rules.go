@@ -0,0 +1,122 @@
+package spam
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	linkPattern    = regexp.MustCompile(`https?://[^\s]+`)
+	mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_]+)`)
+)
+
+// DefaultRules is the rule set chirps are screened with
+func DefaultRules() []Rule {
+	return []Rule{
+		DuplicateRule{MaxDistance: 10},
+		LinkDensityRule{},
+		AccountAgeRule{},
+		VelocityRule{Allowed: 5},
+		MentionRule{Allowed: 3},
+	}
+}
+
+// DuplicateRule scores near duplicates of recent chirps, repeating yourself counts for more
+// than repeating someone else since scripts usually post from one account at a time
+type DuplicateRule struct {
+	// MaxDistance is how many bits apart two fingerprints can be and still be duplicates
+	MaxDistance int
+}
+
+func (r DuplicateRule) Name() string { return "duplicate" }
+
+func (r DuplicateRule) Score(s Signals) float64 {
+	if s.Hash == 0 {
+		return 0
+	}
+	score := 0.6*float64(r.count(s.Hash, s.AuthorHashes)) + 0.2*float64(r.count(s.Hash, s.OtherHashes))
+	return min(score, 2)
+}
+
+func (r DuplicateRule) count(hash uint64, hashes []uint64) int {
+	count := 0
+	for _, other := range hashes {
+		if Distance(hash, other) <= r.MaxDistance {
+			count++
+		}
+	}
+	return count
+}
+
+// LinkDensityRule scores chirps that are mostly links
+type LinkDensityRule struct{}
+
+func (r LinkDensityRule) Name() string { return "link_density" }
+
+func (r LinkDensityRule) Score(s Signals) float64 {
+
+	links := len(linkPattern.FindAllString(s.Body, -1))
+	if links == 0 {
+		return 0
+	}
+
+	words := len(strings.Fields(linkPattern.ReplaceAllString(s.Body, "")))
+	score := 0.25 * float64(max(links-2, 0))
+	switch {
+	case words == 0:
+		score += 0.75
+	case float64(links)/float64(links+words) > 0.3:
+		score += 0.4
+	}
+	return min(score, 1.5)
+}
+
+// AccountAgeRule scores chirps from brand new accounts, it only adds to other signals
+type AccountAgeRule struct{}
+
+func (r AccountAgeRule) Name() string { return "account_age" }
+
+func (r AccountAgeRule) Score(s Signals) float64 {
+	switch {
+	case s.AccountAge < time.Hour:
+		return 0.5
+	case s.AccountAge < 24*time.Hour:
+		return 0.25
+	}
+	return 0
+}
+
+// VelocityRule scores authors posting faster than Allowed chirps per window
+type VelocityRule struct {
+	Allowed int
+}
+
+func (r VelocityRule) Name() string { return "velocity" }
+
+func (r VelocityRule) Score(s Signals) float64 {
+	return min(0.25*float64(max(s.RecentChirps-r.Allowed, 0)), 1.5)
+}
+
+// MentionRule scores chirps that mention more than Allowed people, or the same person repeatedly
+type MentionRule struct {
+	Allowed int
+}
+
+func (r MentionRule) Name() string { return "mentions" }
+
+func (r MentionRule) Score(s Signals) float64 {
+
+	seen := map[string]bool{}
+	repeats := 0
+	for _, match := range mentionPattern.FindAllStringSubmatch(s.Body, -1) {
+		handle := strings.ToLower(match[1])
+		if seen[handle] {
+			repeats++
+		}
+		seen[handle] = true
+	}
+
+	score := 0.25*float64(max(len(seen)-r.Allowed, 0)) + 0.5*float64(repeats)
+	return min(score, 1.5)
+}
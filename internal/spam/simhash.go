@@ -0,0 +1,62 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// SimHash fingerprints text so that near duplicates, a word swapped or a counter bumped,
+// land a few bits apart while unrelated text differs in about half of them
+func SimHash(text string) uint64 {
+
+	features := shingles(tokens(text))
+	if len(features) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for _, feature := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Distance is how many bits two fingerprints differ in
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// tokens splits text into lowercase words, dropping punctuation
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// shingles are the words themselves plus each pair of neighbouring words, so word order counts
+// without a single changed word moving the fingerprint too far
+func shingles(words []string) []string {
+	features := append([]string{}, words...)
+	for i := 0; i+1 < len(words); i++ {
+		features = append(features, words[i]+" "+words[i+1])
+	}
+	return features
+}
@@ -0,0 +1,92 @@
+// Package spam, scores new chirps against a set of rules to catch scripted and abusive posting
+package spam
+
+import (
+	"time"
+)
+
+type Verdict string
+
+const (
+	VerdictAccept Verdict = "accept"
+	// VerdictHold publishes the chirp to its author only until a moderator reviews it
+	VerdictHold   Verdict = "hold"
+	VerdictReject Verdict = "reject"
+)
+
+// Default thresholds, a chirp scoring at least DefaultHoldScore is held and at least
+// DefaultRejectScore is rejected
+const (
+	DefaultHoldScore   = 1.0
+	DefaultRejectScore = 2.0
+)
+
+// Signals is everything the rules know about a chirp and its author
+type Signals struct {
+	Body string
+	// Hash is the SimHash of Body
+	Hash       uint64
+	AccountAge time.Duration
+	// RecentChirps counts the author's chirps inside the velocity window
+	RecentChirps int
+	// AuthorHashes and OtherHashes are fingerprints of recently screened chirps, by the
+	// author and by everyone else
+	AuthorHashes []uint64
+	OtherHashes  []uint64
+}
+
+// Rule scores one kind of suspicious behaviour, 0 when there's nothing suspicious about it
+type Rule interface {
+	Name() string
+	Score(s Signals) float64
+}
+
+// Hit is a rule that scored a chirp above 0
+type Hit struct {
+	Rule  string  `json:"rule"`
+	Score float64 `json:"score"`
+}
+
+// Decision is the outcome of running a chirp through a Pipeline
+type Decision struct {
+	Verdict Verdict
+	Score   float64
+	Hits    []Hit
+}
+
+// Pipeline adds up the scores of its rules and compares the total against its thresholds
+type Pipeline struct {
+	Rules       []Rule
+	HoldScore   float64
+	RejectScore float64
+}
+
+// NewPipeline builds a pipeline with the default rules and the given thresholds
+func NewPipeline(holdScore, rejectScore float64) Pipeline {
+	return Pipeline{
+		Rules:       DefaultRules(),
+		HoldScore:   holdScore,
+		RejectScore: rejectScore,
+	}
+}
+
+func (p Pipeline) Evaluate(s Signals) Decision {
+
+	decision := Decision{Verdict: VerdictAccept, Hits: []Hit{}}
+	for _, rule := range p.Rules {
+		score := rule.Score(s)
+		if score <= 0 {
+			continue
+		}
+		decision.Score += score
+		decision.Hits = append(decision.Hits, Hit{Rule: rule.Name(), Score: score})
+	}
+
+	switch {
+	case decision.Score >= p.RejectScore:
+		decision.Verdict = VerdictReject
+	case decision.Score >= p.HoldScore:
+		decision.Verdict = VerdictHold
+	}
+	return decision
+}
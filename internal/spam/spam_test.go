@@ -0,0 +1,98 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimHashNearDuplicates(t *testing.T) {
+
+	original := SimHash("Win a free phone today, just click the link in our bio and claim your prize now")
+	nearDuplicate := SimHash("Win a free phone today, just click the link in our bio and claim your prize now!!! 42")
+	swappedWord := SimHash("Win a free tablet today, just click the link in our bio and claim your prize now")
+	unrelated := SimHash("Had a lovely walk along the river this morning, the herons were out in force")
+
+	if d := Distance(original, nearDuplicate); d > 3 {
+		t.Errorf("expected near duplicates to be close, got distance %d", d)
+	}
+	if d := Distance(original, swappedWord); d > 10 {
+		t.Errorf("expected a swapped word to stay within the default distance, got %d", d)
+	}
+	if d := Distance(original, unrelated); d <= 10 {
+		t.Errorf("expected unrelated text to be far apart, got distance %d", d)
+	}
+	if SimHash("...") != 0 {
+		t.Error("expected text without words to hash to 0")
+	}
+}
+
+func TestRules(t *testing.T) {
+
+	established := 30 * 24 * time.Hour
+	hash := SimHash("buy cheap followers at our store today")
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		signals Signals
+		want    float64
+	}{
+		{"no duplicates", DuplicateRule{MaxDistance: 3}, Signals{Hash: hash}, 0},
+		{"own duplicates", DuplicateRule{MaxDistance: 3}, Signals{Hash: hash, AuthorHashes: []uint64{hash, hash}}, 1.2},
+		{"others' duplicates", DuplicateRule{MaxDistance: 3}, Signals{Hash: hash, OtherHashes: []uint64{hash}}, 0.2},
+		{"duplicates capped", DuplicateRule{MaxDistance: 3}, Signals{Hash: hash, AuthorHashes: []uint64{hash, hash, hash, hash}}, 2},
+		{"no links", LinkDensityRule{}, Signals{Body: "just words here"}, 0},
+		{"one link in text", LinkDensityRule{}, Signals{Body: "read my post about go generics at https://example.com"}, 0},
+		{"only links", LinkDensityRule{}, Signals{Body: "https://a.example https://b.example https://c.example"}, 1},
+		{"new account", AccountAgeRule{}, Signals{AccountAge: time.Minute}, 0.5},
+		{"day old account", AccountAgeRule{}, Signals{AccountAge: 2 * time.Hour}, 0.25},
+		{"established account", AccountAgeRule{}, Signals{AccountAge: established}, 0},
+		{"normal pace", VelocityRule{Allowed: 5}, Signals{RecentChirps: 5}, 0},
+		{"fast pace", VelocityRule{Allowed: 5}, Signals{RecentChirps: 9}, 1},
+		{"few mentions", MentionRule{Allowed: 3}, Signals{Body: "@a @b @c hi"}, 0},
+		{"many mentions", MentionRule{Allowed: 3}, Signals{Body: "@a @b @c @d @e"}, 0.5},
+		{"repeated mention", MentionRule{Allowed: 3}, Signals{Body: "@a @A @a"}, 1},
+	}
+
+	for _, tc := range tests {
+		if got := tc.rule.Score(tc.signals); got < tc.want-0.001 || got > tc.want+0.001 {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPipelineVerdicts(t *testing.T) {
+
+	pipeline := NewPipeline(DefaultHoldScore, DefaultRejectScore)
+	body := "follow @promo for free crypto https://scam.example"
+	hash := SimHash(body)
+
+	accepted := pipeline.Evaluate(Signals{Body: "good morning everyone", Hash: SimHash("good morning everyone"), AccountAge: 30 * 24 * time.Hour})
+	if accepted.Verdict != VerdictAccept || accepted.Score != 0 || len(accepted.Hits) != 0 {
+		t.Errorf("expected a clean accept, got %+v", accepted)
+	}
+
+	held := pipeline.Evaluate(Signals{Body: body, Hash: hash, AccountAge: time.Minute, AuthorHashes: []uint64{hash}})
+	if held.Verdict != VerdictHold {
+		t.Errorf("expected hold, got %+v", held)
+	}
+
+	rejected := pipeline.Evaluate(Signals{Body: body, Hash: hash, AccountAge: time.Minute, RecentChirps: 12, AuthorHashes: []uint64{hash, hash}})
+	if rejected.Verdict != VerdictReject {
+		t.Errorf("expected reject, got %+v", rejected)
+	}
+}
+
+type alwaysRule struct{}
+
+func (alwaysRule) Name() string            { return "always" }
+func (alwaysRule) Score(s Signals) float64 { return 5 }
+
+func TestPipelineCustomRules(t *testing.T) {
+
+	pipeline := Pipeline{Rules: []Rule{alwaysRule{}}, HoldScore: 1, RejectScore: 10}
+	decision := pipeline.Evaluate(Signals{})
+	if decision.Verdict != VerdictHold || len(decision.Hits) != 1 || decision.Hits[0].Rule != "always" {
+		t.Errorf("expected a hold from the custom rule, got %+v", decision)
+	}
+}
@@ -0,0 +1,169 @@
+// Package stream, fans chirp events out to clients following them live over server-sent events
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeChirpCreated = "chirp.created"
+	TypeChirpDeleted = "chirp.deleted"
+
+	// DefaultBuffer is how many events a subscriber may fall behind before it is dropped
+	DefaultBuffer = 64
+)
+
+var (
+	// ErrSlowConsumer means the subscriber stopped keeping up and was dropped, it can resume
+	// from the last event it saw
+	ErrSlowConsumer = errors.New("subscriber fell too far behind")
+	ErrClosed       = errors.New("stream is closed")
+)
+
+// Event is one change to the chirps, IDs increase so a client can resume after the last it saw
+type Event struct {
+	ID       int64
+	Type     string
+	AuthorID uuid.UUID
+	// Unlisted events stay out of the firehose and only go to clients following their author
+	Unlisted bool
+	Data     json.RawMessage
+}
+
+// Filter picks the events a subscriber wants, the zero Filter is the public firehose
+type Filter struct {
+	AuthorID uuid.UUID
+}
+
+func (f Filter) Matches(event Event) bool {
+	if f.AuthorID == uuid.Nil {
+		return !event.Unlisted
+	}
+	return event.AuthorID == f.AuthorID
+}
+
+// Subscription receives the events matching its filter until it is closed or dropped
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+	err    error
+}
+
+// Events is closed when the subscription ends, Err then says why
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s, nil)
+}
+
+// Hub hands every published event to the subscriptions that want it without ever waiting on one
+type Hub struct {
+	// Buffer is the size of each new subscription's queue
+	Buffer int
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		Buffer: DefaultBuffer,
+		subs:   map[*Subscription]struct{}{},
+	}
+}
+
+func (h *Hub) Subscribe(filter Filter) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+	sub := &Subscription{hub: h, filter: filter, events: make(chan Event, h.Buffer)}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Publish queues event for every matching subscription, one whose queue is full is dropped
+// with ErrSlowConsumer rather than holding everyone else up
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub, ErrSlowConsumer)
+		}
+	}
+}
+
+// Close ends every subscription with ErrClosed and refuses new ones, for shutting down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.drop(sub, ErrClosed)
+	}
+}
+
+// Len is the number of live subscriptions
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// drop ends sub, the caller holds h.mu
+func (h *Hub) drop(sub *Subscription, err error) {
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	delete(h.subs, sub)
+	sub.err = err
+	close(sub.events)
+}
+
+// Write sends event in the text/event-stream format
+func Write(w io.Writer, event Event) error {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "id: %d\nevent: %s\n", event.ID, event.Type)
+	// a newline would end the data field, so each line goes in a data field of its own
+	for _, line := range bytes.Split(event.Data, []byte("\n")) {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteComment sends a line clients ignore, it keeps idle connections from being timed out
+func WriteComment(w io.Writer, comment string) error {
+	_, err := fmt.Fprintf(w, ": %s\n\n", comment)
+	return err
+}
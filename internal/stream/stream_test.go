@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishFiltersByAuthor(t *testing.T) {
+
+	hub := NewHub()
+	author := uuid.New()
+
+	firehose, _ := hub.Subscribe(Filter{})
+	following, _ := hub.Subscribe(Filter{AuthorID: author})
+
+	hub.Publish(Event{ID: 1, Type: TypeChirpCreated, AuthorID: author})
+	hub.Publish(Event{ID: 2, Type: TypeChirpCreated, AuthorID: uuid.New()})
+	hub.Publish(Event{ID: 3, Type: TypeChirpCreated, AuthorID: author, Unlisted: true})
+
+	if got := drain(firehose); !equal(got, []int64{1, 2}) {
+		t.Errorf("expected the firehose to skip unlisted chirps, got %v", got)
+	}
+	if got := drain(following); !equal(got, []int64{1, 3}) {
+		t.Errorf("expected only the author's chirps, got %v", got)
+	}
+}
+
+func TestSlowConsumerIsDropped(t *testing.T) {
+
+	hub := NewHub()
+	hub.Buffer = 2
+
+	slow, _ := hub.Subscribe(Filter{})
+	fast, _ := hub.Subscribe(Filter{})
+
+	for i := int64(1); i <= 3; i++ {
+		hub.Publish(Event{ID: i})
+		if i < 3 {
+			<-fast.Events()
+		}
+	}
+
+	if got := drain(slow); !equal(got, []int64{1, 2}) {
+		t.Errorf("expected the queued events before the drop, got %v", got)
+	}
+	if _, ok := <-slow.Events(); ok || !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("expected the slow subscriber to be dropped, got %v", slow.Err())
+	}
+	if event := <-fast.Events(); event.ID != 3 {
+		t.Errorf("expected the fast subscriber to keep receiving, got %d", event.ID)
+	}
+	if hub.Len() != 1 {
+		t.Errorf("expected one subscriber left, got %d", hub.Len())
+	}
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+
+	hub := NewHub()
+	sub, _ := hub.Subscribe(Filter{})
+
+	left, _ := hub.Subscribe(Filter{})
+	left.Close()
+	left.Close()
+	if _, ok := <-left.Events(); ok || left.Err() != nil {
+		t.Errorf("expected a closed subscription to end cleanly, got %v", left.Err())
+	}
+
+	hub.Close()
+	if _, ok := <-sub.Events(); ok || !errors.Is(sub.Err(), ErrClosed) {
+		t.Errorf("expected closing the hub to end subscriptions, got %v", sub.Err())
+	}
+	if _, err := hub.Subscribe(Filter{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected a closed hub to refuse subscribers, got %v", err)
+	}
+	hub.Publish(Event{ID: 1})
+}
+
+func TestWrite(t *testing.T) {
+
+	buf := bytes.Buffer{}
+	Write(&buf, Event{ID: 7, Type: TypeChirpDeleted, Data: []byte("{\"id\":\n1}")})
+
+	want := "id: 7\nevent: chirp.deleted\ndata: {\"id\":\ndata: 1}\n\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	WriteComment(&buf, "heartbeat")
+	if buf.String() != ": heartbeat\n\n" {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func drain(sub *Subscription) []int64 {
+	ids := []int64{}
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, event.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+// Package subscription, works out what a Chirpy Red subscription looks like after each billing event
+package subscription
+
+import (
+	"errors"
+	"time"
+)
+
+// Tier is the plan a subscription is for
+type Tier string
+
+const (
+	TierFree Tier = "free"
+	TierRed  Tier = "red"
+)
+
+// Status is where a subscription is in its lifecycle
+type Status string
+
+const (
+	// StatusActive is paid up for the current period
+	StatusActive Status = "active"
+	// StatusPastDue missed a payment and keeps its perks until the grace period runs out
+	StatusPastDue Status = "past_due"
+	// StatusCanceled won't renew and keeps its perks until the end of the period already paid for
+	StatusCanceled Status = "canceled"
+	// StatusExpired has no perks left
+	StatusExpired Status = "expired"
+)
+
+// Event is a billing event from the payment provider
+type Event string
+
+const (
+	EventUpgraded      Event = "user.upgraded"
+	EventRenewed       Event = "user.renewed"
+	EventPaymentFailed Event = "user.payment_failed"
+	EventCanceled      Event = "user.canceled"
+	EventDowngraded    Event = "user.downgraded"
+)
+
+// Valid reports whether e is an event subscriptions know how to handle
+func (e Event) Valid() bool {
+	switch e {
+	case EventUpgraded, EventRenewed, EventPaymentFailed, EventCanceled, EventDowngraded:
+		return true
+	}
+	return false
+}
+
+const (
+	DefaultPeriod      = 30 * 24 * time.Hour
+	DefaultGracePeriod = 3 * 24 * time.Hour
+)
+
+var (
+	ErrUnknownEvent   = errors.New("unknown subscription event")
+	ErrNoSubscription = errors.New("user has no subscription")
+)
+
+// Subscription is one user's subscription
+type Subscription struct {
+	Tier        Tier
+	Status      Status
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// ExpiresAt is when the perks stop if nothing else happens, the period end plus any grace
+	ExpiresAt time.Time
+	// CanceledAt is zero unless the subscription was canceled
+	CanceledAt time.Time
+}
+
+// Entitled reports whether the subscription still carries its tier's perks at now
+func (s Subscription) Entitled(now time.Time) bool {
+	return s.Status != StatusExpired && now.Before(s.ExpiresAt)
+}
+
+// ActiveTier is the tier whose perks apply at now
+func (s Subscription) ActiveTier(now time.Time) Tier {
+	if !s.Entitled(now) {
+		return TierFree
+	}
+	return s.Tier
+}
+
+// Policy is how long periods and grace periods last
+type Policy struct {
+	Period      time.Duration
+	GracePeriod time.Duration
+}
+
+var DefaultPolicy = Policy{Period: DefaultPeriod, GracePeriod: DefaultGracePeriod}
+
+// Apply returns the subscription after event happens at now. current is nil for a user who has never
+// subscribed, and periodEnd is the end of the period the event paid for when the provider sends one.
+func (p Policy) Apply(current *Subscription, event Event, periodEnd, now time.Time) (Subscription, error) {
+
+	if !event.Valid() {
+		return Subscription{}, ErrUnknownEvent
+	}
+	if event == EventUpgraded {
+		return p.newPeriod(TierRed, now, periodEnd), nil
+	}
+	if current == nil {
+		return Subscription{}, ErrNoSubscription
+	}
+	next := *current
+
+	switch event {
+	case EventRenewed:
+		// a renewal that arrives on time continues from the old period, a late one starts afresh
+		start := now
+		if current.Entitled(now) && current.PeriodEnd.After(now) {
+			start = current.PeriodEnd
+		}
+		return p.newPeriod(current.Tier, start, periodEnd), nil
+
+	case EventPaymentFailed:
+		if !current.Entitled(now) || current.Status == StatusPastDue {
+			return next, nil
+		}
+		next.Status = StatusPastDue
+		next.ExpiresAt = later(now, current.PeriodEnd).Add(p.GracePeriod)
+
+	case EventCanceled:
+		if !current.Entitled(now) {
+			return next, nil
+		}
+		next.Status = StatusCanceled
+		next.CanceledAt = now
+		next.ExpiresAt = later(now, current.PeriodEnd)
+
+	case EventDowngraded:
+		next.Status = StatusExpired
+		next.ExpiresAt = now
+	}
+
+	return next, nil
+}
+
+// newPeriod is an active subscription for a period starting at start
+func (p Policy) newPeriod(tier Tier, start, periodEnd time.Time) Subscription {
+	if !periodEnd.After(start) {
+		periodEnd = start.Add(p.Period)
+	}
+	return Subscription{
+		Tier:        tier,
+		Status:      StatusActive,
+		PeriodStart: start,
+		PeriodEnd:   periodEnd,
+		ExpiresAt:   periodEnd.Add(p.GracePeriod),
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
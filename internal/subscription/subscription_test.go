@@ -0,0 +1,85 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+
+	policy := Policy{Period: 30 * 24 * time.Hour, GracePeriod: 3 * 24 * time.Hour}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	sub, err := policy.Apply(nil, EventUpgraded, time.Time{}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != StatusActive || !sub.PeriodEnd.Equal(start.Add(30*day)) || !sub.ExpiresAt.Equal(start.Add(33*day)) {
+		t.Fatalf("unexpected subscription after upgrade: %+v", sub)
+	}
+	if sub.ActiveTier(start) != TierRed {
+		t.Error("expected an upgraded user to be on red")
+	}
+
+	renewed, err := policy.Apply(&sub, EventRenewed, time.Time{}, start.Add(29*day))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.PeriodStart.Equal(sub.PeriodEnd) || !renewed.PeriodEnd.Equal(start.Add(60*day)) {
+		t.Errorf("expected an on time renewal to continue the period, got %+v", renewed)
+	}
+
+	failed, _ := policy.Apply(&renewed, EventPaymentFailed, time.Time{}, start.Add(61*day))
+	if failed.Status != StatusPastDue || !failed.ExpiresAt.Equal(start.Add(64*day)) {
+		t.Errorf("expected a failed payment to start the grace period, got %+v", failed)
+	}
+	if !failed.Entitled(start.Add(63*day)) || failed.Entitled(start.Add(64*day)) {
+		t.Error("expected perks to last exactly until the grace period ends")
+	}
+	again, _ := policy.Apply(&failed, EventPaymentFailed, time.Time{}, start.Add(63*day))
+	if !again.ExpiresAt.Equal(failed.ExpiresAt) {
+		t.Error("expected repeated payment failures not to extend the grace period")
+	}
+
+	late, _ := policy.Apply(&failed, EventRenewed, time.Time{}, start.Add(70*day))
+	if late.Status != StatusActive || !late.PeriodStart.Equal(start.Add(70*day)) {
+		t.Errorf("expected a late renewal to start a fresh period, got %+v", late)
+	}
+
+	canceled, _ := policy.Apply(&sub, EventCanceled, time.Time{}, start.Add(10*day))
+	if canceled.Status != StatusCanceled || !canceled.ExpiresAt.Equal(sub.PeriodEnd) || canceled.CanceledAt.IsZero() {
+		t.Errorf("expected a canceled subscription to run to the end of its period, got %+v", canceled)
+	}
+
+	downgraded, _ := policy.Apply(&sub, EventDowngraded, time.Time{}, start.Add(10*day))
+	if downgraded.Entitled(start.Add(10*day)) || downgraded.ActiveTier(start.Add(10*day)) != TierFree {
+		t.Errorf("expected a downgrade to end perks straight away, got %+v", downgraded)
+	}
+}
+
+func TestProviderPeriodEnd(t *testing.T) {
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := now.Add(365 * 24 * time.Hour)
+
+	sub, err := DefaultPolicy.Apply(nil, EventUpgraded, end, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sub.PeriodEnd.Equal(end) {
+		t.Errorf("expected the provider's period end to be used, got %v", sub.PeriodEnd)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+
+	now := time.Now()
+	if _, err := DefaultPolicy.Apply(nil, EventRenewed, time.Time{}, now); !errors.Is(err, ErrNoSubscription) {
+		t.Errorf("expected ErrNoSubscription, got %v", err)
+	}
+	if _, err := DefaultPolicy.Apply(nil, Event("user.teleported"), time.Time{}, now); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("expected ErrUnknownEvent, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package visibility, decides which chirps a viewer is allowed to see
+package visibility
+
+import "errors"
+
+type Level string
+
+const (
+	Public    Level = "public"
+	Followers Level = "followers"
+	Unlisted  Level = "unlisted"
+	Private   Level = "private"
+)
+
+// ParseLevel reads a visibility from user input, an empty string means Public
+func ParseLevel(level string) (Level, error) {
+	switch Level(level) {
+	case "":
+		return Public, nil
+	case Public, Followers, Unlisted, Private:
+		return Level(level), nil
+	}
+	return "", errors.New("visibility must be one of public, followers, unlisted or private")
+}
+
+// Surface is where a chirp is being shown
+type Surface int
+
+const (
+	// SurfaceDirect is a single chirp fetched by id, or acted on by id
+	SurfaceDirect Surface = iota
+	// SurfaceTimeline is a view scoped to chosen authors, like a profile or a list
+	SurfaceTimeline
+	// SurfacePublicFeed is the global firehose of chirps
+	SurfacePublicFeed
+)
+
+// Viewer is how the person looking relates to the chirp's author
+type Viewer struct {
+	IsAuthor      bool
+	FollowsAuthor bool
+}
+
+// Chirp is the part of a chirp and its author that visibility depends on
+type Chirp struct {
+	Level           Level
+	AuthorProtected bool
+}
+
+// CanView reports whether viewer may see chirp on surface.
+//
+//   - authors always see their own chirps
+//   - private chirps are only for their author
+//   - a protected author's chirps are only for approved followers
+//   - followers chirps are only for approved followers
+//   - unlisted chirps are open to anyone but stay out of the public feed
+func CanView(chirp Chirp, viewer Viewer, surface Surface) bool {
+
+	if viewer.IsAuthor {
+		return true
+	}
+
+	if chirp.Level == Private {
+		return false
+	}
+
+	if chirp.AuthorProtected && !viewer.FollowsAuthor {
+		return false
+	}
+
+	switch chirp.Level {
+	case Followers:
+		return viewer.FollowsAuthor
+	case Unlisted:
+		return surface != SurfacePublicFeed
+	case Public:
+		return true
+	}
+
+	// unknown levels fail closed
+	return false
+}
@@ -0,0 +1,92 @@
+package visibility
+
+import "testing"
+
+func TestCanView(t *testing.T) {
+
+	stranger := Viewer{}
+	follower := Viewer{FollowsAuthor: true}
+	author := Viewer{IsAuthor: true}
+
+	surfaces := map[string]Surface{
+		"direct":   SurfaceDirect,
+		"timeline": SurfaceTimeline,
+		"feed":     SurfacePublicFeed,
+	}
+
+	tests := []struct {
+		name      string
+		chirp     Chirp
+		viewer    Viewer
+		visibleOn []string
+	}{
+		{"public stranger", Chirp{Level: Public}, stranger, []string{"direct", "timeline", "feed"}},
+		{"public follower", Chirp{Level: Public}, follower, []string{"direct", "timeline", "feed"}},
+		{"public author", Chirp{Level: Public}, author, []string{"direct", "timeline", "feed"}},
+
+		{"unlisted stranger", Chirp{Level: Unlisted}, stranger, []string{"direct", "timeline"}},
+		{"unlisted follower", Chirp{Level: Unlisted}, follower, []string{"direct", "timeline"}},
+		{"unlisted author", Chirp{Level: Unlisted}, author, []string{"direct", "timeline", "feed"}},
+
+		{"followers stranger", Chirp{Level: Followers}, stranger, []string{}},
+		{"followers follower", Chirp{Level: Followers}, follower, []string{"direct", "timeline", "feed"}},
+		{"followers author", Chirp{Level: Followers}, author, []string{"direct", "timeline", "feed"}},
+
+		{"private stranger", Chirp{Level: Private}, stranger, []string{}},
+		{"private follower", Chirp{Level: Private}, follower, []string{}},
+		{"private author", Chirp{Level: Private}, author, []string{"direct", "timeline", "feed"}},
+
+		{"protected public stranger", Chirp{Level: Public, AuthorProtected: true}, stranger, []string{}},
+		{"protected public follower", Chirp{Level: Public, AuthorProtected: true}, follower, []string{"direct", "timeline", "feed"}},
+		{"protected unlisted stranger", Chirp{Level: Unlisted, AuthorProtected: true}, stranger, []string{}},
+		{"protected unlisted follower", Chirp{Level: Unlisted, AuthorProtected: true}, follower, []string{"direct", "timeline"}},
+		{"protected followers follower", Chirp{Level: Followers, AuthorProtected: true}, follower, []string{"direct", "timeline", "feed"}},
+		{"protected private follower", Chirp{Level: Private, AuthorProtected: true}, follower, []string{}},
+		{"protected private author", Chirp{Level: Private, AuthorProtected: true}, author, []string{"direct", "timeline", "feed"}},
+
+		{"unknown level stranger", Chirp{Level: "secret"}, stranger, []string{}},
+		{"unknown level follower", Chirp{Level: "secret"}, follower, []string{}},
+		{"unknown level author", Chirp{Level: "secret"}, author, []string{"direct", "timeline", "feed"}},
+	}
+
+	for _, tc := range tests {
+		visible := map[string]bool{}
+		for _, surface := range tc.visibleOn {
+			visible[surface] = true
+		}
+
+		for name, surface := range surfaces {
+			got := CanView(tc.chirp, tc.viewer, surface)
+			if got != visible[name] {
+				t.Errorf(`%s on %s: expected %v, got %v`, tc.name, name, visible[name], got)
+			}
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+
+	tests := []struct {
+		input   string
+		want    Level
+		wantErr bool
+	}{
+		{"", Public, false},
+		{"public", Public, false},
+		{"followers", Followers, false},
+		{"unlisted", Unlisted, false},
+		{"private", Private, false},
+		{"PUBLIC", "", true},
+		{"friends", "", true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseLevel(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf(`ParseLevel(%q) error = %v, wantErr %v`, tc.input, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf(`ParseLevel(%q) = %q, want %q`, tc.input, got, tc.want)
+		}
+	}
+}
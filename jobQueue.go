@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/jobs"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/google/uuid"
+)
+
+// kinds of background job
+const (
+	jobPublishScheduledChirps = "publish_scheduled_chirps"
+	jobPurgeTrashedChirps     = "purge_trashed_chirps"
+	jobExpireSubscriptions    = "expire_subscriptions"
+	jobDispatchWebhooks       = "dispatch_webhooks"
+	jobPurgeFinishedJobs      = "purge_finished_jobs"
+)
+
+const (
+	jobPurgeInterval = time.Hour
+	// how long completed jobs are kept for inspection, dead ones are kept until retried
+	jobRetention = 24 * time.Hour
+)
+
+type BackgroundJob struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+func jobFromDB(job database.Job) BackgroundJob {
+	formatted := BackgroundJob{
+		ID:          job.ID,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LastError:   job.LastError.String,
+	}
+	if job.LockedUntil.Valid {
+		formatted.LockedUntil = &job.LockedUntil.Time
+	}
+	if job.FinishedAt.Valid {
+		formatted.FinishedAt = &job.FinishedAt.Time
+	}
+	return formatted
+}
+
+// jobStore keeps the job queue in the jobs table
+type jobStore struct {
+	db *database.Queries
+}
+
+func (s jobStore) Enqueue(ctx context.Context, job jobs.NewJob) error {
+	now := time.Now()
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = now
+	}
+	return s.db.EnqueueJob(ctx, database.EnqueueJobParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		MaxAttempts: int32(job.MaxAttempts),
+		RunAt:       runAt,
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+	})
+}
+
+func (s jobStore) Claim(ctx context.Context, kinds []string, now, leaseUntil time.Time) (jobs.Job, bool, error) {
+	claimed, err := s.db.ClaimJob(ctx, database.ClaimJobParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		Kinds:      kinds,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return jobs.Job{}, false, nil
+	}
+	if err != nil {
+		return jobs.Job{}, false, err
+	}
+	return jobs.Job{
+		ID:          claimed.ID,
+		Kind:        claimed.Kind,
+		Payload:     claimed.Payload,
+		Attempts:    int(claimed.Attempts),
+		MaxAttempts: int(claimed.MaxAttempts),
+	}, true, nil
+}
+
+func (s jobStore) Complete(ctx context.Context, job jobs.Job, now time.Time) error {
+	return s.db.CompleteJob(ctx, database.CompleteJobParams{Now: now, ID: job.ID})
+}
+
+func (s jobStore) Fail(ctx context.Context, job jobs.Job, err error, retryAt time.Time, dead bool) error {
+	return s.db.FailJob(ctx, database.FailJobParams{
+		Dead:      dead,
+		RunAt:     retryAt,
+		LastError: err.Error(),
+		Now:       time.Now(),
+		ID:        job.ID,
+	})
+}
+
+// newJobRunner registers the server's background work. Every instance runs the same
+// recurring jobs, the queue makes sure each slot is worked once.
+func (cfg *apiConfig) newJobRunner() *jobs.Runner {
+
+	runner := jobs.NewRunner(jobStore{db: cfg.db})
+
+	dispatcher := outbound.NewDispatcher(webhookOutbox{db: cfg.db, dbConn: cfg.dbConn}, outbound.NewSender(outbound.DefaultTimeout, cfg.webhookGuard))
+
+	jobs.Register(runner, jobPublishScheduledChirps, cfg.publishDueChirps)
+	jobs.Register(runner, jobPurgeTrashedChirps, cfg.purgeTrashedChirps)
+	jobs.Register(runner, jobExpireSubscriptions, cfg.expireSubscriptions)
+	jobs.Register(runner, jobDispatchWebhooks, func(ctx context.Context, _ struct{}) error {
+		return dispatchWebhooks(ctx, dispatcher)
+	})
+	jobs.Register(runner, jobPurgeFinishedJobs, cfg.purgeFinishedJobs)
+	jobs.Register(runner, jobPurgeChirpStream, cfg.purgeChirpStream)
+
+	runner.Every(jobPublishScheduledChirps, schedulerInterval)
+	runner.Every(jobPurgeTrashedChirps, chirpPurgeInterval)
+	runner.Every(jobExpireSubscriptions, subscriptionExpiryInterval)
+	runner.Every(jobDispatchWebhooks, webhookDispatchInterval)
+	runner.Every(jobPurgeFinishedJobs, jobPurgeInterval)
+	runner.Every(jobPurgeChirpStream, jobPurgeInterval)
+
+	return runner
+}
+
+// purgeFinishedJobs is the purge_finished_jobs job, recurring jobs leave a row behind every slot
+func (cfg *apiConfig) purgeFinishedJobs(ctx context.Context, _ struct{}) error {
+	_, err := cfg.db.PurgeFinishedJobs(ctx, time.Now().Add(-jobRetention))
+	return err
+}
+
+func (cfg *apiConfig) handlerGetJobs(w http.ResponseWriter, req *http.Request) {
+
+	query := req.URL.Query()
+
+	status := sql.NullString{}
+	switch raw := query.Get("status"); jobs.Status(raw) {
+	case "":
+	case jobs.StatusPending, jobs.StatusRunning, jobs.StatusCompleted, jobs.StatusDead:
+		status = sql.NullString{String: raw, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be pending, running, completed or dead", nil)
+		return
+	}
+	kind := sql.NullString{String: query.Get("kind"), Valid: query.Get("kind") != ""}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetJobs(req.Context(), database.GetJobsParams{
+		Status:     status,
+		Kind:       kind,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve jobs", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	formatted := []BackgroundJob{}
+	for _, job := range unformatted {
+		formatted = append(formatted, jobFromDB(job))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+func (cfg *apiConfig) handlerGetJob(w http.ResponseWriter, req *http.Request) {
+
+	jobID, err := uuid.Parse(req.PathValue("jobID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid job id", err)
+		return
+	}
+
+	job, err := cfg.db.GetJob(req.Context(), jobID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "job does not exist", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jobFromDB(job))
+}
+
+// handlerRetryJob puts a dead job back in the queue to run as soon as a worker is free
+func (cfg *apiConfig) handlerRetryJob(w http.ResponseWriter, req *http.Request) {
+
+	jobID, err := uuid.Parse(req.PathValue("jobID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid job id", err)
+		return
+	}
+
+	job, err := cfg.db.RetryJob(req.Context(), database.RetryJobParams{
+		RunAt: time.Now(),
+		ID:    jobID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.db.GetJob(req.Context(), jobID); err != nil {
+			respondWithError(w, http.StatusNotFound, "job does not exist", err)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "only dead jobs can be retried", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retry job", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, jobFromDB(job))
+}
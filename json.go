@@ -24,6 +24,20 @@ func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
 	})
 }
 
+// respondWithFieldErrors is a 400 that says what was wrong with each bad query param or field
+func respondWithFieldErrors(w http.ResponseWriter, fields map[string]string) {
+
+	type errorResponse struct {
+		Error		string `json:"error"`
+		Fields		map[string]string `json:"fields"`
+	}
+
+	respondWithJSON(w, http.StatusBadRequest, errorResponse{
+		Error: "invalid parameters",
+		Fields: fields,
+	})
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload any){
 
 	w.Header().Set("Content-Type", "applications/json")
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const maxListNameLength = 64
+
+type List struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	OwnerID   uuid.UUID `json:"owner_id"`
+	Name      string    `json:"name"`
+}
+
+type ListMember struct {
+	UserID  uuid.UUID `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+func listFromDB(list database.List) List {
+	return List{
+		ID:        list.ID,
+		CreatedAt: list.CreatedAt,
+		UpdatedAt: list.UpdatedAt,
+		OwnerID:   list.OwnerID,
+		Name:      list.Name,
+	}
+}
+
+func validateListName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	return name, name != "" && utf8.RuneCountInString(name) <= maxListNameLength
+}
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.CreateBookmark(req.Context(), database.CreateBookmarkParams{
+		UserID:    userID,
+		ChirpID:   chirp.ID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not bookmark chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteBookmark(req.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove bookmark", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	listParams := database.GetBookmarkedChirpsParams{
+		UserID:     userID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	}
+
+	rows, err := cfg.db.GetBookmarkedChirps(req.Context(), listParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve bookmarks", err)
+		return
+	}
+	rows = reversePage(p, rows)
+
+	bookmarked := []database.Chirp{}
+	for _, row := range rows {
+		bookmarked = append(bookmarked, row.Chirp)
+	}
+
+	// a bookmarked chirp can go out of reach later, e.g. its author made it private
+	bookmarked, err = cfg.filterVisibleChirps(req.Context(), userID, bookmarked, visibility.SurfaceDirect)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve bookmarks", err)
+		return
+	}
+
+	chirps := []Chirp{}
+	for _, chirp := range bookmarked {
+		chirps = append(chirps, chirpFromDB(chirp))
+	}
+
+	err = cfg.attachPolls(req.Context(), chirps, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve polls", err)
+		return
+	}
+
+	nextCursor, prevCursor := p.cursors(len(rows), func(i int) string {
+		return encodeCursor(rows[i].BookmarkedAt, rows[i].Chirp.ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+		PrevCursor string  `json:"prev_cursor,omitempty"`
+	}{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+// listForOwner authenticates the caller and loads the list in the url,
+// lists are private so one owned by someone else is reported as missing
+func (cfg *apiConfig) listForOwner(w http.ResponseWriter, req *http.Request) (database.List, bool) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return database.List{}, false
+	}
+
+	listID, err := uuid.Parse(req.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract list id from url", err)
+		return database.List{}, false
+	}
+
+	list, err := cfg.db.GetListForOwner(req.Context(), database.GetListForOwnerParams{
+		ID:      listID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "list does not exist", err)
+		return database.List{}, false
+	}
+
+	return list, true
+}
+
+func (cfg *apiConfig) handlerCreateList(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	name, ok := validateListName(params.Name)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "list name must be 1-64 characters", nil)
+		return
+	}
+
+	list, err := cfg.db.CreateList(req.Context(), database.CreateListParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		OwnerID:   userID,
+		Name:      name,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "you already have a list with that name", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not save list", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, listFromDB(list))
+}
+
+func (cfg *apiConfig) handlerGetLists(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetListsByOwner(req.Context(), database.GetListsByOwnerParams{
+		OwnerID:    userID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve lists", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	lists := []List{}
+	for _, list := range unformatted {
+		lists = append(lists, listFromDB(list))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Lists      []List `json:"lists"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}{
+		Lists:      lists,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerGetList(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listFromDB(list))
+}
+
+func (cfg *apiConfig) handlerRenameList(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	name, valid := validateListName(params.Name)
+	if !valid {
+		respondWithError(w, http.StatusBadRequest, "list name must be 1-64 characters", nil)
+		return
+	}
+
+	renamed, err := cfg.db.RenameList(req.Context(), database.RenameListParams{
+		Name:      name,
+		UpdatedAt: time.Now(),
+		ID:        list.ID,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "you already have a list with that name", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not save list", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listFromDB(renamed))
+}
+
+func (cfg *apiConfig) handlerDeleteList(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteList(req.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete list", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetListMembers(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetListMembersPage(req.Context(), database.GetListMembersPageParams{
+		ListID:     list.ID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve list members", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].AddedAt, unformatted[i].UserID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	members := []ListMember{}
+	for _, member := range unformatted {
+		members = append(members, ListMember{
+			UserID:  member.UserID,
+			AddedAt: member.AddedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Members    []ListMember `json:"members"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+		PrevCursor string       `json:"prev_cursor,omitempty"`
+	}{
+		Members:    members,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerAddListMember(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(req.Context(), params.UserID); err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	}
+
+	err = cfg.db.AddListMember(req.Context(), database.AddListMemberParams{
+		ListID:  list.ID,
+		UserID:  params.UserID,
+		AddedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not add list member", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveListMember(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	err = cfg.db.RemoveListMember(req.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove list member", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetListTimeline(w http.ResponseWriter, req *http.Request) {
+
+	list, ok := cfg.listForOwner(w, req)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.db.GetListMembers(req.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve list members", err)
+		return
+	}
+
+	authorIDs := []uuid.UUID{}
+	for _, member := range members {
+		authorIDs = append(authorIDs, member.UserID)
+	}
+
+	filter, p, fields := parseChirpTimeline(req, false)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
+	filter.AuthorIDs = authorIDs
+
+	cfg.respondWithChirpsPage(w, req, filter, p, visibility.SurfaceTimeline)
+}
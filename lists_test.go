@@ -0,0 +1,525 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestValidateListName(t *testing.T) {
+
+	cases := []struct {
+		name  string
+		want  string
+		valid bool
+	}{
+		{"Friends", "Friends", true},
+		{"  padded  ", "padded", true},
+		{strings.Repeat("a", maxListNameLength), strings.Repeat("a", maxListNameLength), true},
+		{strings.Repeat("é", maxListNameLength), strings.Repeat("é", maxListNameLength), true},
+		{"", "", false},
+		{"   ", "", false},
+		{strings.Repeat("a", maxListNameLength+1), strings.Repeat("a", maxListNameLength+1), false},
+	}
+
+	for _, c := range cases {
+		got, valid := validateListName(c.name)
+		if got != c.want || valid != c.valid {
+			t.Errorf("%q: expected %q %v, got %q %v", c.name, c.want, c.valid, got, valid)
+		}
+	}
+}
+
+func TestBookmarkChirp(t *testing.T) {
+
+	user := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello", UserID: uuid.New(), Visibility: "public"}
+
+	cases := []struct {
+		name    string
+		handler func(cfg *apiConfig) http.HandlerFunc
+		method  string
+		query   string
+	}{
+		{"bookmark", func(cfg *apiConfig) http.HandlerFunc { return cfg.handlerBookmarkChirp }, http.MethodPost, "CreateBookmark"},
+		{"remove", func(cfg *apiConfig) http.HandlerFunc { return cfg.handlerRemoveBookmark }, http.MethodDelete, "DeleteBookmark"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.returns("GetOneChirp", row(chirp))
+
+			req := authorizedRequest(t, user, c.method, "/api/chirps/"+chirp.ID.String()+"/bookmark", "")
+			req.SetPathValue("chirpID", chirp.ID.String())
+			w := httptest.NewRecorder()
+			c.handler(cfg)(w, req)
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("expected 204, got %d %s", w.Code, w.Body)
+			}
+			calls := fake.calledWith(c.query)
+			if len(calls) != 1 || calls[0][0] != user.String() || calls[0][1] != chirp.ID.String() {
+				t.Errorf("expected one %s for the user and chirp, got %v", c.query, calls)
+			}
+		})
+
+		t.Run(c.name+" missing chirp", func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+
+			missing := uuid.New()
+			req := authorizedRequest(t, user, c.method, "/api/chirps/"+missing.String()+"/bookmark", "")
+			req.SetPathValue("chirpID", missing.String())
+			w := httptest.NewRecorder()
+			c.handler(cfg)(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("expected 404, got %d %s", w.Code, w.Body)
+			}
+			if calls := fake.calledWith(c.query); len(calls) != 0 {
+				t.Errorf("expected no %s, got %v", c.query, calls)
+			}
+		})
+	}
+}
+
+func TestGetBookmarks(t *testing.T) {
+
+	user := uuid.New()
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	first := database.Chirp{ID: uuid.New(), CreatedAt: start, UpdatedAt: start, Body: "first", UserID: uuid.New(), Visibility: "public"}
+	second := database.Chirp{ID: uuid.New(), CreatedAt: start, UpdatedAt: start, Body: "second", UserID: uuid.New(), Visibility: "public"}
+	bookmarked := []driver.Value{
+		start.Add(2 * time.Minute),
+		start.Add(time.Minute),
+	}
+
+	type page struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor"`
+	}
+
+	t.Run("full page", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetBookmarkedChirps", row(first, bookmarked[0]), row(second, bookmarked[1]))
+
+		w := httptest.NewRecorder()
+		cfg.handlerGetBookmarks(w, authorizedRequest(t, user, http.MethodGet, "/api/bookmarks?limit=2", ""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+		}
+		got := page{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Chirps) != 2 || got.Chirps[0].ID != first.ID || got.Chirps[1].ID != second.ID {
+			t.Errorf("expected both chirps in order, got %+v", got.Chirps)
+		}
+		if want := encodeCursor(start.Add(time.Minute), second.ID); got.NextCursor != want {
+			t.Errorf("expected cursor at the last bookmark %q, got %q", want, got.NextCursor)
+		}
+		if links := w.Header().Values("Link"); len(links) != 1 || !strings.Contains(links[0], `rel="next"`) {
+			t.Errorf("expected a next Link header, got %v", links)
+		}
+
+		calls := fake.calledWith("GetBookmarkedChirps")
+		if len(calls) != 1 || calls[0][0] != user.String() || calls[0][1] != nil || calls[0][2] != false || calls[0][4] != int64(2) {
+			t.Errorf("expected a first page of 2 for the user, got %v", calls)
+		}
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetBookmarkedChirps", row(second, bookmarked[1]))
+
+		cursor := encodeCursor(start.Add(2*time.Minute), first.ID)
+		w := httptest.NewRecorder()
+		cfg.handlerGetBookmarks(w, authorizedRequest(t, user, http.MethodGet, "/api/bookmarks?limit=2&cursor="+cursor, ""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+		}
+		got := page{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Chirps) != 1 || got.NextCursor != "" {
+			t.Errorf("expected one chirp and no cursor, got %+v", got)
+		}
+
+		calls := fake.calledWith("GetBookmarkedChirps")
+		if len(calls) != 1 || calls[0][2] != false || calls[0][3] != first.ID.String() {
+			t.Errorf("expected the page to start after the cursor, got %v", calls)
+		}
+	})
+
+	t.Run("bad cursor", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+
+		w := httptest.NewRecorder()
+		cfg.handlerGetBookmarks(w, authorizedRequest(t, user, http.MethodGet, "/api/bookmarks?cursor=nonsense", ""))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d %s", w.Code, w.Body)
+		}
+		if calls := fake.calledWith("GetBookmarkedChirps"); len(calls) != 0 {
+			t.Errorf("expected no query, got %v", calls)
+		}
+	})
+}
+
+func TestCreateList(t *testing.T) {
+
+	owner := uuid.New()
+
+	cases := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"created", `{"name":"  Friends  "}`, nil, http.StatusCreated},
+		{"blank name", `{"name":"   "}`, nil, http.StatusBadRequest},
+		{"long name", `{"name":"` + strings.Repeat("a", maxListNameLength+1) + `"}`, nil, http.StatusBadRequest},
+		{"duplicate", `{"name":"Friends"}`, &pq.Error{Code: "23505"}, http.StatusConflict},
+		{"not saved", `{"name":"Friends"}`, errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("CreateList", func(args []driver.Value) fakeResult {
+				if c.err != nil {
+					return fakeResult{err: c.err}
+				}
+				return echoArgs(args)
+			})
+
+			w := httptest.NewRecorder()
+			cfg.handlerCreateList(w, authorizedRequest(t, owner, http.MethodPost, "/api/lists", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			if c.status != http.StatusCreated {
+				return
+			}
+
+			got := List{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != "Friends" || got.OwnerID != owner {
+				t.Errorf("expected the trimmed list for the owner, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestGetLists(t *testing.T) {
+
+	owner := uuid.New()
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	lists := []database.List{
+		{ID: uuid.New(), CreatedAt: start, UpdatedAt: start, OwnerID: owner, Name: "Friends"},
+		{ID: uuid.New(), CreatedAt: start.Add(time.Minute), UpdatedAt: start, OwnerID: owner, Name: "Work"},
+	}
+	cursor := encodeCursor(start.Add(-time.Minute), uuid.New())
+
+	cases := []struct {
+		name     string
+		query    string
+		rows     [][]driver.Value
+		backward bool
+		next     string
+		prev     string
+		links    int
+	}{
+		{name: "first page", query: "limit=2", rows: [][]driver.Value{row(lists[0]), row(lists[1])},
+			next: encodeCursor(lists[1].CreatedAt, lists[1].ID), links: 1},
+		{name: "last page", query: "limit=3&after=" + cursor, rows: [][]driver.Value{row(lists[0]), row(lists[1])},
+			prev: encodeCursor(lists[0].CreatedAt, lists[0].ID), links: 1},
+		{name: "backward page comes newest first", query: "limit=2&before=" + cursor, rows: [][]driver.Value{row(lists[1]), row(lists[0])}, backward: true,
+			next: encodeCursor(lists[1].CreatedAt, lists[1].ID), prev: encodeCursor(lists[0].CreatedAt, lists[0].ID), links: 2},
+		{name: "no lists"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.returns("GetListsByOwner", c.rows...)
+
+			w := httptest.NewRecorder()
+			cfg.handlerGetLists(w, authorizedRequest(t, owner, http.MethodGet, "/api/lists?"+c.query, ""))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+			}
+			got := struct {
+				Lists      []List `json:"lists"`
+				NextCursor string `json:"next_cursor"`
+				PrevCursor string `json:"prev_cursor"`
+			}{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Lists == nil {
+				t.Errorf("expected lists to be an array, got %s", w.Body)
+			}
+			if len(c.rows) > 0 && (len(got.Lists) != 2 || got.Lists[0].ID != lists[0].ID || got.Lists[1].ID != lists[1].ID) {
+				t.Errorf("expected the owner's lists oldest first, got %+v", got.Lists)
+			}
+			if got.NextCursor != c.next || got.PrevCursor != c.prev {
+				t.Errorf("expected next %q prev %q, got %q %q", c.next, c.prev, got.NextCursor, got.PrevCursor)
+			}
+			if links := w.Header().Values("Link"); len(links) != c.links {
+				t.Errorf("expected %d Link headers, got %v", c.links, links)
+			}
+
+			calls := fake.calledWith("GetListsByOwner")
+			if len(calls) != 1 || calls[0][0] != owner.String() || calls[0][2] != c.backward {
+				t.Errorf("expected the owner's lists with backward %v, got %v", c.backward, calls)
+			}
+		})
+	}
+}
+
+// listRequest builds a request by owner against the list at target with the list id set
+func listRequest(t *testing.T, owner, listID uuid.UUID, method, target, body string) *http.Request {
+	req := authorizedRequest(t, owner, method, target, body)
+	req.SetPathValue("listID", listID.String())
+	return req
+}
+
+func TestListsArePrivate(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	stranger := uuid.New()
+	listID := uuid.New()
+
+	handlers := map[string]http.HandlerFunc{
+		"get":      cfg.handlerGetList,
+		"rename":   cfg.handlerRenameList,
+		"delete":   cfg.handlerDeleteList,
+		"members":  cfg.handlerGetListMembers,
+		"add":      cfg.handlerAddListMember,
+		"remove":   cfg.handlerRemoveListMember,
+		"timeline": cfg.handlerGetListTimeline,
+	}
+
+	for name, handler := range handlers {
+		w := httptest.NewRecorder()
+		handler(w, listRequest(t, stranger, listID, http.MethodGet, "/api/lists/"+listID.String(), `{"name":"Mine"}`))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d %s", name, w.Code, w.Body)
+		}
+	}
+
+	for _, call := range fake.calledWith("GetListForOwner") {
+		if call[0] != listID.String() || call[1] != stranger.String() {
+			t.Errorf("expected the list to be looked up for the caller, got %v", call)
+		}
+	}
+	for _, query := range []string{"RenameList", "DeleteList", "GetListMembers", "GetListMembersPage", "AddListMember", "RemoveListMember"} {
+		if calls := fake.calledWith(query); len(calls) != 0 {
+			t.Errorf("expected no %s, got %v", query, calls)
+		}
+	}
+}
+
+func TestRenameList(t *testing.T) {
+
+	owner := uuid.New()
+	list := database.List{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), OwnerID: owner, Name: "Friends"}
+
+	cases := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"renamed", `{"name":" Family "}`, nil, http.StatusOK},
+		{"blank name", `{"name":""}`, nil, http.StatusBadRequest},
+		{"duplicate", `{"name":"Family"}`, &pq.Error{Code: "23505"}, http.StatusConflict},
+		{"not saved", `{"name":"Family"}`, errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.returns("GetListForOwner", row(list))
+			fake.answer("RenameList", func(args []driver.Value) fakeResult {
+				if c.err != nil {
+					return fakeResult{err: c.err}
+				}
+				renamed := list
+				renamed.Name = args[0].(string)
+				return fakeResult{rows: [][]driver.Value{row(renamed)}}
+			})
+
+			w := httptest.NewRecorder()
+			cfg.handlerRenameList(w, listRequest(t, owner, list.ID, http.MethodPut, "/api/lists/"+list.ID.String(), c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			got := List{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != list.ID || got.Name != "Family" {
+				t.Errorf("expected the renamed list, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDeleteList(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	owner := uuid.New()
+	list := database.List{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), OwnerID: owner, Name: "Friends"}
+	fake.returns("GetListForOwner", row(list))
+
+	w := httptest.NewRecorder()
+	cfg.handlerDeleteList(w, listRequest(t, owner, list.ID, http.MethodDelete, "/api/lists/"+list.ID.String(), ""))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d %s", w.Code, w.Body)
+	}
+	if calls := fake.calledWith("DeleteList"); len(calls) != 1 || calls[0][0] != list.ID.String() {
+		t.Errorf("expected the list to be deleted, got %v", calls)
+	}
+}
+
+func TestListMembers(t *testing.T) {
+
+	owner := uuid.New()
+	member := testUser()
+	list := database.List{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), OwnerID: owner, Name: "Friends"}
+
+	t.Run("add", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetListForOwner", row(list))
+		fake.answer("GetUserByID", usersByID(member))
+
+		w := httptest.NewRecorder()
+		cfg.handlerAddListMember(w, listRequest(t, owner, list.ID, http.MethodPost, "/api/lists/"+list.ID.String()+"/members",
+			`{"user_id":"`+member.ID.String()+`"}`))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d %s", w.Code, w.Body)
+		}
+		calls := fake.calledWith("AddListMember")
+		if len(calls) != 1 || calls[0][0] != list.ID.String() || calls[0][1] != member.ID.String() {
+			t.Errorf("expected the member to be added to the list, got %v", calls)
+		}
+	})
+
+	t.Run("add nobody", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetListForOwner", row(list))
+
+		w := httptest.NewRecorder()
+		cfg.handlerAddListMember(w, listRequest(t, owner, list.ID, http.MethodPost, "/api/lists/"+list.ID.String()+"/members",
+			`{"user_id":"`+uuid.NewString()+`"}`))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d %s", w.Code, w.Body)
+		}
+		if calls := fake.calledWith("AddListMember"); len(calls) != 0 {
+			t.Errorf("expected no member to be added, got %v", calls)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetListForOwner", row(list))
+
+		req := listRequest(t, owner, list.ID, http.MethodDelete, "/api/lists/"+list.ID.String()+"/members/"+member.ID.String(), "")
+		req.SetPathValue("userID", member.ID.String())
+		w := httptest.NewRecorder()
+		cfg.handlerRemoveListMember(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d %s", w.Code, w.Body)
+		}
+		calls := fake.calledWith("RemoveListMember")
+		if len(calls) != 1 || calls[0][0] != list.ID.String() || calls[0][1] != member.ID.String() {
+			t.Errorf("expected the member to be removed from the list, got %v", calls)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("GetListForOwner", row(list))
+		added := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		fake.returns("GetListMembersPage", row(database.ListMember{ListID: list.ID, UserID: member.ID, AddedAt: added}))
+
+		w := httptest.NewRecorder()
+		cfg.handlerGetListMembers(w, listRequest(t, owner, list.ID, http.MethodGet, "/api/lists/"+list.ID.String()+"/members", ""))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+		}
+		got := struct {
+			Members    []ListMember `json:"members"`
+			NextCursor string       `json:"next_cursor"`
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Members) != 1 || got.Members[0].UserID != member.ID || !got.Members[0].AddedAt.Equal(added) {
+			t.Errorf("expected the member, got %+v", got.Members)
+		}
+	})
+}
+
+func TestGetListTimeline(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	owner := uuid.New()
+	members := []uuid.UUID{uuid.New(), uuid.New()}
+	list := database.List{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), OwnerID: owner, Name: "Friends"}
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello", UserID: members[0], Visibility: "public"}
+
+	fake.returns("GetListForOwner", row(list))
+	fake.returns("GetListMembers",
+		row(database.ListMember{ListID: list.ID, UserID: members[0], AddedAt: time.Now()}),
+		row(database.ListMember{ListID: list.ID, UserID: members[1], AddedAt: time.Now()}),
+	)
+	fake.returns("FilterChirps", row(chirp))
+
+	w := httptest.NewRecorder()
+	cfg.handlerGetListTimeline(w, listRequest(t, owner, list.ID, http.MethodGet, "/api/lists/"+list.ID.String()+"/chirps", ""))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+	got := []Chirp{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != chirp.ID {
+		t.Errorf("expected the member's chirp, got %+v", got)
+	}
+
+	calls := fake.calledWith("FilterChirps")
+	want := driverValue(members)
+	if len(calls) != 1 || calls[0][0] != want {
+		t.Errorf("expected chirps by the list members %v, got %v", want, calls)
+	}
+}
@@ -4,20 +4,34 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"sort"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/colfarl/chirpy-server/internal/chirptext"
 	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/entitlements"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/moderation"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/colfarl/chirpy-server/internal/search"
+	"github.com/colfarl/chirpy-server/internal/spam"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/colfarl/chirpy-server/internal/visibility"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type User struct {
@@ -26,6 +40,24 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Email     string    `json:"email"`
 	ChirpyRed bool		`json:"is_chirpy_red"`
+	Handle	  string	`json:"handle,omitempty"`
+	DisplayName string	`json:"display_name,omitempty"`
+	IsProtected bool	`json:"is_protected"`
+	Entitlements entitlements.Entitlements `json:"entitlements"`
+}
+
+func userFromDB(user database.User, ent entitlements.Entitlements) User {
+	return User{
+		ID: user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email: user.Email,
+		ChirpyRed: ent.Tier == subscription.TierRed,
+		Handle: user.Handle.String,
+		DisplayName: user.DisplayName.String,
+		IsProtected: user.IsProtected,
+		Entitlements: ent,
+	}
 }
 
 type LoggedInUser struct {
@@ -34,6 +66,10 @@ type LoggedInUser struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Email     string    `json:"email"`
 	ChirpyRed bool		`json:"is_chirpy_red"`
+	Handle	  string	`json:"handle,omitempty"`
+	DisplayName string	`json:"display_name,omitempty"`
+	IsProtected bool	`json:"is_protected"`
+	Entitlements entitlements.Entitlements `json:"entitlements"`
 	Token	  string	`json:"token"`
 	RefreshToken string `json:"refresh_token"`
 }
@@ -44,14 +80,66 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body	  string    `json:"body"`
 	UserID	  uuid.UUID `json:"user_id"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	Visibility string	`json:"visibility"`
+	Language  string	`json:"language"`
+	Media	  []string	`json:"media"`
+	Poll	  *Poll		`json:"poll,omitempty"`
+	Pinned	  bool		`json:"pinned,omitempty"`
+	HeldForReview bool	`json:"held_for_review,omitempty"`
+}
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	formatted := Chirp{
+		ID: chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body: chirp.Body,
+		UserID: chirp.UserID,
+		Visibility: chirp.Visibility,
+		Language: chirp.Language,
+		Media: chirp.MediaUrls,
+		HeldForReview: chirp.HeldForReview,
+	}
+	if chirp.ReplyToID.Valid {
+		formatted.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	return formatted
 }
 
 type apiConfig struct {
 	fileServerHits	atomic.Int32
 	db				*database.Queries	
+	dbConn			*sql.DB
 	platform		string
 	secret			string
-	polka_key		string
+	polkaSecrets	[]string
+	entitlements	entitlements.Resolver
+	events			*events.Bus
+	moderation		*moderation.Cache
+	spam			spam.Pipeline
+	chirpStream		*stream.Hub
+	realtime		realtime.PubSub
+	webhookGuard	outbound.Guard
+}
+
+// authenticatedUserID returns the user behind the Bearer JWT on the request
+func (cfg *apiConfig) authenticatedUserID(req *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return cfg.validateAccessToken(req.Context(), token)
+}
+
+// optionalUserID is for endpoints that work anonymously but can show more to a signed in viewer,
+// uuid.Nil means no valid token was sent
+func (cfg *apiConfig) optionalUserID(req *http.Request) uuid.UUID {
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		return uuid.Nil
+	}
+	return userID
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -79,7 +167,7 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, req *http.Request) {
 	cfg.fileServerHits.Store(0)
 
 	//Clean database
-	err := cfg.db.DeleteAllUsers(context.Background())
+	err := cfg.db.DeleteAllUsers(req.Context())
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not delete users", err)
 		return
@@ -94,6 +182,7 @@ func (cfg * apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Reques
 	type parameters struct {
 		Email		string `json:"email"`
 		Password	string `json:"password"`
+		Handle		string `json:"handle"`
 	}
 	
 	decoder := json.NewDecoder(req.Body)
@@ -110,6 +199,13 @@ func (cfg * apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	if params.Handle != "" {
+		if err := validateHandle(params.Handle); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
 	hash, err := auth.HashPassword(params.Password) 
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to encrypt password", err)
@@ -122,23 +218,25 @@ func (cfg * apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Reques
 		UpdatedAt: time.Now(),
 		Email: params.Email,
 		HashedPassword: hash,
+		Handle: sql.NullString{
+			String: params.Handle,
+			Valid: params.Handle != "",
+		},
 	}
 	
-	user, err := cfg.db.CreateUserWithPassWord(context.Background(), userParams)
+	user, err := cfg.db.CreateUserWithPassWord(req.Context(), userParams)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not put user in database", err)
 		return
 	}
 
-	taggedUser := User{
-		ID: user.ID,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-		Email: user.Email,
-		ChirpyRed: user.IsChirpyRed,
+	ent, err := cfg.entitlements.For(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve entitlements", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, taggedUser) 	
+	respondWithJSON(w, http.StatusCreated, userFromDB(user, ent)) 	
 }
 
 func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Request){
@@ -156,7 +254,7 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Request)
 		return
 	}
 	
-	user, err := cfg.db.GetUserByEmail(context.Background(), params.Email)
+	user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not find associated user", err)
 		return
@@ -167,6 +265,11 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Request)
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password", err)
 		return
 	}
+
+	if err := activeSuspension(user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason, time.Now()); err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
 		
 	
 	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Duration(3600) * time.Second)
@@ -188,19 +291,29 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Request)
 		},
 	}
 
-	_, err = cfg.db.CreateRefreshToken(context.Background(), refreshParams)
+	_, err = cfg.db.CreateRefreshToken(req.Context(), refreshParams)
 	if err != nil {
 		log.Println("error inserting refresh token", refreshParams)
 		respondWithError(w, http.StatusInternalServerError, "error creating refresh token", err)
 		return
 	}
 
+	ent, err := cfg.entitlements.For(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve entitlements", err)
+		return
+	}
+
 	taggedLoggedInUser := LoggedInUser{
 		ID: user.ID,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 		Email: user.Email,
-		ChirpyRed: user.IsChirpyRed,
+		ChirpyRed: ent.Tier == subscription.TierRed,
+		Handle: user.Handle.String,
+		DisplayName: user.DisplayName.String,
+		IsProtected: user.IsProtected,
+		Entitlements: ent,
 		Token: token,
 		RefreshToken: refreshToken,
 	}
@@ -209,11 +322,93 @@ func (cfg *apiConfig) handlerUserLogin(w http.ResponseWriter, req *http.Request)
 	respondWithJSON(w, http.StatusOK, taggedLoggedInUser)
 }
 
+// validateChirpBody applies the rules every chirp has to pass before it is published,
+// the result's Text is the body as it should be stored
+func (cfg *apiConfig) validateChirpBody(body string, policy chirptext.Policy) (moderation.Result, error) {
+	body, err := policy.Check(body)
+	if err != nil {
+		return moderation.Result{}, err
+	}
+	return cfg.moderateText(body)
+}
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate in a unique column
+func isUniqueViolation(err error) bool {
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// dbTime converts a time from a client for a timestamp column. The columns have no zone and the
+// driver's offset is dropped, so times must be in the server's zone like the time.Now() values
+// they sit next to.
+func dbTime(t time.Time) time.Time {
+	return t.In(time.Local)
+}
+
+// chirpPolicy is the length policy a user's entitlements allow
+func chirpPolicy(ent entitlements.Entitlements) chirptext.Policy {
+	return chirptext.Policy{MaxLength: ent.MaxChirpLength}
+}
+
+const maxMediaURLLength = 2048
+
+var errChirpRateLimited = errors.New("too many chirps, try again later")
+
+// checkAuthorCanPost turns away a suspended author or one who has used up this hour's chirps,
+// chirps posted directly and by the scheduler go through the same gate
+func checkAuthorCanPost(ctx context.Context, db *database.Queries, userID uuid.UUID, ent entitlements.Entitlements, now time.Time) error {
+
+	suspension, err := db.GetUserSuspension(ctx, userID)
+	if err != nil {
+		return err
+	}
+	posted, err := db.CountChirpsByAuthorSince(ctx, database.CountChirpsByAuthorSinceParams{
+		UserID: userID,
+		Since: now.Add(-time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+	return authorCanPost(suspension, posted, ent, now)
+}
+
+// authorCanPost is checkAuthorCanPost's decision given what it read
+func authorCanPost(suspension database.GetUserSuspensionRow, postedLastHour int64, ent entitlements.Entitlements, now time.Time) error {
+	if err := activeSuspension(suspension.SuspendedAt, suspension.SuspendedUntil, suspension.SuspensionReason, now); err != nil {
+		return err
+	}
+	if postedLastHour >= int64(ent.ChirpsPerHour) {
+		return errChirpRateLimited
+	}
+	return nil
+}
+
+// validateMediaURLs checks the links to images or video attached to a chirp
+func validateMediaURLs(mediaURLs []string, maxMedia int) ([]string, error) {
+	if len(mediaURLs) > maxMedia {
+		return nil, fmt.Errorf("a chirp can have at most %d media attachments", maxMedia)
+	}
+	valid := []string{}
+	for _, mediaURL := range mediaURLs {
+		parsed, err := url.ParseRequestURI(mediaURL)
+		if err != nil || len(mediaURL) > maxMediaURLLength || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, errors.New("media must be http or https urls")
+		}
+		valid = append(valid, mediaURL)
+	}
+	return valid, nil
+}
+
 func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Request){
 
 	type parameters struct {
 		Body		string    `json:"body"`
 		UserID		uuid.UUID `json:"user_id"`
+		ReplyToID	*uuid.UUID `json:"reply_to_id"`
+		Poll		*pollParameters `json:"poll"`
+		Visibility	string `json:"visibility"`
+		Language	string `json:"language"`
+		Media		[]string `json:"media"`
 	}
 	
 	log.Println("Request", req.Body)
@@ -233,85 +428,245 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(req.Context(), token)
 	if err != nil {
 		log.Println("Error: ", err, "  Gathered_ID  ", userID, "  Submitted_ID  ", params.UserID)
 		respondWithError(w, http.StatusUnauthorized, "invalid token provided", err)
 		return
 	}
 
-	if len(params.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "chirp is too long", err)
+	ent, err := cfg.entitlements.For(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not find associated user", err)
+		return
+	}
+	policy := chirpPolicy(ent)
+
+	err = checkAuthorCanPost(req.Context(), cfg.db, userID, ent, time.Now())
+	suspended := &suspendedError{}
+	switch {
+	case errors.Is(err, errChirpRateLimited):
+		respondWithError(w, http.StatusTooManyRequests, err.Error(), err)
+		return
+	case errors.As(err, &suspended):
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
+	}
+
+	moderated, err := cfg.validateChirpBody(params.Body, policy)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 	
-	cleanChirp := cleanWords(params.Body)
+	level, err := visibility.ParseLevel(params.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	language, err := search.ParseLanguage(params.Language)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	media, err := validateMediaURLs(params.Media, ent.MaxMedia)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	now := time.Now()
+	pollLabels := []string{}
+	if params.Poll != nil {
+		pollLabels, err = cfg.validatePoll(*params.Poll, now)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	replyTo := uuid.NullUUID{}
+	if params.ReplyToID != nil {
+		parent, err := cfg.db.GetOneChirp(req.Context(), *params.ReplyToID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "chirp being replied to does not exist", err)
+			return
+		}
+		if canView, err := cfg.canViewChirp(req.Context(), userID, parent); err != nil || !canView {
+			respondWithError(w, http.StatusBadRequest, "chirp being replied to does not exist", err)
+			return
+		}
+		replyTo = uuid.NullUUID{
+			UUID: parent.ID,
+			Valid: true,
+		}
+	}
+	
+	signals, decision, err := cfg.screenChirp(req.Context(), cfg.db, userID, moderated.Text)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
+	}
+	if decision.Verdict == spam.VerdictReject {
+		err = recordSpamDecision(req.Context(), cfg.db, userID, uuid.NullUUID{}, signals, decision)
+		if err != nil {
+			log.Println("could not record spam decision: ", err)
+		}
+		respondWithError(w, http.StatusBadRequest, errChirpSpam.Error(), errChirpSpam)
+		return
+	}
+
 	chirpParams := database.CreateChirpParams{
 		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Body: cleanChirp,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body: moderated.Text,
 		UserID: userID,
+		ReplyToID: replyTo,
+		Visibility: string(level),
+		Language: language,
+		MediaUrls: media,
+		HeldForReview: decision.Verdict == spam.VerdictHold,
+	}
+
+	// the chirp and its poll are written together or not at all
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 	
-	chirp, err := cfg.db.CreateChirp(context.Background(), chirpParams)
+	chirp, err := qtx.CreateChirp(req.Context(), chirpParams)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		ID: chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.CreatedAt,
-		Body: chirp.Body,
-		UserID: chirp.UserID,
+	if err := flagChirp(req.Context(), qtx, chirp.ID, moderated); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
+	}
+
+	err = recordSpamDecision(req.Context(), qtx, userID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, signals, decision)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
+	}
+
+	if params.Poll != nil {
+		err = createPoll(req.Context(), qtx, chirp.ID, pollLabels, params.Poll.ClosesAt, now)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "poll not uploaded", err)
+			return
+		}
+	}
+
+	if !chirp.HeldForReview {
+		if err := enqueueChirpWebhook(req.Context(), qtx, outbound.EventChirpCreated, chirp); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+			return
+		}
+		if err := recordChirpStreamEvent(req.Context(), qtx, stream.TypeChirpCreated, chirp); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "chirp not uploaded", err)
+		return
+	}
+
+	// a held chirp's replies and mentions are announced when a moderator releases it
+	if !chirp.HeldForReview {
+		cfg.publishChirpEvents(req.Context(), chirp)
+	}
+
+	formatted := []Chirp{chirpFromDB(chirp)}
+	if err := cfg.attachPolls(req.Context(), formatted, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, struct {
+		Chirp
+		RemainingCharacters int `json:"remaining_characters"`
+	}{
+		Chirp: formatted[0],
+		RemainingCharacters: policy.Remaining(params.Body),
 	})	
 }
 
+// handlerGetChirps is the public timeline, filtered by author_id, since, until, has_media, is_reply
+// and language. author_id is kept for older clients, GET /api/users/{userID}/chirps is the profile timeline.
 func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request){
 	
-	var chirpsUnformatted []database.Chirp;
-	var err error
+	filter, p, fields := parseChirpTimeline(req, true)
+	if len(fields) > 0 {
+		respondWithFieldErrors(w, fields)
+		return
+	}
 
-	authorID := req.URL.Query().Get("author_id")
-	authorUUID, _ := uuid.Parse(authorID)
-	
-	if authorID != "" {
-		chirpsUnformatted, err = cfg.db.GetAllChirpsByAuthor(context.Background(), authorUUID)
-	} else {
-		chirpsUnformatted, err = cfg.db.GetAllChirps(context.Background())
+	surface := visibility.SurfacePublicFeed
+	if filter.AuthorIDs != nil {
+		surface = visibility.SurfaceTimeline
 	}
 
+	cfg.respondWithChirpsPage(w, req, filter, p, surface)
+}
+
+// respondWithChirpsPage writes the page p of the chirps matching filter
+func (cfg *apiConfig) respondWithChirpsPage(w http.ResponseWriter, req *http.Request, filter database.ChirpFilter, p page, surface visibility.Surface){
+
+	// walking backwards through an ascending timeline is walking forwards through a descending one
+	filter.Ascending = filter.Ascending != p.backward
+	filter.CursorTime = p.cursor
+	filter.CursorID = p.cursorID
+	filter.PageSize = p.size
+
+	chirpsUnformatted, err := cfg.db.FilterChirps(req.Context(), filter)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps", err)
 		return
 	}
+	chirpsUnformatted = reversePage(p, chirpsUnformatted)
 
-	sortOrder := req.URL.Query().Get("sort")
-	log.Println("Attempting to sort with key ", sortOrder, "len", len(chirpsUnformatted))
-	if sortOrder == "asc" {	
-		sort.Slice(chirpsUnformatted, func (l, r int) bool {
-			return chirpsUnformatted[l].CreatedAt.Before(chirpsUnformatted[r].CreatedAt)
-		})
-		log.Println("After ", sortOrder, "len", len(chirpsUnformatted))
-	}
-	if sortOrder == "desc" {
-		sort.Slice(chirpsUnformatted, func (l, r int) bool {
-			return chirpsUnformatted[l].CreatedAt.After(chirpsUnformatted[r].CreatedAt)
-		})
-		log.Println("After ", sortOrder, "len", len(chirpsUnformatted))
+	// cursors come from the page before visibility filtering so hidden chirps can't stall it
+	nextCursor, prevCursor := p.cursors(len(chirpsUnformatted), func(i int) string {
+		return encodeCursor(chirpsUnformatted[i].CreatedAt, chirpsUnformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	cfg.respondWithChirps(w, req, chirpsUnformatted, surface)
+}
+
+// respondWithChirps hides what the viewer may not see and writes the rest as JSON in the
+// order given, every endpoint that returns a timeline of chirps goes through here
+func (cfg *apiConfig) respondWithChirps(w http.ResponseWriter, req *http.Request, chirpsUnformatted []database.Chirp, surface visibility.Surface){
+
+	viewerID := cfg.optionalUserID(req)
+	chirpsUnformatted, err := cfg.filterVisibleChirps(req.Context(), viewerID, chirpsUnformatted, surface)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps", err)
+		return
 	}
-	
+
 	allChirps := []Chirp{}
 	for _, chirp := range chirpsUnformatted {
-		allChirps = append(allChirps, Chirp{
-			ID: chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body: chirp.Body,
-			UserID: chirp.UserID,
-		})
+		allChirps = append(allChirps, chirpFromDB(chirp))
+	}
+
+	err = cfg.attachPolls(req.Context(), allChirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve polls", err)
+		return
 	}
 	
 	respondWithJSON(w, http.StatusOK, allChirps)
@@ -326,19 +681,31 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, req *http.Request){
 		return
 	}
 
-	unformattedChirp, err := cfg.db.GetOneChirp(context.Background(), chirpID)
+	unformattedChirp, err := cfg.db.GetOneChirp(req.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID: unformattedChirp.ID,
-		CreatedAt: unformattedChirp.CreatedAt,
-		UpdatedAt: unformattedChirp.UpdatedAt,
-		Body: unformattedChirp.Body,
-		UserID: unformattedChirp.UserID,
-	})
+	viewerID := cfg.optionalUserID(req)
+	canView, err := cfg.canViewChirp(req.Context(), viewerID, unformattedChirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirp", err)
+		return
+	}
+	if !canView {
+		respondWithError(w, http.StatusNotFound, "chirp does not exist", nil)
+		return
+	}
+
+	formatted := []Chirp{chirpFromDB(unformattedChirp)}
+	err = cfg.attachPolls(req.Context(), formatted, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, formatted[0])
 }
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request){
@@ -349,7 +716,7 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request){
 		respondWithError(w, http.StatusBadRequest, "invalid authorization", err)
 	}
 	
-	refreshToken, err := cfg.db.GetRefreshTokenByToken(context.Background(), token)
+	refreshToken, err := cfg.db.GetRefreshTokenByToken(req.Context(), token)
 	if err != nil {
 		log.Println("could not find refresh token in database ", err)
 		respondWithError(w, http.StatusBadRequest, "invalid authorization", err)
@@ -362,6 +729,16 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request){
 		return
 	}
 
+	user, err := cfg.db.GetUserByID(req.Context(), refreshToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "session expired", err)
+		return
+	}
+	if err := activeSuspension(user.SuspendedAt, user.SuspendedUntil, user.SuspensionReason, time.Now()); err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error(), err)
+		return
+	}
+
 	accessToken, err := auth.MakeJWT(refreshToken.UserID, cfg.secret, time.Duration(3600) * time.Second)
 	if err != nil {
 		log.Println("Error: ", err)
@@ -390,7 +767,7 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, req *http.Request){
 		UpdatedAt: time.Now(),
 	}
 
-	err = cfg.db.RevokeToken(context.Background(), updateParams)
+	err = cfg.db.RevokeToken(req.Context(), updateParams)
 	if err != nil {
 		log.Println("tried to revoke token and failed ", err)
 		respondWithError(w, http.StatusInternalServerError, "unable to revoke token", err)
@@ -412,6 +789,9 @@ func (cfg * apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, req *http.Re
 	type parameters struct {
 		Email		string
 		Password	string
+		Handle		string
+		DisplayName	*string `json:"display_name"`
+		IsProtected	*bool `json:"is_protected"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -423,7 +803,7 @@ func (cfg * apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, req *http.Re
 		return
 	}
 	
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(req.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "couldn't decode parameters", err)
 		return
@@ -442,19 +822,106 @@ func (cfg * apiConfig) handlerUpdateUserInfo(w http.ResponseWriter, req *http.Re
 		UpdatedAt: time.Now(),
 	}
 
-	updatedUser, err := cfg.db.UpdateUserLogin(context.Background(), responseParams)
+	if params.Handle != "" {
+		if err := validateHandle(params.Handle); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	displayName := ""
+	if params.DisplayName != nil && *params.DisplayName != "" {
+		displayName, err = validateDisplayName(*params.DisplayName)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	// every change is made in one transaction, so a taken handle doesn't leave the new email
+	// or password behind
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update user info", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	updatedUser, err := qtx.UpdateUserLogin(req.Context(), responseParams)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "email is already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "could not update user info", err)
 		return	
 	}
+
+	if params.Handle != "" {
+		updatedUser, err = qtx.SetUserHandle(req.Context(), database.SetUserHandleParams{
+			Handle: sql.NullString{
+				String: params.Handle,
+				Valid: true,
+			},
+			UpdatedAt: time.Now(),
+			ID: userID,
+		})
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "handle is already taken", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not update handle", err)
+			return
+		}
+	}
+
+	// an empty display_name clears it, leaving it out keeps the current one
+	if params.DisplayName != nil {
+		updatedUser, err = qtx.SetUserDisplayName(req.Context(), database.SetUserDisplayNameParams{
+			DisplayName: sql.NullString{
+				String: displayName,
+				Valid: displayName != "",
+			},
+			UpdatedAt: time.Now(),
+			ID: userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not update display name", err)
+			return
+		}
+	}
+
+	approved := []database.Follow{}
+	if params.IsProtected != nil && *params.IsProtected != updatedUser.IsProtected {
+		updatedUser, approved, err = setUserProtected(req.Context(), qtx, userID, *params.IsProtected)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not update account privacy", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update user info", err)
+		return
+	}
+
+	for _, follow := range approved {
+		cfg.events.Publish(req.Context(), events.Event{
+			Kind: events.KindFollow,
+			ActorID: follow.FollowerID,
+			UserID: userID,
+		})
+	}
 	
-	respondWithJSON(w, http.StatusOK, User{
-		ID: updatedUser.ID,	
-		Email: updatedUser.Email,
-		CreatedAt: updatedUser.CreatedAt,
-		UpdatedAt: updatedUser.UpdatedAt,
-		ChirpyRed: updatedUser.IsChirpyRed,
-	})
+	ent, err := cfg.entitlements.For(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve entitlements", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(updatedUser, ent))
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Request){
@@ -473,15 +940,16 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 	
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID, err := cfg.validateAccessToken(req.Context(), token)
 	if err != nil {
 		respondWithError(w, http.StatusForbidden, "invalid token", err)
 		return
 	}
 
-	chirp, err := cfg.db.GetOneChirp(context.Background(), chirpID)
+	chirp, err := cfg.db.GetOneChirp(req.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
+		return
 	}
 	
 	if chirp.UserID != userID {
@@ -489,53 +957,61 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	err = cfg.db.DeleteChirp(context.Background(), chirpID)
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp", err)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
-}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-func (cfg * apiConfig) handlerUpgradeUserRed(w http.ResponseWriter, req *http.Request) {
-	
-	type requestParameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+	// the chirp goes to the author's trash and is purged after chirpTrashRetention
+	err = qtx.SoftDeleteChirp(req.Context(), database.SoftDeleteChirpParams{
+		ID:        chirpID,
+		DeletedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp", err)
+		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := requestParameters{}
-	err := decoder.Decode(&params)
-
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "could not decode json", err)
-		return 
+	// a held chirp was never announced, so neither is its deletion
+	if !chirp.HeldForReview {
+		if err := enqueueChirpWebhook(req.Context(), qtx, outbound.EventChirpDeleted, chirp); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to delete chirp", err)
+			return
+		}
+		if err := recordChirpStreamEvent(req.Context(), qtx, stream.TypeChirpDeleted, chirp); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to delete chirp", err)
+			return
+		}
 	}
-	
-		
-	apiKey, err := auth.GetAPIKey(req.Header)
-	if err != nil || apiKey != cfg.polka_key{
-		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete chirp", err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	log.Println("Webhook with event ", params.Event)
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+const maxWebhookBodyBytes = 1 << 20
+
+func (cfg * apiConfig) handlerUpgradeUserRed(w http.ResponseWriter, req *http.Request) {
+	
+	// the signature covers the exact bytes sent, so read them before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not read request", err)
 		return
 	}
-	
-	log.Println("called upgrade on: ", params.Data.UserID)
-	err = cfg.db.UpgradeChirpyRed(context.Background(), params.Data.UserID)
+
+	err = auth.VerifyWebhook(req.Header, body, cfg.polkaSecrets, auth.DefaultWebhookTolerance, time.Now())
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "could not upgrade user", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	cfg.receivePolkaEvent(w, req, body)
 }
 
 func main() {
@@ -555,14 +1031,31 @@ func main() {
 	dbQueries := database.New(db)
 
 	secret := os.Getenv("SECRET")
-	polka_key := os.Getenv("POLKA_KEY")
+	// POLKA_WEBHOOK_SECRETS lists every secret Polka may sign with, comma separated, so one can be
+	// rotated in before the last is retired. POLKA_KEY is the single secret it replaces.
+	polkaSecrets := splitListParam([]string{os.Getenv("POLKA_WEBHOOK_SECRETS")})
+	if len(polkaSecrets) == 0 {
+		polkaSecrets = []string{os.Getenv("POLKA_KEY")}
+	}
+	realtimeLocal := realtime.NewLocal()
 	apiCfg := &apiConfig{
 		fileServerHits: atomic.Int32{},
 		db: dbQueries,
+		dbConn: db,
 		platform: platform,
 		secret: secret,
-		polka_key: polka_key,
+		polkaSecrets: polkaSecrets,
+		events: events.NewBus(),
+		chirpStream: stream.NewHub(),
+		realtime: pgPubSub{Local: realtimeLocal, db: dbQueries},
+		entitlements: entitlements.NewEngine(entitlements.DefaultPlans, subscriptionTiers{db: dbQueries}),
+		// WEBHOOKS_ALLOW_PRIVATE=true lets endpoints on localhost and private networks register,
+		// for development only
+		webhookGuard: outbound.Guard{AllowPrivate: os.Getenv("WEBHOOKS_ALLOW_PRIVATE") == "true"},
 	}
+	apiCfg.moderation = moderation.NewCache(apiCfg.loadModerationRules)
+	apiCfg.spam = spamPipelineFromEnv()
+	apiCfg.subscribeNotifications(apiCfg.events)
 
 	handler := http.StripPrefix("/app", http.FileServer(http.Dir(root)))
 	mux := http.NewServeMux()	
@@ -584,17 +1077,129 @@ func main() {
 
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps) 
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp) 
+	mux.HandleFunc("GET /api/stream/chirps", apiCfg.handlerStreamChirps)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics) 
 
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUserInfo)
 
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerEditChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("GET /api/chirps/trash", apiCfg.handlerGetChirpTrash)
+	mux.HandleFunc("GET /api/search", apiCfg.handlerSearch)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerRestoreChirp)
+
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerCreateWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apiCfg.handlerDeleteWebhookEndpoint)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/enable", apiCfg.handlerEnableWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries/{deliveryID}", apiCfg.handlerGetWebhookDelivery)
+
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("GET /api/follow_requests", apiCfg.handlerGetFollowRequests)
+	mux.HandleFunc("POST /api/follow_requests/{userID}/approve", apiCfg.handlerApproveFollowRequest)
+	mux.HandleFunc("POST /api/follow_requests/{userID}/reject", apiCfg.handlerRejectFollowRequest)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.handlerBlockUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.handlerUnblockUser)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirps", apiCfg.handlerRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirps", apiCfg.handlerUndoRechirp)
+
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerMarkAllNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerMarkNotificationRead)
+
+	mux.HandleFunc("POST /api/scheduled_chirps", apiCfg.handlerCreateScheduledChirp)
+	mux.HandleFunc("GET /api/scheduled_chirps", apiCfg.handlerGetScheduledChirps)
+	mux.HandleFunc("PUT /api/scheduled_chirps/{scheduledID}", apiCfg.handlerUpdateScheduledChirp)
+	mux.HandleFunc("DELETE /api/scheduled_chirps/{scheduledID}", apiCfg.handlerCancelScheduledChirp)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.handlerVoteInPoll)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.handlerPinChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.handlerUnpinChirp)
+	mux.HandleFunc("GET /api/users/{userID}/chirps", apiCfg.handlerGetUserChirps)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerRemoveBookmark)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.handlerGetBookmarks)
+
+	mux.HandleFunc("POST /api/lists", apiCfg.handlerCreateList)
+	mux.HandleFunc("GET /api/lists", apiCfg.handlerGetLists)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerGetList)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.handlerRenameList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handlerDeleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.handlerGetListMembers)
+	mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.handlerAddListMember)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.handlerRemoveListMember)
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", apiCfg.handlerGetListTimeline)
+
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerCreateConversation)
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerGetDirectMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerSendDirectMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerMarkConversationRead)
+
+	mux.HandleFunc("POST /api/reports", apiCfg.handlerCreateReport)
+	mux.Handle("GET /admin/reports", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerGetReports))
+	mux.Handle("POST /admin/reports/{reportID}/claim", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerClaimReport))
+	mux.Handle("POST /admin/reports/{reportID}/resolve", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerResolveReport))
+	mux.Handle("POST /admin/reports/{reportID}/dismiss", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerDismissReport))
+	mux.Handle("POST /admin/users/{userID}/suspension", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerSuspendUser))
+	mux.Handle("DELETE /admin/users/{userID}/suspension", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerLiftSuspension))
+	mux.Handle("POST /admin/users/{userID}/shadow_ban", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerShadowBanUser))
+	mux.Handle("DELETE /admin/users/{userID}/shadow_ban", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerLiftShadowBan))
+	mux.Handle("GET /admin/spam/decisions", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerGetSpamDecisions))
+	mux.Handle("GET /admin/spam/held", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerGetHeldChirps))
+	mux.Handle("POST /admin/spam/held/{chirpID}/release", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerReleaseHeldChirp))
+	mux.Handle("DELETE /admin/spam/held/{chirpID}", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerRemoveHeldChirp))
+	mux.Handle("GET /admin/moderation/actions", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetModerationActions))
+	mux.Handle("GET /admin/moderation/words", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetModerationWords))
+	mux.Handle("POST /admin/moderation/words", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerCreateModerationWord))
+	mux.Handle("PUT /admin/moderation/words/{wordID}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerUpdateModerationWord))
+	mux.Handle("DELETE /admin/moderation/words/{wordID}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerDeleteModerationWord))
+	mux.Handle("GET /admin/webhooks", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetWebhookEvents))
+	mux.Handle("GET /admin/webhooks/{eventID}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetWebhookEvent))
+	mux.Handle("POST /admin/webhooks/{eventID}/replay", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerReplayWebhookEvent))
+	mux.Handle("GET /admin/jobs", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetJobs))
+	mux.Handle("GET /admin/jobs/{jobID}", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerGetJob))
+	mux.Handle("POST /admin/jobs/{jobID}/retry", apiCfg.middlewareRequireRole(roleAdmin, apiCfg.handlerRetryJob))
+	mux.Handle("GET /admin/moderation/flags", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerGetChirpFlags))
+	mux.Handle("DELETE /admin/moderation/flags/{chirpID}", apiCfg.middlewareRequireRole(roleModerator, apiCfg.handlerDismissChirpFlag))
+
+	srvCfg := serverConfigFromEnv()
+	srv := srvCfg.newServer(":" + port, mux)
+
+	// SIGINT or SIGTERM stops the background loops and starts draining requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := apiCfg.newJobRunner()
+	runner.DrainTimeout = srvCfg.shutdownTimeout
+	jobsDone := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(jobsDone)
+	}()
+	go apiCfg.runModerationRefresher(ctx)
+	go apiCfg.runChirpStreamListener(ctx, dbURL)
+	go apiCfg.runRealtimeListener(ctx, dbURL, realtimeLocal)
+
+	// Shutdown doesn't wait on streams to finish by themselves, ending them lets it return.
+	// Websockets are hijacked so Shutdown doesn't wait on them at all, this closes them cleanly.
+	srv.RegisterOnShutdown(apiCfg.chirpStream.Close)
+	srv.RegisterOnShutdown(apiCfg.realtime.Close)
 
-	srv := &http.Server{
-		Addr: ":" + port,
-		Handler: mux,
-	}
-	
 	fmt.Println("Serving on port", port)
-	log.Fatal(srv.ListenAndServe())
+	if err := srvCfg.serve(ctx, srv); err != nil {
+		log.Fatal(err)
+	}
+
+	// jobs drain alongside requests, wait for them before closing the database under them
+	<-jobsDone
+	db.Close()
+	fmt.Println("Server stopped")
 }
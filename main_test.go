@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+
+	duplicate := &pq.Error{Code: "23505"}
+	if !isUniqueViolation(duplicate) || !isUniqueViolation(fmt.Errorf("update: %w", duplicate)) {
+		t.Error("expected a unique violation to be recognised, wrapped or not")
+	}
+	for _, err := range []error{nil, errors.New("boom"), &pq.Error{Code: "23503"}} {
+		if isUniqueViolation(err) {
+			t.Errorf("expected %v not to be a unique violation", err)
+		}
+	}
+}
+
+func TestUpdateUserInfo(t *testing.T) {
+
+	user := testUser()
+	duplicate := &pq.Error{Code: "23505"}
+
+	cases := []struct {
+		name      string
+		body      string
+		loginErr  error
+		handleErr error
+		status    int
+	}{
+		{"updated", `{"email":"new@example.com","password":"pw","handle":"newname","display_name":"New"}`, nil, nil, http.StatusOK},
+		{"email taken", `{"email":"taken@example.com","password":"pw"}`, duplicate, nil, http.StatusConflict},
+		{"handle taken", `{"email":"new@example.com","password":"pw","handle":"taken","display_name":"New"}`, nil, duplicate, http.StatusConflict},
+		{"handle not saved", `{"email":"new@example.com","password":"pw","handle":"newname"}`, nil, errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("UpdateUserLogin", func(args []driver.Value) fakeResult {
+				return fakeResult{rows: [][]driver.Value{row(user)}, err: c.loginErr}
+			})
+			fake.answer("SetUserHandle", func(args []driver.Value) fakeResult {
+				return fakeResult{rows: [][]driver.Value{row(user)}, err: c.handleErr}
+			})
+			fake.returns("SetUserDisplayName", row(user))
+
+			w := httptest.NewRecorder()
+			cfg.handlerUpdateUserInfo(w, authorizedRequest(t, user.ID, http.MethodPut, "/api/users", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			// a failed step stops the rest of the update, the transaction rolls back what came before
+			if c.status != http.StatusOK {
+				if calls := fake.calledWith("SetUserDisplayName"); len(calls) != 0 {
+					t.Errorf("expected the update to stop at the failure, got %v", calls)
+				}
+			}
+		})
+	}
+}
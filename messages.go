@@ -0,0 +1,525 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationMembers = 10
+	maxDirectMessageLength = 1000
+)
+
+type ConversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	IsGroup     bool                 `json:"is_group"`
+	Members     []ConversationMember `json:"members"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+type DirectMessage struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+func conversationMemberFromDB(member database.ConversationMember) ConversationMember {
+	formatted := ConversationMember{
+		UserID:   member.UserID,
+		JoinedAt: member.JoinedAt,
+	}
+	if member.LastReadAt.Valid {
+		formatted.LastReadAt = &member.LastReadAt.Time
+	}
+	return formatted
+}
+
+// directMessageFromDB fills in read receipts, a member has read every message
+// sent at or before their last_read_at
+func directMessageFromDB(message database.DirectMessage, members []database.ConversationMember) DirectMessage {
+	readBy := []uuid.UUID{}
+	for _, member := range members {
+		if member.UserID == message.SenderID {
+			continue
+		}
+		if member.LastReadAt.Valid && !member.LastReadAt.Time.Before(message.CreatedAt) {
+			readBy = append(readBy, member.UserID)
+		}
+	}
+
+	return DirectMessage{
+		ID:             message.ID,
+		CreatedAt:      message.CreatedAt,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		ReadBy:         readBy,
+	}
+}
+
+// conversationForMember authenticates the caller and loads the conversation in the url,
+// a conversation the caller is not part of is reported as missing
+func (cfg *apiConfig) conversationForMember(w http.ResponseWriter, req *http.Request) (uuid.UUID, database.Conversation, bool) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	conversationID, err := uuid.Parse(req.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract conversation id from url", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	conversation, err := cfg.db.GetConversationForMember(req.Context(), database.GetConversationForMemberParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "conversation does not exist", err)
+		return uuid.Nil, database.Conversation{}, false
+	}
+
+	return userID, conversation, true
+}
+
+// otherConversationMembers is who userID asked to start a conversation with, without
+// userID or repeats
+func otherConversationMembers(userID uuid.UUID, memberIDs []uuid.UUID) []uuid.UUID {
+	others := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, memberID := range memberIDs {
+		if !seen[memberID] {
+			seen[memberID] = true
+			others = append(others, memberID)
+		}
+	}
+	return others
+}
+
+// directConversationKey identifies the one-to-one conversation between two users whichever
+// of them starts it
+func directConversationKey(a, b uuid.UUID) string {
+	first, second := a.String(), b.String()
+	if second < first {
+		first, second = second, first
+	}
+	return first + ":" + second
+}
+
+// validateDirectMessage checks a message body, its length is in characters like a chirp's
+func validateDirectMessage(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("message is empty")
+	}
+	if utf8.RuneCountInString(body) > maxDirectMessageLength {
+		return errors.New("message is too long")
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	type parameters struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	others := otherConversationMembers(userID, params.MemberIDs)
+
+	if len(others) == 0 || len(others)+1 > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, "a conversation needs between 2 and 10 members", nil)
+		return
+	}
+
+	for _, memberID := range others {
+		if _, err := cfg.db.GetUserByID(req.Context(), memberID); err != nil {
+			respondWithError(w, http.StatusNotFound, "user does not exist", err)
+			return
+		}
+	}
+
+	blocked, err := cfg.db.IsBlockedEitherWay(req.Context(), database.IsBlockedEitherWayParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "cannot start a conversation with a blocked user", nil)
+		return
+	}
+
+	isGroup := len(others) > 1
+	directKey := sql.NullString{}
+	if !isGroup {
+		directKey = sql.NullString{String: directConversationKey(userID, others[0]), Valid: true}
+		existing, err := cfg.db.FindDirectConversation(req.Context(), directKey.String)
+		if err == nil {
+			cfg.respondWithConversation(w, req, http.StatusOK, userID, existing)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "could not look up conversation", err)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not create conversation", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	conversation, err := qtx.CreateConversation(req.Context(), database.CreateConversationParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: userID,
+		IsGroup:   isGroup,
+		DirectKey: directKey,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// the other member started it at the same time
+		tx.Rollback()
+		existing, err := cfg.db.FindDirectConversation(req.Context(), directKey.String)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not look up conversation", err)
+			return
+		}
+		cfg.respondWithConversation(w, req, http.StatusOK, userID, existing)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not create conversation", err)
+		return
+	}
+
+	for _, memberID := range append([]uuid.UUID{userID}, others...) {
+		err = qtx.AddConversationMember(req.Context(), database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         memberID,
+			JoinedAt:       now,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not add conversation member", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not create conversation", err)
+		return
+	}
+
+	cfg.respondWithConversation(w, req, http.StatusCreated, userID, conversation)
+}
+
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, req *http.Request, code int, userID uuid.UUID, conversation database.Conversation) {
+
+	members, err := cfg.db.ListConversationMembers(req.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve conversation members", err)
+		return
+	}
+
+	formatted := Conversation{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		IsGroup:   conversation.IsGroup,
+		Members:   []ConversationMember{},
+	}
+	for _, member := range members {
+		formatted.Members = append(formatted.Members, conversationMemberFromDB(member))
+	}
+
+	respondWithJSON(w, code, formatted)
+}
+
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	listParams := database.ListConversationsForUserParams{
+		UserID:     userID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	}
+
+	rows, err := cfg.db.ListConversationsForUser(req.Context(), listParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve conversations", err)
+		return
+	}
+	rows = reversePage(p, rows)
+
+	conversationIDs := []uuid.UUID{}
+	for _, row := range rows {
+		conversationIDs = append(conversationIDs, row.ID)
+	}
+
+	members, err := cfg.db.ListConversationMembers(req.Context(), conversationIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve conversation members", err)
+		return
+	}
+
+	membersByConversation := map[uuid.UUID][]ConversationMember{}
+	for _, member := range members {
+		membersByConversation[member.ConversationID] = append(membersByConversation[member.ConversationID], conversationMemberFromDB(member))
+	}
+
+	conversations := []Conversation{}
+	for _, row := range rows {
+		conversations = append(conversations, Conversation{
+			ID:          row.ID,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+			IsGroup:     row.IsGroup,
+			Members:     membersByConversation[row.ID],
+			UnreadCount: row.UnreadCount,
+		})
+	}
+
+	nextCursor, prevCursor := p.cursors(len(rows), func(i int) string {
+		return encodeCursor(rows[i].UpdatedAt, rows[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Conversations []Conversation `json:"conversations"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+		PrevCursor    string         `json:"prev_cursor,omitempty"`
+	}{
+		Conversations: conversations,
+		NextCursor:    nextCursor,
+		PrevCursor:    prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerGetDirectMessages(w http.ResponseWriter, req *http.Request) {
+
+	userID, conversation, ok := cfg.conversationForMember(w, req)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	listParams := database.ListDirectMessagesParams{
+		ConversationID: conversation.ID,
+		ViewerID:       userID,
+		CursorTime:     p.cursor,
+		Backward:       p.backward,
+		CursorID:       p.cursorID,
+		PageSize:       p.size,
+	}
+
+	unformatted, err := cfg.db.ListDirectMessages(req.Context(), listParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve messages", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	members, err := cfg.db.ListConversationMembers(req.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve conversation members", err)
+		return
+	}
+
+	messages := []DirectMessage{}
+	for _, message := range unformatted {
+		messages = append(messages, directMessageFromDB(message, members))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Messages   []DirectMessage `json:"messages"`
+		NextCursor string          `json:"next_cursor,omitempty"`
+		PrevCursor string          `json:"prev_cursor,omitempty"`
+	}{
+		Messages:   messages,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerSendDirectMessage(w http.ResponseWriter, req *http.Request) {
+
+	userID, conversation, ok := cfg.conversationForMember(w, req)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	if err := validateDirectMessage(params.Body); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	members, err := cfg.db.ListConversationMembers(req.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve conversation members", err)
+		return
+	}
+
+	others := []uuid.UUID{}
+	for _, member := range members {
+		if member.UserID != userID {
+			others = append(others, member.UserID)
+		}
+	}
+
+	blocked, err := cfg.db.IsBlockedEitherWay(req.Context(), database.IsBlockedEitherWayParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "cannot message a blocked user", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not send message", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	message, err := qtx.CreateDirectMessage(req.Context(), database.CreateDirectMessageParams{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		ConversationID: conversation.ID,
+		SenderID:       userID,
+		Body:           params.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not send message", err)
+		return
+	}
+
+	err = qtx.TouchConversation(req.Context(), database.TouchConversationParams{
+		ID:        conversation.ID,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not send message", err)
+		return
+	}
+
+	// sending a message means the sender has read everything up to it
+	err = qtx.MarkConversationRead(req.Context(), database.MarkConversationReadParams{
+		ReadAt:         now,
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not send message", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not send message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, directMessageFromDB(message, nil))
+}
+
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, req *http.Request) {
+
+	userID, conversation, ok := cfg.conversationForMember(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.MarkConversationRead(req.Context(), database.MarkConversationReadParams{
+		ReadAt:         time.Now(),
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not mark conversation read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestDirectMessageReadReceipts(t *testing.T) {
+
+	sentAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sender, caughtUp, behind, never := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	member := func(userID uuid.UUID, lastRead sql.NullTime) database.ConversationMember {
+		return database.ConversationMember{UserID: userID, LastReadAt: lastRead}
+	}
+	members := []database.ConversationMember{
+		member(sender, sql.NullTime{Time: sentAt, Valid: true}),
+		member(caughtUp, sql.NullTime{Time: sentAt, Valid: true}),
+		member(behind, sql.NullTime{Time: sentAt.Add(-time.Second), Valid: true}),
+		member(never, sql.NullTime{}),
+	}
+
+	message := directMessageFromDB(database.DirectMessage{SenderID: sender, CreatedAt: sentAt}, members)
+	if want := []uuid.UUID{caughtUp}; !reflect.DeepEqual(message.ReadBy, want) {
+		t.Errorf("expected only members who read up to the message, not the sender, got %v", message.ReadBy)
+	}
+}
+
+func TestOtherConversationMembers(t *testing.T) {
+
+	me, alice, bob := uuid.New(), uuid.New(), uuid.New()
+
+	got := otherConversationMembers(me, []uuid.UUID{alice, me, bob, alice})
+	if want := []uuid.UUID{alice, bob}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := otherConversationMembers(me, []uuid.UUID{me}); len(got) != 0 {
+		t.Errorf("expected a conversation with yourself to have no other members, got %v", got)
+	}
+}
+
+func TestDirectConversationKey(t *testing.T) {
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+
+	if directConversationKey(alice, bob) != directConversationKey(bob, alice) {
+		t.Error("expected the same key whoever starts the conversation")
+	}
+	if directConversationKey(alice, bob) == directConversationKey(alice, carol) {
+		t.Error("expected different pairs to get different keys")
+	}
+}
+
+func TestValidateDirectMessage(t *testing.T) {
+
+	if err := validateDirectMessage(strings.Repeat("😀", maxDirectMessageLength)); err != nil {
+		t.Errorf("expected %d emoji to fit, got %v", maxDirectMessageLength, err)
+	}
+	if err := validateDirectMessage(strings.Repeat("é", maxDirectMessageLength)); err != nil {
+		t.Errorf("expected %d accented letters to fit, got %v", maxDirectMessageLength, err)
+	}
+	for _, body := range []string{"", "  \n\t", strings.Repeat("a", maxDirectMessageLength+1)} {
+		if err := validateDirectMessage(body); err == nil {
+			t.Errorf("expected a %d character message to be rejected", len(body))
+		}
+	}
+}
+
+func TestCreateConversation(t *testing.T) {
+
+	me, other, third := testUser(), testUser(), testUser()
+	key := directConversationKey(me.ID, other.ID)
+	existing := database.Conversation{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		CreatedBy: other.ID,
+		DirectKey: sql.NullString{String: key, Valid: true},
+	}
+
+	cases := []struct {
+		name    string
+		members []uuid.UUID
+		blocked bool
+		// finds is whether each FindDirectConversation turns up the existing conversation, in order
+		finds []bool
+		// raced means someone else created the pair's conversation first, so the insert hits the key
+		raced bool
+		want  int
+		// added is how many members a new conversation gets, the creator included
+		added int
+	}{
+		{name: "direct", members: []uuid.UUID{other.ID}, want: http.StatusCreated, added: 2},
+		{name: "group", members: []uuid.UUID{other.ID, third.ID, other.ID}, want: http.StatusCreated, added: 3},
+		{name: "already talking", members: []uuid.UUID{other.ID}, finds: []bool{true}, want: http.StatusOK},
+		{name: "started by both at once", members: []uuid.UUID{other.ID}, finds: []bool{false, true}, raced: true, want: http.StatusOK},
+		{name: "blocked", members: []uuid.UUID{other.ID}, blocked: true, want: http.StatusForbidden},
+		{name: "only yourself", members: []uuid.UUID{me.ID}, want: http.StatusBadRequest},
+		{name: "nobody", members: []uuid.UUID{uuid.New()}, want: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		fake, cfg := newTestConfig(t)
+		fake.answer("GetUserByID", usersByID(me, other, third))
+		fake.returns("IsBlockedEitherWay", row(c.blocked))
+		finds := c.finds
+		fake.answer("FindDirectConversation", func(args []driver.Value) fakeResult {
+			found := len(finds) > 0 && finds[0]
+			if len(finds) > 0 {
+				finds = finds[1:]
+			}
+			if !found {
+				return fakeResult{}
+			}
+			return fakeResult{rows: [][]driver.Value{row(existing)}}
+		})
+		fake.answer("CreateConversation", func(args []driver.Value) fakeResult {
+			if c.raced {
+				return fakeResult{}
+			}
+			return echoArgs(args)
+		})
+
+		body, _ := json.Marshal(map[string][]uuid.UUID{"member_ids": c.members})
+		w := httptest.NewRecorder()
+		cfg.handlerCreateConversation(w, authorizedRequest(t, me.ID, http.MethodPost, "/api/conversations", string(body)))
+
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.want, w.Code, w.Body)
+			continue
+		}
+
+		for _, find := range fake.calledWith("FindDirectConversation") {
+			if find[0] != key {
+				t.Errorf("%s: expected the conversation looked up by %q, got %v", c.name, key, find[0])
+			}
+		}
+		if c.added == 2 || c.raced {
+			created := fake.calledWith("CreateConversation")
+			if len(created) != 1 || created[0][5] != key {
+				t.Errorf("%s: expected the conversation created with key %q, got %v", c.name, key, created)
+			}
+		}
+
+		added := fake.calledWith("AddConversationMember")
+		if len(added) != c.added {
+			t.Errorf("%s: expected %d members added, got %v", c.name, c.added, added)
+		}
+		if c.added > 0 && added[0][1] != me.ID.String() {
+			t.Errorf("%s: expected the creator to be a member, got %v", c.name, added)
+		}
+		if len(c.finds) > 0 {
+			conversation := Conversation{}
+			if err := json.Unmarshal(w.Body.Bytes(), &conversation); err != nil || conversation.ID != existing.ID {
+				t.Errorf("%s: expected the existing conversation %s, got %s", c.name, existing.ID, w.Body)
+			}
+		}
+	}
+}
+
+func TestSendDirectMessage(t *testing.T) {
+
+	me, other := uuid.New(), uuid.New()
+	conversation := database.Conversation{ID: uuid.New(), CreatedBy: me}
+
+	cases := []struct {
+		name    string
+		member  bool
+		body    string
+		blocked bool
+		want    int
+	}{
+		{name: "sent", member: true, body: "hello", want: http.StatusCreated},
+		{name: "not a member", body: "hello", want: http.StatusNotFound},
+		{name: "empty", member: true, body: "  ", want: http.StatusBadRequest},
+		{name: "too long", member: true, body: strings.Repeat("a", maxDirectMessageLength+1), want: http.StatusBadRequest},
+		{name: "blocked", member: true, body: "hello", blocked: true, want: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		fake, cfg := newTestConfig(t)
+		if c.member {
+			fake.returns("GetConversationForMember", row(conversation))
+		}
+		fake.returns("ListConversationMembers",
+			row(database.ConversationMember{ConversationID: conversation.ID, UserID: me}),
+			row(database.ConversationMember{ConversationID: conversation.ID, UserID: other}))
+		fake.returns("IsBlockedEitherWay", row(c.blocked))
+		fake.answer("CreateDirectMessage", echoArgs)
+
+		body, _ := json.Marshal(map[string]string{"body": c.body})
+		req := authorizedRequest(t, me, http.MethodPost, "/api/conversations/"+conversation.ID.String()+"/messages", string(body))
+		req.SetPathValue("conversationID", conversation.ID.String())
+		w := httptest.NewRecorder()
+		cfg.handlerSendDirectMessage(w, req)
+
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.want, w.Code, w.Body)
+		}
+		sent := fake.calledWith("CreateDirectMessage")
+		if c.want != http.StatusCreated {
+			if len(sent) != 0 {
+				t.Errorf("%s: expected nothing sent, got %v", c.name, sent)
+			}
+			continue
+		}
+		if len(sent) != 1 || sent[0][2] != conversation.ID.String() || sent[0][3] != me.String() || sent[0][4] != c.body {
+			t.Errorf("%s: expected the message sent by %s, got %v", c.name, me, sent)
+		}
+		if blockArgs := fake.calledWith("IsBlockedEitherWay"); len(blockArgs) != 1 || !strings.Contains(blockArgs[0][1].(string), other.String()) || strings.Contains(blockArgs[0][1].(string), me.String()) {
+			t.Errorf("%s: expected blocks checked against the other members, got %v", c.name, blockArgs)
+		}
+		// the sender has read their own message
+		if read := fake.calledWith("MarkConversationRead"); len(read) != 1 || read[0][2] != me.String() {
+			t.Errorf("%s: expected the sender's read receipt moved up, got %v", c.name, read)
+		}
+		if touched := fake.calledWith("TouchConversation"); len(touched) != 1 {
+			t.Errorf("%s: expected the conversation bumped, got %v", c.name, touched)
+		}
+	}
+}
+
+func TestMarkConversationRead(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	me := uuid.New()
+	conversation := database.Conversation{ID: uuid.New()}
+	fake.returns("GetConversationForMember", row(conversation))
+
+	req := authorizedRequest(t, me, http.MethodPost, "/api/conversations/"+conversation.ID.String()+"/read", "")
+	req.SetPathValue("conversationID", conversation.ID.String())
+	w := httptest.NewRecorder()
+	cfg.handlerMarkConversationRead(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d %s", w.Code, w.Body)
+	}
+	read := fake.calledWith("MarkConversationRead")
+	if len(read) != 1 || read[0][1] != conversation.ID.String() || read[0][2] != me.String() {
+		t.Errorf("expected %s's receipt in %s moved up, got %v", me, conversation.ID, read)
+	}
+}
+
+func TestBlockUserSeversFollows(t *testing.T) {
+
+	me, other := testUser(), testUser()
+
+	cases := []struct {
+		name   string
+		target uuid.UUID
+		want   int
+	}{
+		{"block", other.ID, http.StatusNoContent},
+		{"yourself", me.ID, http.StatusBadRequest},
+		{"nobody", uuid.New(), http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		fake, cfg := newTestConfig(t)
+		fake.answer("GetUserByID", usersByID(me, other))
+
+		req := authorizedRequest(t, me.ID, http.MethodPost, "/api/users/"+c.target.String()+"/block", "")
+		req.SetPathValue("userID", c.target.String())
+		w := httptest.NewRecorder()
+		cfg.handlerBlockUser(w, req)
+
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.want, w.Code, w.Body)
+		}
+		blocked, severed := fake.calledWith("BlockUser"), fake.calledWith("RemoveFollowsBetween")
+		if c.want != http.StatusNoContent {
+			if len(blocked) != 0 || len(severed) != 0 {
+				t.Errorf("%s: expected nothing changed, got %v %v", c.name, blocked, severed)
+			}
+			continue
+		}
+		if len(blocked) != 1 || blocked[0][0] != me.ID.String() || blocked[0][1] != other.ID.String() {
+			t.Errorf("%s: expected %s to block %s, got %v", c.name, me.ID, other.ID, blocked)
+		}
+		if len(severed) != 1 {
+			t.Errorf("%s: expected follows both ways removed, got %v", c.name, severed)
+		}
+	}
+}
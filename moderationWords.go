@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const (
+	maxModerationWordLength = 64
+	// other instances pick up word list changes this often
+	moderationRefreshInterval = time.Minute
+)
+
+var errChirpRejected = errors.New("chirp contains words that aren't allowed")
+
+type ModerationWord struct {
+	ID        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Word      string            `json:"word"`
+	Action    moderation.Action `json:"action"`
+}
+
+type ChirpFlag struct {
+	Chirp     Chirp     `json:"chirp"`
+	Words     []string  `json:"words"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+func moderationWordFromDB(word database.ModerationWord) ModerationWord {
+	return ModerationWord{
+		ID:        word.ID,
+		CreatedAt: word.CreatedAt,
+		UpdatedAt: word.UpdatedAt,
+		Word:      word.Word,
+		Action:    moderation.Action(word.Action),
+	}
+}
+
+// loadModerationRules reads the word list for the moderation cache
+func (cfg *apiConfig) loadModerationRules(ctx context.Context) ([]moderation.Rule, error) {
+	rows, err := cfg.db.GetModerationRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []moderation.Rule{}
+	for _, row := range rows {
+		rules = append(rules, moderation.Rule{Word: row.Word, Action: moderation.Action(row.Action)})
+	}
+	return rules, nil
+}
+
+// runModerationRefresher reloads the word list until ctx is cancelled, this instance reloads
+// straight away after its own changes but has to poll for everyone else's
+func (cfg *apiConfig) runModerationRefresher(ctx context.Context) {
+
+	if err := cfg.moderation.Refresh(ctx); err != nil {
+		log.Println("could not load moderation words: ", err)
+	}
+
+	ticker := time.NewTicker(moderationRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.moderation.Refresh(ctx); err != nil {
+				log.Println("could not refresh moderation words: ", err)
+			}
+		}
+	}
+}
+
+// moderateText runs text through the word list, text with a rejected word is an error
+func (cfg *apiConfig) moderateText(text string) (moderation.Result, error) {
+	result := cfg.moderation.Filter().Check(text)
+	if result.Action == moderation.ActionReject {
+		return result, errChirpRejected
+	}
+	return result, nil
+}
+
+// flagChirp queues a chirp for review when one of its words asked for it
+func flagChirp(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	if result.Action != moderation.ActionFlag {
+		return nil
+	}
+	return qtx.FlagChirp(ctx, database.FlagChirpParams{
+		ChirpID:   chirpID,
+		CreatedAt: time.Now(),
+		Words:     result.Words,
+	})
+}
+
+type moderationWordParameters struct {
+	Word   string `json:"word"`
+	Action string `json:"action"`
+}
+
+func (cfg *apiConfig) handlerGetModerationWords(w http.ResponseWriter, req *http.Request) {
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetModerationWords(req.Context(), database.GetModerationWordsParams{
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve moderation words", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	words := []ModerationWord{}
+	for _, word := range unformatted {
+		words = append(words, moderationWordFromDB(word))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, words)
+}
+
+func (cfg *apiConfig) handlerCreateModerationWord(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	params := moderationWordParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	word := strings.ToLower(strings.TrimSpace(params.Word))
+	if word == "" || utf8.RuneCountInString(word) > maxModerationWordLength {
+		respondWithError(w, http.StatusBadRequest, "word must be 1-64 characters", nil)
+		return
+	}
+
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not add word", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	created, err := qtx.CreateModerationWord(req.Context(), database.CreateModerationWordParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Word:      word,
+		Action:    string(action),
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "word is already on the list", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not add word", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      moderationActionAddWord,
+		Note:        created.Word + " (" + created.Action + ")",
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not add word", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not add word", err)
+		return
+	}
+
+	cfg.refreshModeration(req.Context())
+	respondWithJSON(w, http.StatusCreated, moderationWordFromDB(created))
+}
+
+func (cfg *apiConfig) handlerUpdateModerationWord(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	wordID, err := uuid.Parse(req.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid word id", err)
+		return
+	}
+
+	params := moderationWordParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update word", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	updated, err := qtx.UpdateModerationWord(req.Context(), database.UpdateModerationWordParams{
+		Action:    string(action),
+		UpdatedAt: time.Now(),
+		ID:        wordID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "word not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update word", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      moderationActionUpdateWord,
+		Note:        updated.Word + " (" + updated.Action + ")",
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update word", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update word", err)
+		return
+	}
+
+	cfg.refreshModeration(req.Context())
+	respondWithJSON(w, http.StatusOK, moderationWordFromDB(updated))
+}
+
+func (cfg *apiConfig) handlerDeleteModerationWord(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	wordID, err := uuid.Parse(req.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid word id", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not delete word", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	deleted, err := qtx.DeleteModerationWord(req.Context(), wordID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "word not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not delete word", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      moderationActionRemoveWord,
+		Note:        deleted.Word,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not delete word", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not delete word", err)
+		return
+	}
+
+	cfg.refreshModeration(req.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// refreshModeration reloads the word list after a change, a failure only delays the change
+// until the next scheduled refresh
+func (cfg *apiConfig) refreshModeration(ctx context.Context) {
+	if err := cfg.moderation.Refresh(ctx); err != nil {
+		log.Println("could not refresh moderation words: ", err)
+	}
+}
+
+func (cfg *apiConfig) handlerGetChirpFlags(w http.ResponseWriter, req *http.Request) {
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetChirpFlags(req.Context(), database.GetChirpFlagsParams{
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve flagged chirps", err)
+		return
+	}
+	rows = reversePage(p, rows)
+
+	flags := []ChirpFlag{}
+	for _, row := range rows {
+		flags = append(flags, ChirpFlag{
+			Chirp:     chirpFromDB(row.Chirp),
+			Words:     row.ChirpFlag.Words,
+			FlaggedAt: row.ChirpFlag.CreatedAt,
+		})
+	}
+
+	nextCursor, prevCursor := p.cursors(len(rows), func(i int) string {
+		return encodeCursor(rows[i].ChirpFlag.CreatedAt, rows[i].ChirpFlag.ChirpID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, flags)
+}
+
+// handlerDismissChirpFlag clears a chirp from review once a moderator has decided it can stay
+func (cfg *apiConfig) handlerDismissChirpFlag(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not dismiss flag", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	dismissed, err := qtx.DismissChirpFlag(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not dismiss flag", err)
+		return
+	}
+	if dismissed == 0 {
+		respondWithError(w, http.StatusNotFound, "chirp is not flagged", nil)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:        moderationActionDismissFlag,
+		TargetChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+		Note:          "",
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not dismiss flag", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not dismiss flag", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
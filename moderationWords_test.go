@@ -0,0 +1,68 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestCreateModerationWord(t *testing.T) {
+
+	moderator := uuid.New()
+
+	cases := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"added", `{"word":"  Spam ","action":"mask"}`, nil, http.StatusCreated},
+		{"blank word", `{"word":" ","action":"mask"}`, nil, http.StatusBadRequest},
+		{"unknown action", `{"word":"spam","action":"ban"}`, nil, http.StatusBadRequest},
+		{"duplicate", `{"word":"spam","action":"mask"}`, &pq.Error{Code: "23505"}, http.StatusConflict},
+		{"not saved", `{"word":"spam","action":"mask"}`, errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("CreateModerationWord", func(args []driver.Value) fakeResult {
+				if c.err != nil {
+					return fakeResult{err: c.err}
+				}
+				return echoArgs(args)
+			})
+
+			w := httptest.NewRecorder()
+			cfg.handlerCreateModerationWord(w, authorizedRequest(t, moderator, http.MethodPost, "/admin/moderation/words", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			logged := fake.calledWith("LogModerationAction")
+			if c.status != http.StatusCreated {
+				if len(logged) != 0 {
+					t.Errorf("expected nothing logged, got %v", logged)
+				}
+				return
+			}
+
+			got := ModerationWord{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Word != "spam" || got.Action != "mask" {
+				t.Errorf("expected the word lowercased and trimmed, got %+v", got)
+			}
+			if len(logged) != 1 || logged[0][2] != moderator.String() || logged[0][4] != moderationActionAddWord {
+				t.Errorf("expected the word logged against the moderator, got %v", logged)
+			}
+		})
+	}
+}
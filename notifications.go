@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/google/uuid"
+)
+
+// how many of the most recent actors are listed on a grouped notification
+const notificationActorPreview = 3
+
+type Notification struct {
+	ID         uuid.UUID   `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Kind       string      `json:"kind"`
+	ChirpID    *uuid.UUID  `json:"chirp_id,omitempty"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	ActorCount int64       `json:"actor_count"`
+	Summary    string      `json:"summary"`
+	Read       bool        `json:"read"`
+}
+
+// subscribeNotifications turns social events into notifications for the user they concern
+func (cfg *apiConfig) subscribeNotifications(bus *events.Bus) {
+	bus.Subscribe(cfg.recordNotification,
+		events.KindFollow,
+		events.KindLike,
+		events.KindReply,
+		events.KindMention,
+		events.KindRechirp,
+		events.KindFollowRequest,
+		events.KindWarning,
+	)
+}
+
+func (cfg *apiConfig) recordNotification(ctx context.Context, event events.Event) error {
+
+	if event.ActorID == event.UserID {
+		return nil
+	}
+
+	// likes, rechirps and mentions can come from anyone who sees the chirp, but not from
+	// someone on either side of a block
+	switch event.Kind {
+	case events.KindLike, events.KindRechirp, events.KindMention:
+		blocked, err := cfg.db.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+			UserID:   event.UserID,
+			OtherIds: []uuid.UUID{event.ActorID},
+		})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return nil
+		}
+	}
+
+	chirpID := uuid.NullUUID{
+		UUID:  event.ChirpID,
+		Valid: event.ChirpID != uuid.Nil,
+	}
+
+	notification, err := cfg.db.UpsertNotification(ctx, database.UpsertNotificationParams{
+		ID:        uuid.New(),
+		CreatedAt: event.OccurredAt,
+		UpdatedAt: event.OccurredAt,
+		UserID:    event.UserID,
+		Kind:      string(event.Kind),
+		ChirpID:   chirpID,
+		GroupKey:  notificationGroupKey(event),
+	})
+	if err != nil {
+		return err
+	}
+
+	if event.ActorID != uuid.Nil {
+		err = cfg.db.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: notification.ID,
+			ActorID:        event.ActorID,
+			CreatedAt:      event.OccurredAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pushed := struct {
+		ID         uuid.UUID  `json:"id"`
+		Kind       string     `json:"kind"`
+		ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+		ActorID    *uuid.UUID `json:"actor_id,omitempty"`
+		OccurredAt time.Time  `json:"occurred_at"`
+	}{
+		ID:         notification.ID,
+		Kind:       notification.Kind,
+		OccurredAt: event.OccurredAt,
+	}
+	if chirpID.Valid {
+		pushed.ChirpID = &chirpID.UUID
+	}
+	if event.ActorID != uuid.Nil {
+		pushed.ActorID = &event.ActorID
+	}
+	cfg.publishRealtime(ctx, realtime.NotificationsChannel(event.UserID), realtime.TypeNotification, pushed)
+
+	return nil
+}
+
+// notificationGroupKey decides which events collapse into a single notification,
+// e.g. every like on the same chirp becomes "n people liked your chirp"
+func notificationGroupKey(event events.Event) string {
+	if event.ChirpID == uuid.Nil {
+		return string(event.Kind)
+	}
+	return string(event.Kind) + ":" + event.ChirpID.String()
+}
+
+func notificationSummary(kind string, actorCount int64) string {
+
+	who := "1 person"
+	if actorCount != 1 {
+		who = fmt.Sprintf("%d people", actorCount)
+	}
+
+	switch events.Kind(kind) {
+	case events.KindFollow:
+		return who + " followed you"
+	case events.KindLike:
+		return who + " liked your chirp"
+	case events.KindReply:
+		return who + " replied to your chirp"
+	case events.KindMention:
+		return who + " mentioned you"
+	case events.KindRechirp:
+		return who + " rechirped your chirp"
+	case events.KindFollowRequest:
+		return who + " requested to follow you"
+	case events.KindWarning:
+		return "a moderator has warned you about breaking the rules"
+	}
+	return who + " interacted with you"
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	listParams := database.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: req.URL.Query().Get("unread") == "true",
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	}
+
+	rows, err := cfg.db.ListNotifications(req.Context(), listParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve notifications", err)
+		return
+	}
+	rows = reversePage(p, rows)
+
+	notificationIDs := []uuid.UUID{}
+	for _, row := range rows {
+		notificationIDs = append(notificationIDs, row.ID)
+	}
+
+	actors, err := cfg.db.ListRecentNotificationActors(req.Context(), database.ListRecentNotificationActorsParams{
+		NotificationIds: notificationIDs,
+		PerNotification: notificationActorPreview,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve notifications", err)
+		return
+	}
+
+	actorsByNotification := map[uuid.UUID][]uuid.UUID{}
+	for _, actor := range actors {
+		actorsByNotification[actor.NotificationID] = append(actorsByNotification[actor.NotificationID], actor.ActorID)
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not count notifications", err)
+		return
+	}
+
+	notifications := []Notification{}
+	for _, row := range rows {
+		notification := Notification{
+			ID:         row.ID,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+			Kind:       row.Kind,
+			ActorIDs:   actorsByNotification[row.ID],
+			ActorCount: row.ActorCount,
+			Summary:    notificationSummary(row.Kind, row.ActorCount),
+			Read:       row.ReadAt.Valid,
+		}
+		if row.ChirpID.Valid {
+			notification.ChirpID = &row.ChirpID.UUID
+		}
+		notifications = append(notifications, notification)
+	}
+
+	nextCursor, prevCursor := p.cursors(len(rows), func(i int) string {
+		return encodeCursor(rows[i].CreatedAt, rows[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor,omitempty"`
+		PrevCursor    string         `json:"prev_cursor,omitempty"`
+	}{
+		Notifications: notifications,
+		UnreadCount:   unread,
+		NextCursor:    nextCursor,
+		PrevCursor:    prevCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	notificationID, err := uuid.Parse(req.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract notification id from url", err)
+		return
+	}
+
+	updated, err := cfg.db.MarkNotificationRead(req.Context(), database.MarkNotificationReadParams{
+		ReadAt: time.Now(),
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not mark notification read", err)
+		return
+	}
+
+	if updated == 0 {
+		respondWithError(w, http.StatusNotFound, "no unread notification with that id", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMarkAllNotificationsRead(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	err = cfg.db.MarkAllNotificationsRead(req.Context(), database.MarkAllNotificationsReadParams{
+		ReadAt: time.Now(),
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not mark notifications read", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/google/uuid"
+)
+
+func TestNotificationGroupKey(t *testing.T) {
+
+	chirpID, otherChirpID := uuid.New(), uuid.New()
+	key := func(kind events.Kind, chirpID uuid.UUID) string {
+		return notificationGroupKey(events.Event{Kind: kind, ActorID: uuid.New(), ChirpID: chirpID})
+	}
+
+	if key(events.KindLike, chirpID) != key(events.KindLike, chirpID) {
+		t.Error("expected likes on the same chirp by different people to group together")
+	}
+	if key(events.KindLike, chirpID) == key(events.KindLike, otherChirpID) {
+		t.Error("expected likes on different chirps to stay apart")
+	}
+	if key(events.KindLike, chirpID) == key(events.KindRechirp, chirpID) {
+		t.Error("expected different kinds on the same chirp to stay apart")
+	}
+	if key(events.KindFollow, uuid.Nil) != key(events.KindFollow, uuid.Nil) {
+		t.Error("expected follows to group together")
+	}
+}
+
+func TestNotificationSummary(t *testing.T) {
+
+	cases := []struct {
+		kind       events.Kind
+		actorCount int64
+		want       string
+	}{
+		{events.KindLike, 1, "1 person liked your chirp"},
+		{events.KindLike, 3, "3 people liked your chirp"},
+		{events.KindFollow, 2, "2 people followed you"},
+		{events.KindReply, 1, "1 person replied to your chirp"},
+		{events.KindMention, 1, "1 person mentioned you"},
+		{events.KindRechirp, 4, "4 people rechirped your chirp"},
+		{events.KindWarning, 1, "a moderator has warned you about breaking the rules"},
+	}
+
+	for _, c := range cases {
+		if got := notificationSummary(string(c.kind), c.actorCount); got != c.want {
+			t.Errorf("%s with %d actors: expected %q, got %q", c.kind, c.actorCount, c.want, got)
+		}
+	}
+}
+
+func TestRecordNotificationGroupsActors(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	author, chirpID := uuid.New(), uuid.New()
+
+	// the second like lands on the unread notification the first one created
+	grouped := database.Notification{ID: uuid.New(), UserID: author, Kind: string(events.KindLike)}
+	fake.returns("UpsertNotification", row(grouped))
+
+	likers := []uuid.UUID{uuid.New(), uuid.New()}
+	for _, liker := range likers {
+		err := cfg.recordNotification(context.Background(), events.Event{
+			Kind:       events.KindLike,
+			ActorID:    liker,
+			UserID:     author,
+			ChirpID:    chirpID,
+			OccurredAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upserts := fake.calledWith("UpsertNotification")
+	if len(upserts) != 2 {
+		t.Fatalf("expected both likes upserted, got %v", upserts)
+	}
+	for _, upsert := range upserts {
+		if upsert[3] != author.String() || upsert[4] != string(events.KindLike) || upsert[6] != "like:"+chirpID.String() {
+			t.Errorf("expected a like for %s grouped on the chirp, got %v", author, upsert)
+		}
+	}
+
+	actors := fake.calledWith("AddNotificationActor")
+	if len(actors) != 2 {
+		t.Fatalf("expected both likers added, got %v", actors)
+	}
+	for i, actor := range actors {
+		if actor[0] != grouped.ID.String() || actor[1] != likers[i].String() {
+			t.Errorf("expected %s added to %s, got %v", likers[i], grouped.ID, actor)
+		}
+	}
+}
+
+func TestRecordNotificationSkipsYourself(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	user := uuid.New()
+
+	err := cfg.recordNotification(context.Background(), events.Event{Kind: events.KindLike, ActorID: user, UserID: user, ChirpID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upserts := fake.calledWith("UpsertNotification"); len(upserts) != 0 {
+		t.Errorf("expected liking your own chirp not to notify you, got %v", upserts)
+	}
+}
+
+func TestRecordNotificationSkipsBlocks(t *testing.T) {
+
+	user, actor := uuid.New(), uuid.New()
+
+	for _, kind := range []events.Kind{events.KindLike, events.KindRechirp, events.KindMention} {
+		for _, blocked := range []bool{true, false} {
+			fake, cfg := newTestConfig(t)
+			fake.returns("IsBlockedEitherWay", row(blocked))
+			fake.returns("UpsertNotification", row(database.Notification{ID: uuid.New(), UserID: user, Kind: string(kind)}))
+
+			err := cfg.recordNotification(context.Background(), events.Event{
+				Kind:       kind,
+				ActorID:    actor,
+				UserID:     user,
+				ChirpID:    uuid.New(),
+				OccurredAt: time.Now(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checked := fake.calledWith("IsBlockedEitherWay")
+			if len(checked) != 1 || checked[0][0] != user.String() || !strings.Contains(checked[0][1].(string), actor.String()) {
+				t.Errorf("%s: expected the block checked between %s and %s, got %v", kind, user, actor, checked)
+			}
+			if upserts := fake.calledWith("UpsertNotification"); (len(upserts) == 1) == blocked {
+				t.Errorf("%s: expected a notification only without a block, blocked %v got %v", kind, blocked, upserts)
+			}
+		}
+	}
+}
+
+func TestGetNotifications(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	user, chirpID := uuid.New(), uuid.New()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	liked := database.ListNotificationsRow{
+		ID: uuid.New(), CreatedAt: now.Add(-time.Hour), UpdatedAt: now, UserID: user,
+		Kind: string(events.KindLike), ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true}, ActorCount: 5,
+	}
+	// a later follower bumped updated_at, the page still ends where the group was created
+	followed := database.ListNotificationsRow{
+		ID: uuid.New(), CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-30 * time.Minute), UserID: user,
+		Kind: string(events.KindFollow), ReadAt: sql.NullTime{Time: now, Valid: true}, ActorCount: 1,
+	}
+	fake.returns("ListNotifications", row(liked), row(followed))
+	likers := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	follower := uuid.New()
+	fake.returns("ListRecentNotificationActors",
+		row(liked.ID, likers[0]), row(liked.ID, likers[1]), row(liked.ID, likers[2]), row(followed.ID, follower))
+	fake.returns("CountUnreadNotifications", row(int64(1)))
+
+	w := httptest.NewRecorder()
+	cfg.handlerGetNotifications(w, authorizedRequest(t, user, http.MethodGet, "/api/notifications?limit=2", ""))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+	response := struct {
+		Notifications []Notification `json:"notifications"`
+		UnreadCount   int64          `json:"unread_count"`
+		NextCursor    string         `json:"next_cursor"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Notifications) != 2 {
+		t.Fatalf("expected both notifications, got %s", w.Body)
+	}
+	first, second := response.Notifications[0], response.Notifications[1]
+	if first.Summary != "5 people liked your chirp" || first.ActorCount != 5 || len(first.ActorIDs) != 3 || first.Read {
+		t.Errorf("expected an unread like from 5 people previewing 3, got %+v", first)
+	}
+	if first.ChirpID == nil || *first.ChirpID != chirpID {
+		t.Errorf("expected the like to point at %s, got %v", chirpID, first.ChirpID)
+	}
+	if second.Summary != "1 person followed you" || second.ChirpID != nil || !second.Read || len(second.ActorIDs) != 1 || second.ActorIDs[0] != follower {
+		t.Errorf("expected a read follow from %s, got %+v", follower, second)
+	}
+	if response.UnreadCount != 1 {
+		t.Errorf("expected 1 unread, got %d", response.UnreadCount)
+	}
+	if want := encodeCursor(followed.CreatedAt, followed.ID); response.NextCursor != want {
+		t.Errorf("expected a full page to have next cursor %q, got %q", want, response.NextCursor)
+	}
+
+	listed := fake.calledWith("ListNotifications")
+	if len(listed) != 1 || listed[0][0] != user.String() || listed[0][1] != false || listed[0][2] != nil || listed[0][3] != false || listed[0][5] != int64(2) {
+		t.Errorf("expected %s's first page of 2, got %v", user, listed)
+	}
+}
+
+func TestGetNotificationsRejectsBadCursor(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+
+	w := httptest.NewRecorder()
+	cfg.handlerGetNotifications(w, authorizedRequest(t, uuid.New(), http.MethodGet, "/api/notifications?cursor=nope", ""))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	if listed := fake.calledWith("ListNotifications"); len(listed) != 0 {
+		t.Errorf("expected nothing listed, got %v", listed)
+	}
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+
+	cases := []struct {
+		name    string
+		updated int
+		want    int
+	}{
+		{"unread", 1, http.StatusNoContent},
+		{"missing, someone else's or already read", 0, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		fake, cfg := newTestConfig(t)
+		user, notificationID := uuid.New(), uuid.New()
+		fake.returns("MarkNotificationRead", make([][]driver.Value, c.updated)...)
+
+		req := authorizedRequest(t, user, http.MethodPost, "/api/notifications/"+notificationID.String()+"/read", "")
+		req.SetPathValue("notificationID", notificationID.String())
+		w := httptest.NewRecorder()
+		cfg.handlerMarkNotificationRead(w, req)
+
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, w.Code)
+		}
+		marked := fake.calledWith("MarkNotificationRead")
+		if len(marked) != 1 || marked[0][1] != notificationID.String() || marked[0][2] != user.String() {
+			t.Errorf("%s: expected only %s's notification marked, got %v", c.name, user, marked)
+		}
+	}
+}
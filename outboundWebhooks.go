@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookScopeUser   = "user"
+	webhookScopeGlobal = "global"
+
+	webhookDispatchInterval = 5 * time.Second
+	// a claimed delivery is handed out again if its dispatcher hasn't recorded it by then
+	webhookDeliveryLease = 2 * outbound.DefaultTimeout
+)
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Scope               string     `json:"scope"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	// Secret is only shown when the endpoint is registered
+	Secret string `json:"secret,omitempty"`
+}
+
+func webhookEndpointFromDB(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	formatted := WebhookEndpoint{
+		ID:                  endpoint.ID,
+		CreatedAt:           endpoint.CreatedAt,
+		UpdatedAt:           endpoint.UpdatedAt,
+		Scope:               endpoint.Scope,
+		URL:                 endpoint.Url,
+		Events:              endpoint.EventTypes,
+		Enabled:             endpoint.Enabled,
+		ConsecutiveFailures: endpoint.ConsecutiveFailures,
+	}
+	if endpoint.DisabledAt.Valid {
+		formatted.DisabledAt = &endpoint.DisabledAt.Time
+	}
+	return formatted
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID                `json:"id"`
+	CreatedAt      time.Time                `json:"created_at"`
+	EventID        uuid.UUID                `json:"event_id"`
+	EventType      string                   `json:"event_type"`
+	Payload        json.RawMessage          `json:"payload"`
+	Status         string                   `json:"status"`
+	Attempts       int32                    `json:"attempts"`
+	NextAttemptAt  *time.Time               `json:"next_attempt_at,omitempty"`
+	LastStatusCode int32                    `json:"last_status_code,omitempty"`
+	LastError      string                   `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time               `json:"delivered_at,omitempty"`
+	AttemptLog     []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// webhookDeliveryFromDB formats a delivery, showErrors includes what the endpoint sent back
+func webhookDeliveryFromDB(delivery database.WebhookDelivery, showErrors bool) WebhookDelivery {
+	formatted := WebhookDelivery{
+		ID:             delivery.ID,
+		CreatedAt:      delivery.CreatedAt,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode.Int32,
+	}
+	if showErrors {
+		formatted.LastError = delivery.LastError.String
+	}
+	if delivery.Status == outbound.StatusPending {
+		formatted.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.DeliveredAt.Valid {
+		formatted.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return formatted
+}
+
+type WebhookDeliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  int32     `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+// outboundEvent is the body every endpoint receives
+type outboundEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// enqueueWebhookEvent writes the event to the outbox for every endpoint that should hear about it.
+// Called inside the transaction making the change, so an event is queued if and only if it happened.
+// subjectUserID is whose account or chirp the event is about.
+func enqueueWebhookEvent(ctx context.Context, qtx *database.Queries, eventType string, subjectUserID uuid.UUID, data any) error {
+
+	now := time.Now()
+	event := outboundEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: now,
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = qtx.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		CreatedAt:     now,
+		EventID:       event.ID,
+		EventType:     eventType,
+		Payload:       payload,
+		SubjectUserID: subjectUserID,
+	})
+	return err
+}
+
+func enqueueChirpWebhook(ctx context.Context, qtx *database.Queries, eventType string, chirp database.Chirp) error {
+	return enqueueWebhookEvent(ctx, qtx, eventType, chirp.UserID, chirpFromDB(chirp))
+}
+
+// webhookOutbox is the outbox table as the dispatcher sees it
+type webhookOutbox struct {
+	db     *database.Queries
+	dbConn *sql.DB
+}
+
+func (o webhookOutbox) ClaimDue(ctx context.Context, now time.Time, limit int) ([]outbound.Delivery, error) {
+
+	claimed, err := o.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil:    now.Add(webhookDeliveryLease),
+		Now:           now,
+		MaxDeliveries: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := map[uuid.UUID]database.WebhookEndpoint{}
+	deliveries := []outbound.Delivery{}
+	for _, delivery := range claimed {
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = o.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+			if err != nil {
+				return nil, err
+			}
+			endpoints[endpoint.ID] = endpoint
+		}
+		deliveries = append(deliveries, outbound.Delivery{
+			ID:         delivery.ID,
+			EndpointID: endpoint.ID,
+			Attempts:   int(delivery.Attempts),
+			URL:        endpoint.Url,
+			Secret:     endpoint.Secret,
+			EventType:  delivery.EventType,
+			Payload:    delivery.Payload,
+		})
+	}
+	return deliveries, nil
+}
+
+func (o webhookOutbox) Record(ctx context.Context, delivery outbound.Delivery, outcome outbound.Outcome) error {
+
+	tx, err := o.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := o.db.WithTx(tx)
+
+	result := outcome.Result
+	statusCode := sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0}
+	lastError := sql.NullString{}
+	if result.Err != nil {
+		lastError = sql.NullString{String: result.Err.Error(), Valid: true}
+	}
+
+	err = qtx.RecordWebhookDeliveryAttempt(ctx, database.RecordWebhookDeliveryAttemptParams{
+		ID:          uuid.New(),
+		DeliveryID:  delivery.ID,
+		AttemptedAt: outcome.AttemptedAt,
+		StatusCode:  statusCode,
+		Error:       lastError,
+		DurationMs:  int32(result.Duration / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+
+	nextAttempt := outcome.NextAttemptAt
+	if nextAttempt.IsZero() {
+		nextAttempt = outcome.AttemptedAt
+	}
+	err = qtx.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+		Status:         outcome.Status,
+		NextAttemptAt:  nextAttempt,
+		LastStatusCode: statusCode,
+		LastError:      lastError,
+		DeliveredAt:    sql.NullTime{Time: outcome.AttemptedAt, Valid: outcome.Status == outbound.StatusDelivered},
+		UpdatedAt:      outcome.AttemptedAt,
+		ID:             delivery.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.OK() {
+		if err := qtx.ResetWebhookEndpointFailures(ctx, delivery.EndpointID); err != nil {
+			return err
+		}
+	} else {
+		endpoint, err := qtx.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+			DisableAfter: outbound.DisableAfter,
+			Now:          outcome.AttemptedAt,
+			ID:           delivery.EndpointID,
+		})
+		// the endpoint was deleted while its delivery was in flight
+		if errors.Is(err, sql.ErrNoRows) {
+			return tx.Commit()
+		}
+		if err != nil {
+			return err
+		}
+		if !endpoint.Enabled && endpoint.ConsecutiveFailures == outbound.DisableAfter {
+			log.Printf("disabled webhook endpoint %s after %d failures in a row", endpoint.ID, endpoint.ConsecutiveFailures)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dispatchWebhooks is the dispatch_webhooks job, it delivers queued webhook events until the
+// outbox has no more due
+func dispatchWebhooks(ctx context.Context, dispatcher *outbound.Dispatcher) error {
+	for {
+		attempted, err := dispatcher.RunOnce(ctx)
+		if err != nil {
+			return fmt.Errorf("webhook dispatcher: %w", err)
+		}
+		if attempted < dispatcher.BatchSize {
+			return nil
+		}
+	}
+}
+
+func newWebhookSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+func (cfg *apiConfig) handlerCreateWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		// Scope is "user" unless an admin asks for "global"
+		Scope string `json:"scope"`
+	}
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	if err := cfg.webhookGuard.ValidateURL(req.Context(), params.URL); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "events must list at least one event type", nil)
+		return
+	}
+	events := []string{}
+	seen := map[string]bool{}
+	for _, eventType := range params.Events {
+		if !outbound.ValidEventType(eventType) {
+			respondWithError(w, http.StatusBadRequest, "unknown event type "+eventType, nil)
+			return
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			events = append(events, eventType)
+		}
+	}
+
+	switch params.Scope {
+	case "":
+		params.Scope = webhookScopeUser
+	case webhookScopeUser:
+	case webhookScopeGlobal:
+		user, err := cfg.db.GetUserByID(req.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+			return
+		}
+		if roleRank[user.Role] < roleRank[roleAdmin] {
+			respondWithError(w, http.StatusForbidden, "only admins can register global webhooks", nil)
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "scope must be user or global", nil)
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not register webhook", err)
+		return
+	}
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(req.Context(), database.CreateWebhookEndpointParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UserID:     userID,
+		Scope:      params.Scope,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: events,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not register webhook", err)
+		return
+	}
+
+	formatted := webhookEndpointFromDB(endpoint)
+	formatted.Secret = endpoint.Secret
+	respondWithJSON(w, http.StatusCreated, formatted)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEndpoints(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetWebhookEndpointsByUser(req.Context(), database.GetWebhookEndpointsByUserParams{
+		UserID:     userID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve webhooks", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	endpoints := []WebhookEndpoint{}
+	for _, endpoint := range unformatted {
+		endpoints = append(endpoints, webhookEndpointFromDB(endpoint))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+
+	userID, endpointID, ok := cfg.webhookEndpointRequest(w, req)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(req.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not delete webhook", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "webhook does not exist", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerEnableWebhookEndpoint switches an endpoint back on after it was disabled for failing,
+// deliveries still pending resume straight away
+func (cfg *apiConfig) handlerEnableWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+
+	userID, endpointID, ok := cfg.webhookEndpointRequest(w, req)
+	if !ok {
+		return
+	}
+
+	endpoint, err := cfg.db.EnableWebhookEndpoint(req.Context(), database.EnableWebhookEndpointParams{
+		UpdatedAt: time.Now(),
+		ID:        endpointID,
+		UserID:    userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "webhook does not exist", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not enable webhook", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEndpointFromDB(endpoint))
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+
+	endpoint, ok := cfg.ownWebhookEndpoint(w, req)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	showErrors, err := cfg.showWebhookErrors(req.Context(), endpoint.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve deliveries", err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetWebhookDeliveries(req.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve deliveries", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range unformatted {
+		deliveries = append(deliveries, webhookDeliveryFromDB(delivery, showErrors))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// handlerGetWebhookDelivery shows one delivery with every attempt made at it
+func (cfg *apiConfig) handlerGetWebhookDelivery(w http.ResponseWriter, req *http.Request) {
+
+	endpoint, ok := cfg.ownWebhookEndpoint(w, req)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid delivery id", err)
+		return
+	}
+
+	delivery, err := cfg.db.GetWebhookDelivery(req.Context(), database.GetWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "delivery does not exist", err)
+		return
+	}
+
+	attempts, err := cfg.db.GetWebhookDeliveryAttempts(req.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve delivery attempts", err)
+		return
+	}
+
+	showErrors, err := cfg.showWebhookErrors(req.Context(), endpoint.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve delivery attempts", err)
+		return
+	}
+
+	formatted := webhookDeliveryFromDB(delivery, showErrors)
+	formatted.AttemptLog = []WebhookDeliveryAttempt{}
+	for _, attempt := range attempts {
+		logged := WebhookDeliveryAttempt{
+			AttemptedAt: attempt.AttemptedAt,
+			StatusCode:  attempt.StatusCode.Int32,
+			DurationMs:  attempt.DurationMs,
+		}
+		if showErrors {
+			logged.Error = attempt.Error.String
+		}
+		formatted.AttemptLog = append(formatted.AttemptLog, logged)
+	}
+
+	respondWithJSON(w, http.StatusOK, formatted)
+}
+
+// showWebhookErrors reports whether userID may see the errors deliveries failed with. They hold
+// what the endpoint responded, which only admins are trusted with in case a url reached
+// somewhere it shouldn't have.
+func (cfg *apiConfig) showWebhookErrors(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return roleRank[user.Role] >= roleRank[roleAdmin], nil
+}
+
+// webhookEndpointRequest reads the user and the endpoint in the url
+func (cfg *apiConfig) webhookEndpointRequest(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	endpointID, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid webhook id", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, endpointID, true
+}
+
+// ownWebhookEndpoint is the endpoint in the url, as long as the user registered it
+func (cfg *apiConfig) ownWebhookEndpoint(w http.ResponseWriter, req *http.Request) (database.WebhookEndpoint, bool) {
+
+	userID, endpointID, ok := cfg.webhookEndpointRequest(w, req)
+	if !ok {
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(req.Context(), endpointID)
+	if err != nil || endpoint.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "webhook does not exist", err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/google/uuid"
+)
+
+func TestCreateWebhookEndpointRejectsPrivateURLs(t *testing.T) {
+
+	owner := testUser()
+
+	cases := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"public", "https://93.184.216.34/hooks", http.StatusCreated},
+		{"loopback", "http://127.0.0.1:8080/hooks", http.StatusBadRequest},
+		{"localhost", "http://localhost/hooks", http.StatusBadRequest},
+		{"private", "http://10.1.2.3/hooks", http.StatusBadRequest},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data", http.StatusBadRequest},
+		{"unspecified", "http://0.0.0.0/hooks", http.StatusBadRequest},
+		{"ipv6 loopback", "http://[::1]/hooks", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("CreateWebhookEndpoint", func(args []driver.Value) fakeResult {
+				return fakeResult{rows: [][]driver.Value{row(database.WebhookEndpoint{
+					ID:         uuid.MustParse(args[0].(string)),
+					CreatedAt:  args[1].(time.Time),
+					UpdatedAt:  args[1].(time.Time),
+					UserID:     owner.ID,
+					Scope:      args[3].(string),
+					Url:        args[4].(string),
+					Secret:     args[5].(string),
+					EventTypes: []string{outbound.EventChirpCreated},
+					Enabled:    true,
+				})}}
+			})
+
+			body, _ := json.Marshal(map[string]any{"url": c.url, "events": []string{outbound.EventChirpCreated}})
+			w := httptest.NewRecorder()
+			cfg.handlerCreateWebhookEndpoint(w, authorizedRequest(t, owner.ID, http.MethodPost, "/api/webhooks", string(body)))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			created := fake.calledWith("CreateWebhookEndpoint")
+			if c.status != http.StatusCreated {
+				if len(created) != 0 {
+					t.Errorf("expected nothing registered, got %v", created)
+				}
+				return
+			}
+			if len(created) != 1 || created[0][4] != c.url {
+				t.Errorf("expected the endpoint registered, got %v", created)
+			}
+		})
+	}
+
+	t.Run("allowed for development", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		cfg.webhookGuard = outbound.Guard{AllowPrivate: true}
+		fake.returns("CreateWebhookEndpoint", row(database.WebhookEndpoint{
+			ID:         uuid.New(),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+			UserID:     owner.ID,
+			Scope:      webhookScopeUser,
+			Url:        "http://127.0.0.1:8080/hooks",
+			EventTypes: []string{outbound.EventChirpCreated},
+			Enabled:    true,
+		}))
+
+		w := httptest.NewRecorder()
+		cfg.handlerCreateWebhookEndpoint(w, authorizedRequest(t, owner.ID, http.MethodPost, "/api/webhooks",
+			`{"url":"http://127.0.0.1:8080/hooks","events":["chirp.created"]}`))
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestGetWebhookDeliveryHidesErrorsFromNonAdmins(t *testing.T) {
+
+	owner, admin := testUser(), testUser()
+	owner.Role, admin.Role = roleUser, roleAdmin
+	response := "internal service says: secret=hunter2"
+
+	for _, c := range []struct {
+		name       string
+		user       database.User
+		showErrors bool
+	}{
+		{"owner", owner, false},
+		{"admin", admin, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			endpoint := database.WebhookEndpoint{
+				ID:         uuid.New(),
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+				UserID:     c.user.ID,
+				Scope:      webhookScopeUser,
+				Url:        "https://93.184.216.34/hooks",
+				EventTypes: []string{outbound.EventChirpCreated},
+				Enabled:    true,
+			}
+			delivery := database.WebhookDelivery{
+				ID:             uuid.New(),
+				CreatedAt:      time.Now(),
+				UpdatedAt:      time.Now(),
+				EndpointID:     endpoint.ID,
+				EventID:        uuid.New(),
+				EventType:      outbound.EventChirpCreated,
+				Payload:        json.RawMessage(`{}`),
+				Status:         outbound.StatusFailed,
+				Attempts:       1,
+				NextAttemptAt:  time.Now(),
+				LastStatusCode: sql.NullInt32{Int32: http.StatusForbidden, Valid: true},
+				LastError:      sql.NullString{String: response, Valid: true},
+			}
+			fake.answer("GetUserByID", usersByID(owner, admin))
+			fake.returns("GetWebhookEndpoint", row(endpoint))
+			fake.returns("GetWebhookDelivery", row(delivery))
+			fake.returns("GetWebhookDeliveryAttempts", row(database.WebhookDeliveryAttempt{
+				ID:          uuid.New(),
+				DeliveryID:  delivery.ID,
+				AttemptedAt: time.Now(),
+				StatusCode:  sql.NullInt32{Int32: http.StatusForbidden, Valid: true},
+				Error:       sql.NullString{String: response, Valid: true},
+				DurationMs:  12,
+			}))
+
+			target := "/api/webhooks/" + endpoint.ID.String() + "/deliveries/" + delivery.ID.String()
+			req := authorizedRequest(t, c.user.ID, http.MethodGet, target, "")
+			req.SetPathValue("webhookID", endpoint.ID.String())
+			req.SetPathValue("deliveryID", delivery.ID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerGetWebhookDelivery(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+			}
+			got := WebhookDelivery{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.AttemptLog) != 1 || got.LastStatusCode != http.StatusForbidden || got.AttemptLog[0].StatusCode != http.StatusForbidden {
+				t.Fatalf("expected the attempt and its status code, got %+v", got)
+			}
+			if shown := got.LastError == response && got.AttemptLog[0].Error == response; shown != c.showErrors {
+				t.Errorf("expected the endpoint's response shown %v, got %+v", c.showErrors, got)
+			}
+			if !c.showErrors && (got.LastError != "" || got.AttemptLog[0].Error != "") {
+				t.Errorf("expected no trace of the response, got %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions   = 2
+	maxPollOptions   = 4
+	maxPollOptionLen = 25
+	minPollDuration  = 5 * time.Minute
+	maxPollDuration  = 7 * 24 * time.Hour
+)
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+// Poll hides its tallies until the viewer has voted or the poll has closed
+type Poll struct {
+	ClosesAt     time.Time    `json:"closes_at"`
+	Closed       bool         `json:"closed"`
+	Options      []PollOption `json:"options"`
+	TotalVotes   *int64       `json:"total_votes,omitempty"`
+	ViewerVoteID *uuid.UUID   `json:"viewer_vote_id,omitempty"`
+}
+
+type pollParameters struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// validatePoll checks poll input before anything is written and returns the cleaned option labels
+func (cfg *apiConfig) validatePoll(params pollParameters, now time.Time) ([]string, error) {
+
+	if len(params.Options) < minPollOptions || len(params.Options) > maxPollOptions {
+		return nil, errors.New("a poll needs between 2 and 4 options")
+	}
+
+	labels := []string{}
+	seen := map[string]bool{}
+	for _, option := range params.Options {
+		label := strings.TrimSpace(option)
+		if label == "" || utf8.RuneCountInString(label) > maxPollOptionLen {
+			return nil, errors.New("poll options must be 1-25 characters")
+		}
+		if seen[strings.ToLower(label)] {
+			return nil, errors.New("poll options must be unique")
+		}
+		seen[strings.ToLower(label)] = true
+		moderated, err := cfg.moderateText(label)
+		if err != nil {
+			return nil, errors.New("poll options contain words that aren't allowed")
+		}
+		labels = append(labels, moderated.Text)
+	}
+
+	duration := params.ClosesAt.Sub(now)
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, errors.New("poll must close between 5 minutes and 7 days from now")
+	}
+
+	return labels, nil
+}
+
+// createPoll writes a poll for a chirp, it is called with the transaction that inserted the chirp
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, labels []string, closesAt, now time.Time) error {
+
+	_, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:   chirpID,
+		CreatedAt: now,
+		ClosesAt:  dbTime(closesAt),
+	})
+	if err != nil {
+		return err
+	}
+
+	for position, label := range labels {
+		_, err = qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ID:       uuid.New(),
+			ChirpID:  chirpID,
+			Position: int32(position),
+			Label:    label,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachPolls loads the polls for a page of chirps in three queries and fills in Chirp.Poll
+func (cfg *apiConfig) attachPolls(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	polls, err := cfg.db.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+
+	pollChirpIDs := []uuid.UUID{}
+	for _, poll := range polls {
+		pollChirpIDs = append(pollChirpIDs, poll.ChirpID)
+	}
+
+	tallies, err := cfg.db.GetPollOptionTallies(ctx, pollChirpIDs)
+	if err != nil {
+		return err
+	}
+
+	viewerVotes := map[uuid.UUID]uuid.UUID{}
+	if viewerID != uuid.Nil {
+		votes, err := cfg.db.GetVotesByUser(ctx, database.GetVotesByUserParams{
+			UserID:   viewerID,
+			ChirpIds: pollChirpIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			viewerVotes[vote.ChirpID] = vote.OptionID
+		}
+	}
+
+	talliesByChirp := map[uuid.UUID][]database.GetPollOptionTalliesRow{}
+	for _, tally := range tallies {
+		talliesByChirp[tally.ChirpID] = append(talliesByChirp[tally.ChirpID], tally)
+	}
+
+	pollsByChirp := map[uuid.UUID]*Poll{}
+	for _, poll := range polls {
+		vote, voted := viewerVotes[poll.ChirpID]
+		pollsByChirp[poll.ChirpID] = formatPoll(poll, talliesByChirp[poll.ChirpID], vote, voted)
+	}
+
+	for i := range chirps {
+		chirps[i].Poll = pollsByChirp[chirps[i].ID]
+	}
+
+	return nil
+}
+
+func formatPoll(poll database.Poll, tallies []database.GetPollOptionTalliesRow, vote uuid.UUID, voted bool) *Poll {
+
+	formatted := &Poll{
+		ClosesAt: poll.ClosesAt,
+		Closed:   !poll.ClosesAt.After(time.Now()),
+		Options:  []PollOption{},
+	}
+
+	showResults := voted || formatted.Closed
+	if voted {
+		formatted.ViewerVoteID = &vote
+	}
+
+	total := int64(0)
+	for _, tally := range tallies {
+		option := PollOption{
+			ID:    tally.ID,
+			Label: tally.Label,
+		}
+		if showResults {
+			votes := tally.Votes
+			option.Votes = &votes
+		}
+		total += tally.Votes
+		formatted.Options = append(formatted.Options, option)
+	}
+
+	if showResults {
+		formatted.TotalVotes = &total
+	}
+
+	return formatted
+}
+
+func (cfg *apiConfig) handlerVoteInPoll(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	poll, err := cfg.db.GetPoll(req.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp does not have a poll", err)
+		return
+	}
+
+	if !poll.ClosesAt.After(time.Now()) {
+		respondWithError(w, http.StatusConflict, "poll is closed", nil)
+		return
+	}
+
+	tallies, err := cfg.db.GetPollOptionTallies(req.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve poll", err)
+		return
+	}
+
+	validOption := false
+	for _, tally := range tallies {
+		if tally.ID == params.OptionID {
+			validOption = true
+		}
+	}
+	if !validOption {
+		respondWithError(w, http.StatusBadRequest, "option does not belong to this poll", nil)
+		return
+	}
+
+	cast, err := cfg.db.CastPollVote(req.Context(), database.CastPollVoteParams{
+		ChirpID:   chirp.ID,
+		UserID:    userID,
+		OptionID:  params.OptionID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not record vote", err)
+		return
+	}
+	if cast == 0 {
+		respondWithError(w, http.StatusConflict, "you have already voted in this poll", nil)
+		return
+	}
+
+	tallies, err = cfg.db.GetPollOptionTallies(req.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, formatPoll(poll, tallies, params.OptionID, true))
+}
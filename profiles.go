@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handlerPinChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "you can only pin your own chirps", nil)
+		return
+	}
+
+	// the user row lock keeps two concurrent pins from both passing the limit check
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.LockUser(req.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp", err)
+		return
+	}
+
+	ent, err := cfg.entitlements.For(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve entitlements", err)
+		return
+	}
+
+	pinned, err := qtx.CountPinnedChirps(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not count pinned chirps", err)
+		return
+	}
+
+	if pinned >= int64(ent.MaxPinnedChirps) {
+		respondWithError(w, http.StatusConflict, "pinned chirp limit reached", nil)
+		return
+	}
+
+	err = qtx.PinChirp(req.Context(), database.PinChirpParams{
+		UserID:   userID,
+		ChirpID:  chirp.ID,
+		PinnedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not pin chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnpinChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnpinChirp(req.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not unpin chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerGetUserChirps is a user's profile timeline, the first page starts with
+// their pinned chirps and every page continues with the rest newest first
+func (cfg *apiConfig) handlerGetUserChirps(w http.ResponseWriter, req *http.Request) {
+
+	authorID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(req.Context(), authorID); err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	viewerID := cfg.optionalUserID(req)
+	timeline := []database.Chirp{}
+	pinnedIDs := map[uuid.UUID]bool{}
+	if !p.cursor.Valid {
+		pinned, err := cfg.db.GetPinnedChirps(req.Context(), authorID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not retrieve pinned chirps", err)
+			return
+		}
+		for _, row := range pinned {
+			pinnedIDs[row.Chirp.ID] = true
+			timeline = append(timeline, row.Chirp)
+		}
+	}
+
+	unpinned, err := cfg.db.GetChirpsPageByAuthor(req.Context(), database.GetChirpsPageByAuthorParams{
+		UserID:     authorID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps", err)
+		return
+	}
+	unpinned = reversePage(p, unpinned)
+
+	visible, err := cfg.filterVisibleChirps(req.Context(), viewerID, append(timeline, unpinned...), visibility.SurfaceTimeline)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirps", err)
+		return
+	}
+
+	chirps := []Chirp{}
+	for _, chirp := range visible {
+		formatted := chirpFromDB(chirp)
+		formatted.Pinned = pinnedIDs[chirp.ID]
+		chirps = append(chirps, formatted)
+	}
+
+	err = cfg.attachPolls(req.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve polls", err)
+		return
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unpinned), func(i int) string {
+		return encodeCursor(unpinned[i].CreatedAt, unpinned[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+		PrevCursor string  `json:"prev_cursor,omitempty"`
+	}{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
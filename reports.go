@@ -0,0 +1,572 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/google/uuid"
+)
+
+const maxReportDetailsLength = 1000
+
+const (
+	reportStatusOpen      = "open"
+	reportStatusClaimed   = "claimed"
+	reportStatusResolved  = "resolved"
+	reportStatusDismissed = "dismissed"
+)
+
+// resolutions a moderator can close a report with, they double as moderation action names
+const (
+	resolutionRemoveChirp = "remove_chirp"
+	resolutionWarnUser    = "warn_user"
+	resolutionSuspendUser = "suspend_user"
+)
+
+// moderation actions that aren't resolutions
+const (
+	moderationActionClaim       = "claim"
+	moderationActionDismiss     = "dismiss"
+	moderationActionDismissFlag = "dismiss_flag"
+	moderationActionAddWord     = "add_word"
+	moderationActionUpdateWord  = "update_word"
+	moderationActionRemoveWord  = "remove_word"
+)
+
+var reportReasons = map[string]bool{
+	"spam":          true,
+	"harassment":    true,
+	"hate":          true,
+	"violence":      true,
+	"self_harm":     true,
+	"impersonation": true,
+	"other":         true,
+}
+
+var reportStatuses = map[string]bool{
+	reportStatusOpen:      true,
+	reportStatusClaimed:   true,
+	reportStatusResolved:  true,
+	reportStatusDismissed: true,
+}
+
+type Report struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ReporterID    uuid.UUID  `json:"reporter_id"`
+	TargetUserID  uuid.UUID  `json:"target_user_id"`
+	TargetChirpID *uuid.UUID `json:"target_chirp_id,omitempty"`
+	ChirpBody     *string    `json:"chirp_body,omitempty"`
+	Reason        string     `json:"reason"`
+	Details       string     `json:"details"`
+	Status        string     `json:"status"`
+	ClaimedBy     *uuid.UUID `json:"claimed_by,omitempty"`
+	Resolution    *string    `json:"resolution,omitempty"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ModeratorID   *uuid.UUID `json:"moderator_id,omitempty"`
+	ReportID      *uuid.UUID `json:"report_id,omitempty"`
+	Action        string     `json:"action"`
+	TargetUserID  *uuid.UUID `json:"target_user_id,omitempty"`
+	TargetChirpID *uuid.UUID `json:"target_chirp_id,omitempty"`
+	Note          string     `json:"note"`
+}
+
+func reportFromDB(report database.Report) Report {
+	formatted := Report{
+		ID:           report.ID,
+		CreatedAt:    report.CreatedAt,
+		UpdatedAt:    report.UpdatedAt,
+		ReporterID:   report.ReporterID,
+		TargetUserID: report.TargetUserID,
+		Reason:       report.Reason,
+		Details:      report.Details,
+		Status:       report.Status,
+	}
+	if report.TargetChirpID.Valid {
+		formatted.TargetChirpID = &report.TargetChirpID.UUID
+	}
+	if report.ChirpBody.Valid {
+		formatted.ChirpBody = &report.ChirpBody.String
+	}
+	if report.ClaimedBy.Valid {
+		formatted.ClaimedBy = &report.ClaimedBy.UUID
+	}
+	if report.Resolution.Valid {
+		formatted.Resolution = &report.Resolution.String
+	}
+	if report.ClosedAt.Valid {
+		formatted.ClosedAt = &report.ClosedAt.Time
+	}
+	return formatted
+}
+
+func moderationActionFromDB(action database.ModerationAction) ModerationAction {
+	formatted := ModerationAction{
+		ID:        action.ID,
+		CreatedAt: action.CreatedAt,
+		Action:    action.Action,
+		Note:      action.Note,
+	}
+	if action.ModeratorID.Valid {
+		formatted.ModeratorID = &action.ModeratorID.UUID
+	}
+	if action.ReportID.Valid {
+		formatted.ReportID = &action.ReportID.UUID
+	}
+	if action.TargetUserID.Valid {
+		formatted.TargetUserID = &action.TargetUserID.UUID
+	}
+	if action.TargetChirpID.Valid {
+		formatted.TargetChirpID = &action.TargetChirpID.UUID
+	}
+	return formatted
+}
+
+// logModerationAction records something a moderator did, in the same transaction as doing it
+func logModerationAction(ctx context.Context, qtx *database.Queries, params database.LogModerationActionParams) error {
+	params.ID = uuid.New()
+	params.CreatedAt = time.Now()
+	return qtx.LogModerationAction(ctx, params)
+}
+
+func (cfg *apiConfig) handlerCreateReport(w http.ResponseWriter, req *http.Request) {
+
+	reporterID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	type parameters struct {
+		ChirpID *uuid.UUID `json:"chirp_id"`
+		UserID  *uuid.UUID `json:"user_id"`
+		Reason  string     `json:"reason"`
+		Details string     `json:"details"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	if (params.ChirpID == nil) == (params.UserID == nil) {
+		respondWithError(w, http.StatusBadRequest, "report either a chirp_id or a user_id", nil)
+		return
+	}
+	if !reportReasons[params.Reason] {
+		respondWithError(w, http.StatusBadRequest, "reason must be spam, harassment, hate, violence, self_harm, impersonation or other", nil)
+		return
+	}
+	if utf8.RuneCountInString(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "details must be at most 1000 characters", nil)
+		return
+	}
+
+	now := time.Now()
+	reportParams := database.CreateReportParams{
+		ID:         uuid.New(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ReporterID: reporterID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	}
+
+	if params.ChirpID != nil {
+		chirp, err := cfg.db.GetOneChirp(req.Context(), *params.ChirpID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
+			return
+		}
+		if canView, err := cfg.canViewChirp(req.Context(), reporterID, chirp); err != nil || !canView {
+			respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
+			return
+		}
+		reportParams.TargetUserID = chirp.UserID
+		reportParams.TargetChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		reportParams.ChirpBody = sql.NullString{String: chirp.Body, Valid: true}
+	} else {
+		user, err := cfg.db.GetUserByID(req.Context(), *params.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "user does not exist", err)
+			return
+		}
+		reportParams.TargetUserID = user.ID
+	}
+
+	if reportParams.TargetUserID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "you cannot report yourself", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(req.Context(), reportParams)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "you have already reported this", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not file report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+// handlerGetReports is the moderation queue, oldest first. status takes a comma separated
+// list and defaults to reports still waiting on a decision.
+func (cfg *apiConfig) handlerGetReports(w http.ResponseWriter, req *http.Request) {
+
+	statuses := splitListParam(req.URL.Query()["status"])
+	if len(statuses) == 0 {
+		statuses = []string{reportStatusOpen, reportStatusClaimed}
+	}
+	for _, status := range statuses {
+		if !reportStatuses[status] {
+			respondWithError(w, http.StatusBadRequest, "status must be open, claimed, resolved or dismissed", nil)
+			return
+		}
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetReportQueue(req.Context(), database.GetReportQueueParams{
+		Statuses:   statuses,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve reports", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	reports := []Report{}
+	for _, report := range unformatted {
+		reports = append(reports, reportFromDB(report))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+// reportUnavailable explains why a report couldn't be claimed or closed
+func (cfg *apiConfig) reportUnavailable(w http.ResponseWriter, req *http.Request, reportID uuid.UUID) {
+	report, err := cfg.db.GetReport(req.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "report not found", err)
+		return
+	}
+	if report.Status == reportStatusClaimed {
+		respondWithError(w, http.StatusConflict, "report is claimed by another moderator", nil)
+		return
+	}
+	respondWithError(w, http.StatusConflict, "report is already "+report.Status, nil)
+}
+
+func (cfg *apiConfig) handlerClaimReport(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid report id", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not claim report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err := qtx.ClaimReport(req.Context(), database.ClaimReportParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		UpdatedAt:   time.Now(),
+		ID:          reportID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.reportUnavailable(w, req, reportID)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not claim report", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ReportID:      uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:        moderationActionClaim,
+		TargetUserID:  uuid.NullUUID{UUID: report.TargetUserID, Valid: true},
+		TargetChirpID: report.TargetChirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not claim report", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not claim report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handlerResolveReport(w http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+		// SuspendedUntil only applies to suspend_user, leaving it out suspends permanently
+		SuspendedUntil *time.Time `json:"suspended_until"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	switch params.Action {
+	case resolutionRemoveChirp, resolutionWarnUser, resolutionSuspendUser:
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be remove_chirp, warn_user or suspend_user", nil)
+		return
+	}
+	if params.SuspendedUntil != nil && !params.SuspendedUntil.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+		return
+	}
+
+	cfg.closeReport(w, req, reportStatusResolved, params.Action, params.Note, func(ctx context.Context, qtx *database.Queries, report database.Report) (string, error) {
+		switch params.Action {
+		case resolutionRemoveChirp:
+			if !report.TargetChirpID.Valid {
+				return "report is not about a chirp", nil
+			}
+			// the chirp may already be gone, the report still gets resolved
+			chirp, err := qtx.GetOneChirp(ctx, report.TargetChirpID.UUID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			// soft deleted like an author's delete, so it's purged on the same schedule, but kept
+			// out of the author's trash so they can't restore it
+			err = qtx.SoftDeleteChirp(ctx, database.SoftDeleteChirpParams{
+				ID:                 chirp.ID,
+				DeletedAt:          time.Now(),
+				RemovedByModerator: true,
+			})
+			if err != nil {
+				return "", err
+			}
+			if chirp.HeldForReview {
+				return "", nil
+			}
+			if err := enqueueChirpWebhook(ctx, qtx, outbound.EventChirpDeleted, chirp); err != nil {
+				return "", err
+			}
+			return "", recordChirpStreamEvent(ctx, qtx, stream.TypeChirpDeleted, chirp)
+		case resolutionSuspendUser:
+			until := sql.NullTime{}
+			if params.SuspendedUntil != nil {
+				until = sql.NullTime{Time: *params.SuspendedUntil, Valid: true}
+			}
+			return "", suspendUser(ctx, qtx, report.TargetUserID, until, report.Reason)
+		}
+		return "", nil
+	})
+}
+
+func (cfg *apiConfig) handlerDismissReport(w http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		Note string `json:"note"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	cfg.closeReport(w, req, reportStatusDismissed, moderationActionDismiss, params.Note, nil)
+}
+
+// resolutionAffectsAccount reports whether a resolution acts on the reported account rather than a chirp
+func resolutionAffectsAccount(action string) bool {
+	return action == resolutionWarnUser || action == resolutionSuspendUser
+}
+
+// closeReport resolves or dismisses the report in the url, applying the resolution and logging it
+// in one transaction. apply returns a message when the resolution doesn't fit the report.
+func (cfg *apiConfig) closeReport(w http.ResponseWriter, req *http.Request, status, action, note string,
+	apply func(ctx context.Context, qtx *database.Queries, report database.Report) (string, error)) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid report id", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not close report", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	resolution := sql.NullString{}
+	if status == reportStatusResolved {
+		resolution = sql.NullString{String: action, Valid: true}
+	}
+
+	report, err := qtx.CloseReport(req.Context(), database.CloseReportParams{
+		Status:      status,
+		Resolution:  resolution,
+		ModeratorID: moderatorID,
+		ClosedAt:    time.Now(),
+		ID:          reportID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.reportUnavailable(w, req, reportID)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not close report", err)
+		return
+	}
+
+	// resolutions against the account follow the same rank rule as moderating it directly
+	if resolutionAffectsAccount(action) {
+		moderator, err := qtx.GetUserByID(req.Context(), moderatorID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+			return
+		}
+		target, err := qtx.GetUserByID(req.Context(), report.TargetUserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not close report", err)
+			return
+		}
+		if !canModerate(moderator.Role, target.Role) {
+			respondWithError(w, http.StatusForbidden, "you cannot moderate this account", nil)
+			return
+		}
+	}
+
+	if apply != nil {
+		invalid, err := apply(req.Context(), qtx, report)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not apply "+action, err)
+			return
+		}
+		if invalid != "" {
+			respondWithError(w, http.StatusBadRequest, invalid, nil)
+			return
+		}
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ReportID:      uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:        action,
+		TargetUserID:  uuid.NullUUID{UUID: report.TargetUserID, Valid: true},
+		TargetChirpID: report.TargetChirpID,
+		Note:          note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not close report", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not close report", err)
+		return
+	}
+
+	if action == resolutionWarnUser {
+		cfg.events.Publish(req.Context(), events.Event{
+			Kind:    events.KindWarning,
+			UserID:  report.TargetUserID,
+			ChirpID: report.TargetChirpID.UUID,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handlerGetModerationActions(w http.ResponseWriter, req *http.Request) {
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetModerationActions(req.Context(), database.GetModerationActionsParams{
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve moderation log", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	actions := []ModerationAction{}
+	for _, action := range unformatted {
+		actions = append(actions, moderationActionFromDB(action))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, actions)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestCreateReport(t *testing.T) {
+
+	reporter, target := uuid.New(), testUser()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "buy now", UserID: target.ID, Visibility: "public"}
+	own := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "mine", UserID: reporter, Visibility: "public"}
+
+	cases := []struct {
+		name    string
+		body    string
+		saveErr error
+		status  int
+		chirp   bool
+	}{
+		{"chirp", `{"chirp_id":"` + chirp.ID.String() + `","reason":"spam","details":"ads"}`, nil, http.StatusCreated, true},
+		{"user", `{"user_id":"` + target.ID.String() + `","reason":"impersonation"}`, nil, http.StatusCreated, false},
+		{"both", `{"chirp_id":"` + chirp.ID.String() + `","user_id":"` + target.ID.String() + `","reason":"spam"}`, nil, http.StatusBadRequest, false},
+		{"neither", `{"reason":"spam"}`, nil, http.StatusBadRequest, false},
+		{"unknown reason", `{"user_id":"` + target.ID.String() + `","reason":"rude"}`, nil, http.StatusBadRequest, false},
+		{"long details", `{"user_id":"` + target.ID.String() + `","reason":"other","details":"` + strings.Repeat("é", maxReportDetailsLength+1) + `"}`, nil, http.StatusBadRequest, false},
+		{"yourself", `{"chirp_id":"` + own.ID.String() + `","reason":"spam"}`, nil, http.StatusBadRequest, false},
+		{"missing chirp", `{"chirp_id":"` + uuid.NewString() + `","reason":"spam"}`, nil, http.StatusNotFound, false},
+		{"missing user", `{"user_id":"` + uuid.NewString() + `","reason":"spam"}`, nil, http.StatusNotFound, false},
+		{"already reported", `{"user_id":"` + target.ID.String() + `","reason":"spam"}`, &pq.Error{Code: "23505"}, http.StatusConflict, false},
+		{"not saved", `{"user_id":"` + target.ID.String() + `","reason":"spam"}`, errors.New("connection reset"), http.StatusInternalServerError, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("GetUserByID", usersByID(target))
+			fake.answer("GetOneChirp", func(args []driver.Value) fakeResult {
+				for _, known := range []database.Chirp{chirp, own} {
+					if args[0] == known.ID.String() {
+						return fakeResult{rows: [][]driver.Value{row(known)}}
+					}
+				}
+				return fakeResult{}
+			})
+			fake.answer("CreateReport", func(args []driver.Value) fakeResult {
+				if c.saveErr != nil {
+					return fakeResult{err: c.saveErr}
+				}
+				// a new report is open and unclaimed
+				return fakeResult{rows: [][]driver.Value{append(args, reportStatusOpen, nil, nil, nil)}}
+			})
+
+			w := httptest.NewRecorder()
+			cfg.handlerCreateReport(w, authorizedRequest(t, reporter, http.MethodPost, "/api/reports", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			created := fake.calledWith("CreateReport")
+			if c.status != http.StatusCreated {
+				if c.saveErr == nil && len(created) != 0 {
+					t.Errorf("expected no report, got %v", created)
+				}
+				return
+			}
+
+			got := Report{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ReporterID != reporter || got.TargetUserID != target.ID || got.Status != reportStatusOpen {
+				t.Errorf("expected an open report by the reporter against the target, got %+v", got)
+			}
+			if !c.chirp {
+				if got.TargetChirpID != nil || got.ChirpBody != nil {
+					t.Errorf("expected a report about the user only, got %+v", got)
+				}
+				return
+			}
+			// the body is kept so an edit or delete can't hide what was reported
+			if got.TargetChirpID == nil || *got.TargetChirpID != chirp.ID || got.ChirpBody == nil || *got.ChirpBody != chirp.Body {
+				t.Errorf("expected the chirp and its body on the report, got %+v", got)
+			}
+		})
+	}
+}
+
+// reportFrom answers a query that returns a report with one filed against target about
+// chirpID, in the status and resolution fill sets
+func reportFrom(target uuid.UUID, chirpID uuid.NullUUID, fill func(args []driver.Value, report *database.Report)) func(args []driver.Value) fakeResult {
+	return func(args []driver.Value) fakeResult {
+		report := database.Report{
+			ID:            uuid.New(),
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+			ReporterID:    uuid.New(),
+			TargetUserID:  target,
+			TargetChirpID: chirpID,
+			Reason:        "spam",
+			Status:        reportStatusOpen,
+		}
+		fill(args, &report)
+		return fakeResult{rows: [][]driver.Value{row(report)}}
+	}
+}
+
+// closedReport fills a report the way CloseReport leaves it
+func closedReport(args []driver.Value, report *database.Report) {
+	report.ID = uuid.MustParse(args[4].(string))
+	report.Status = args[0].(string)
+	if args[1] != nil {
+		report.Resolution = sql.NullString{String: args[1].(string), Valid: true}
+	}
+	report.ClaimedBy = uuid.NullUUID{UUID: uuid.MustParse(args[2].(string)), Valid: true}
+	report.ClosedAt = sql.NullTime{Time: args[3].(time.Time), Valid: true}
+}
+
+// reportRequest builds a moderator's request against the report in the url
+func reportRequest(t *testing.T, moderatorID, reportID uuid.UUID, action, body string) *http.Request {
+	req := authorizedRequest(t, moderatorID, http.MethodPost, "/admin/reports/"+reportID.String()+"/"+action, body)
+	req.SetPathValue("reportID", reportID.String())
+	return req
+}
+
+func TestClaimReport(t *testing.T) {
+
+	moderator, target := uuid.New(), uuid.New()
+	reportID := uuid.New()
+
+	cases := []struct {
+		name      string
+		claimable bool
+		existing  string
+		status    int
+	}{
+		{"claimed", true, "", http.StatusOK},
+		{"claimed by someone else", false, reportStatusClaimed, http.StatusConflict},
+		{"already resolved", false, reportStatusResolved, http.StatusConflict},
+		{"missing", false, "", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			if c.claimable {
+				fake.answer("ClaimReport", reportFrom(target, uuid.NullUUID{}, func(args []driver.Value, report *database.Report) {
+					report.ID = uuid.MustParse(args[2].(string))
+					report.Status = reportStatusClaimed
+					report.ClaimedBy = uuid.NullUUID{UUID: uuid.MustParse(args[0].(string)), Valid: true}
+				}))
+			}
+			if c.existing != "" {
+				fake.answer("GetReport", reportFrom(target, uuid.NullUUID{}, func(args []driver.Value, report *database.Report) {
+					report.Status = c.existing
+				}))
+			}
+
+			w := httptest.NewRecorder()
+			cfg.handlerClaimReport(w, reportRequest(t, moderator, reportID, "claim", ""))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			logged := fake.calledWith("LogModerationAction")
+			if c.status != http.StatusOK {
+				if c.existing != "" && !strings.Contains(w.Body.String(), c.existing) {
+					t.Errorf("expected the conflict to say the report is %s, got %s", c.existing, w.Body)
+				}
+				if len(logged) != 0 {
+					t.Errorf("expected nothing logged, got %v", logged)
+				}
+				return
+			}
+
+			got := Report{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != reportStatusClaimed || got.ClaimedBy == nil || *got.ClaimedBy != moderator {
+				t.Errorf("expected the report claimed by the moderator, got %+v", got)
+			}
+			if len(logged) != 1 || logged[0][2] != moderator.String() || logged[0][3] != reportID.String() || logged[0][4] != moderationActionClaim {
+				t.Errorf("expected the claim logged, got %v", logged)
+			}
+		})
+	}
+}
+
+func TestResolveReport(t *testing.T) {
+
+	moderator, target := testUser(), testUser()
+	moderator.Role = roleModerator
+	chirpID := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	later := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name    string
+		body    string
+		chirpID uuid.NullUUID
+		status  int
+		query   string
+	}{
+		{"remove the chirp", `{"action":"remove_chirp","note":"spam link"}`, chirpID, http.StatusOK, "SoftDeleteChirp"},
+		{"remove without a chirp", `{"action":"remove_chirp"}`, uuid.NullUUID{}, http.StatusBadRequest, ""},
+		{"suspend", `{"action":"suspend_user","suspended_until":"` + later.Format(time.RFC3339) + `"}`, chirpID, http.StatusOK, "SuspendUser"},
+		{"suspend in the past", `{"action":"suspend_user","suspended_until":"2020-01-01T00:00:00Z"}`, chirpID, http.StatusBadRequest, ""},
+		{"warn", `{"action":"warn_user"}`, chirpID, http.StatusOK, "UpsertNotification"},
+		{"unknown action", `{"action":"ban"}`, chirpID, http.StatusBadRequest, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			reportID := uuid.New()
+			fake.answer("CloseReport", reportFrom(target.ID, c.chirpID, closedReport))
+			fake.answer("GetUserByID", usersByID(moderator, target))
+			fake.answer("SuspendUser", usersByIDAt(3, target))
+			fake.returns("GetOneChirp", row(database.Chirp{
+				ID:         chirpID.UUID,
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+				Body:       "buy now",
+				UserID:     target.ID,
+				Visibility: "public",
+			}))
+
+			w := httptest.NewRecorder()
+			cfg.handlerResolveReport(w, reportRequest(t, moderator.ID, reportID, "resolve", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			logged := fake.calledWith("LogModerationAction")
+			if c.status != http.StatusOK {
+				// the transaction rolls back so nothing is logged when the resolution doesn't fit
+				if len(logged) != 0 {
+					t.Errorf("expected nothing logged, got %v", logged)
+				}
+				for _, query := range []string{"SoftDeleteChirp", "SuspendUser", "UpsertNotification"} {
+					if calls := fake.calledWith(query); len(calls) != 0 {
+						t.Errorf("expected no %s, got %v", query, calls)
+					}
+				}
+				return
+			}
+
+			closed := fake.calledWith("CloseReport")
+			if len(closed) != 1 || closed[0][0] != reportStatusResolved || closed[0][2] != moderator.ID.String() || closed[0][4] != reportID.String() {
+				t.Errorf("expected the report resolved by the moderator, got %v", closed)
+			}
+			if len(logged) != 1 || logged[0][3] != reportID.String() || logged[0][5] != target.ID.String() {
+				t.Errorf("expected the resolution logged against the target, got %v", logged)
+			}
+
+			applied := fake.calledWith(c.query)
+			if len(applied) != 1 {
+				t.Fatalf("expected one %s, got %v", c.query, applied)
+			}
+			switch c.query {
+			case "SoftDeleteChirp":
+				if applied[0][2] != chirpID.UUID.String() || applied[0][1] != true {
+					t.Errorf("expected the reported chirp removed by a moderator, got %v", applied[0])
+				}
+				if calls := fake.calledWith("DeleteChirp"); len(calls) != 0 {
+					t.Errorf("expected the chirp not to be deleted for good, got %v", calls)
+				}
+				if webhooks := fake.calledWith("EnqueueWebhookDeliveries"); len(webhooks) != 1 || !containsValue(webhooks[0], outbound.EventChirpDeleted) {
+					t.Errorf("expected a %s webhook, got %v", outbound.EventChirpDeleted, webhooks)
+				}
+			case "SuspendUser":
+				until, _ := applied[0][1].(time.Time)
+				if !until.Equal(later) || applied[0][2] != "spam" || applied[0][3] != target.ID.String() {
+					t.Errorf("expected the target suspended until %v for the report's reason, got %v", later, applied[0])
+				}
+			case "UpsertNotification":
+				if applied[0][3] != target.ID.String() || applied[0][4] != string(events.KindWarning) {
+					t.Errorf("expected the target warned, got %v", applied[0])
+				}
+				if actors := fake.calledWith("AddNotificationActor"); len(actors) != 0 {
+					t.Errorf("expected the moderator to stay anonymous, got %v", actors)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveReportRankRule(t *testing.T) {
+
+	moderator, admin, user := testUser(), testUser(), testUser()
+	moderator.Role, admin.Role, user.Role = roleModerator, roleAdmin, roleUser
+
+	cases := []struct {
+		name   string
+		target database.User
+		action string
+		status int
+	}{
+		{"suspend a user", user, resolutionSuspendUser, http.StatusOK},
+		{"warn a user", user, resolutionWarnUser, http.StatusOK},
+		{"suspend an admin", admin, resolutionSuspendUser, http.StatusForbidden},
+		{"warn an admin", admin, resolutionWarnUser, http.StatusForbidden},
+		{"suspend themselves", moderator, resolutionSuspendUser, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("GetUserByID", usersByID(moderator, admin, user))
+			fake.answer("CloseReport", reportFrom(c.target.ID, uuid.NullUUID{}, closedReport))
+			fake.answer("SuspendUser", usersByIDAt(3, moderator, admin, user))
+
+			w := httptest.NewRecorder()
+			cfg.handlerResolveReport(w, reportRequest(t, moderator.ID, uuid.New(), "resolve", `{"action":"`+c.action+`"}`))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			suspended := len(fake.calledWith("SuspendUser")) > 0
+			if suspended != (c.status == http.StatusOK && c.action == resolutionSuspendUser) {
+				t.Errorf("expected the suspension applied only when allowed, got %v", suspended)
+			}
+		})
+	}
+}
+
+func TestDismissReport(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	moderator, target := uuid.New(), uuid.New()
+	reportID := uuid.New()
+	fake.answer("CloseReport", reportFrom(target, uuid.NullUUID{}, closedReport))
+
+	w := httptest.NewRecorder()
+	cfg.handlerDismissReport(w, reportRequest(t, moderator, reportID, "dismiss", `{"note":"not spam"}`))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", w.Code, w.Body)
+	}
+	got := Report{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != reportStatusDismissed || got.Resolution != nil {
+		t.Errorf("expected a dismissed report with no resolution, got %+v", got)
+	}
+
+	logged := fake.calledWith("LogModerationAction")
+	if len(logged) != 1 || logged[0][4] != moderationActionDismiss || logged[0][7] != "not spam" {
+		t.Errorf("expected the dismissal logged with its note, got %v", logged)
+	}
+}
+
+// containsValue reports whether a query was called with want as one of its arguments
+func containsValue(args []driver.Value, want string) bool {
+	for _, arg := range args {
+		if arg == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRank orders roles so a higher one can do everything a lower one can
+var roleRank = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+// canModerate reports whether someone with moderatorRole may act on an account with targetRole,
+// only accounts below their own role
+func canModerate(moderatorRole, targetRole string) bool {
+	return roleRank[targetRole] < roleRank[moderatorRole]
+}
+
+// middlewareRequireRole only lets through requests from users with at least the given role
+func (cfg *apiConfig) middlewareRequireRole(role string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		userID, err := cfg.authenticatedUserID(req)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+			return
+		}
+
+		user, err := cfg.db.GetUserByID(req.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+			return
+		}
+
+		if roleRank[user.Role] < roleRank[role] {
+			respondWithError(w, http.StatusForbidden, "invalid permissions for endpoint", nil)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
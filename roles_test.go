@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestCanModerate(t *testing.T) {
+
+	cases := []struct {
+		moderator string
+		target    string
+		want      bool
+	}{
+		{roleModerator, roleUser, true},
+		{roleAdmin, roleUser, true},
+		{roleAdmin, roleModerator, true},
+		{roleModerator, roleModerator, false},
+		{roleModerator, roleAdmin, false},
+		{roleAdmin, roleAdmin, false},
+		{roleUser, roleUser, false},
+	}
+
+	for _, c := range cases {
+		if got := canModerate(c.moderator, c.target); got != c.want {
+			t.Errorf("%s acting on %s: expected %v, got %v", c.moderator, c.target, c.want, got)
+		}
+	}
+}
+
+func TestResolutionAffectsAccount(t *testing.T) {
+
+	for _, action := range []string{resolutionWarnUser, resolutionSuspendUser} {
+		if !resolutionAffectsAccount(action) {
+			t.Errorf("expected %s to be held to the rank rule", action)
+		}
+	}
+	for _, action := range []string{resolutionRemoveChirp, moderationActionDismiss} {
+		if resolutionAffectsAccount(action) {
+			t.Errorf("expected %s to be allowed against any account", action)
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/search"
+	"github.com/colfarl/chirpy-server/internal/spam"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledStatusDraft     = "draft"
+	scheduledStatusScheduled = "scheduled"
+
+	schedulerInterval = 15 * time.Second
+	// caps how many chirps one tick publishes so a backlog can't starve other work
+	schedulerBatchSize = 100
+)
+
+var errReplyParentGone = errors.New("chirp being replied to does not exist")
+
+// errScheduledChirpFailed is what the author sees when publishing failed for a reason of ours
+var errScheduledChirpFailed = errors.New("chirp could not be published")
+
+type ScheduledChirp struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	ReplyToID  *uuid.UUID `json:"reply_to_id,omitempty"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility string     `json:"visibility"`
+	Status     string     `json:"status"`
+	ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+func scheduledChirpFromDB(scheduled database.ScheduledChirp) ScheduledChirp {
+	formatted := ScheduledChirp{
+		ID:         scheduled.ID,
+		CreatedAt:  scheduled.CreatedAt,
+		UpdatedAt:  scheduled.UpdatedAt,
+		Body:       scheduled.Body,
+		Visibility: scheduled.Visibility,
+		Status:     scheduled.Status,
+		LastError:  scheduled.LastError.String,
+	}
+	if scheduled.ReplyToID.Valid {
+		formatted.ReplyToID = &scheduled.ReplyToID.UUID
+	}
+	if scheduled.PublishAt.Valid {
+		formatted.PublishAt = &scheduled.PublishAt.Time
+	}
+	if scheduled.ChirpID.Valid {
+		formatted.ChirpID = &scheduled.ChirpID.UUID
+	}
+	return formatted
+}
+
+type scheduledChirpParameters struct {
+	Body       string     `json:"body"`
+	ReplyToID  *uuid.UUID `json:"reply_to_id"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility string     `json:"visibility"`
+}
+
+// validScheduledChirp is scheduledChirpParameters once checked and ready to store
+type validScheduledChirp struct {
+	replyTo    uuid.NullUUID
+	publishAt  sql.NullTime
+	status     string
+	visibility visibility.Level
+}
+
+// validateScheduledChirp runs the same checks a chirp gets at publish time, so a user finds out
+// about a bad draft now rather than when the scheduler picks it up.
+// A missing publish_at makes it a draft.
+func (cfg *apiConfig) validateScheduledChirp(ctx context.Context, userID uuid.UUID, params scheduledChirpParameters) (validScheduledChirp, error) {
+
+	valid := validScheduledChirp{
+		status: scheduledStatusDraft,
+	}
+
+	ent, err := cfg.entitlements.For(ctx, userID)
+	if err != nil {
+		return valid, err
+	}
+	policy := chirpPolicy(ent)
+
+	if _, err := cfg.validateChirpBody(params.Body, policy); err != nil {
+		return valid, err
+	}
+
+	level, err := visibility.ParseLevel(params.Visibility)
+	if err != nil {
+		return valid, err
+	}
+	valid.visibility = level
+
+	if params.ReplyToID != nil {
+		parent, err := cfg.db.GetOneChirp(ctx, *params.ReplyToID)
+		if err != nil {
+			return valid, errReplyParentGone
+		}
+		if canView, err := cfg.canViewChirp(ctx, userID, parent); err != nil || !canView {
+			return valid, errReplyParentGone
+		}
+		valid.replyTo = uuid.NullUUID{
+			UUID:  parent.ID,
+			Valid: true,
+		}
+	}
+
+	valid.publishAt, err = scheduledPublishAt(params.PublishAt, time.Now())
+	if err != nil {
+		return valid, err
+	}
+	if valid.publishAt.Valid {
+		valid.status = scheduledStatusScheduled
+	}
+	return valid, nil
+}
+
+// scheduledPublishAt checks publish_at and puts it in the server's zone, like the times it is
+// compared with in the column. A nil publish_at is a draft.
+func scheduledPublishAt(publishAt *time.Time, now time.Time) (sql.NullTime, error) {
+	if publishAt == nil {
+		return sql.NullTime{}, nil
+	}
+	if !publishAt.After(now) {
+		return sql.NullTime{}, errors.New("publish_at must be in the future")
+	}
+	return sql.NullTime{
+		Time:  dbTime(*publishAt),
+		Valid: true,
+	}, nil
+}
+
+func (cfg *apiConfig) handlerCreateScheduledChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := scheduledChirpParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	valid, err := cfg.validateScheduledChirp(req.Context(), userID, params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	scheduled, err := cfg.db.CreateScheduledChirp(req.Context(), database.CreateScheduledChirpParams{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     userID,
+		Body:       params.Body,
+		ReplyToID:  valid.replyTo,
+		PublishAt:  valid.publishAt,
+		Status:     valid.status,
+		Visibility: string(valid.visibility),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not save chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, scheduledChirpFromDB(scheduled))
+}
+
+func (cfg *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	status := req.URL.Query().Get("status")
+	unformatted, err := cfg.db.GetScheduledChirpsByUser(req.Context(), database.GetScheduledChirpsByUserParams{
+		UserID: userID,
+		Status: sql.NullString{
+			String: status,
+			Valid:  status != "",
+		},
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve scheduled chirps", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	scheduledChirps := []ScheduledChirp{}
+	for _, scheduled := range unformatted {
+		scheduledChirps = append(scheduledChirps, scheduledChirpFromDB(scheduled))
+	}
+
+	respondWithJSON(w, http.StatusOK, scheduledChirps)
+}
+
+func (cfg *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	scheduledID, err := uuid.Parse(req.PathValue("scheduledID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract scheduled chirp id from url", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := scheduledChirpParameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	valid, err := cfg.validateScheduledChirp(req.Context(), userID, params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	updated, err := cfg.db.UpdatePendingScheduledChirp(req.Context(), database.UpdatePendingScheduledChirpParams{
+		Body:       params.Body,
+		ReplyToID:  valid.replyTo,
+		PublishAt:  valid.publishAt,
+		Status:     valid.status,
+		Visibility: string(valid.visibility),
+		UpdatedAt:  time.Now(),
+		ID:         scheduledID,
+		UserID:     userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.respondScheduledChirpNotPending(w, req, userID, scheduledID)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update scheduled chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, scheduledChirpFromDB(updated))
+}
+
+func (cfg *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	scheduledID, err := uuid.Parse(req.PathValue("scheduledID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract scheduled chirp id from url", err)
+		return
+	}
+
+	cancelled, err := cfg.db.CancelPendingScheduledChirp(req.Context(), database.CancelPendingScheduledChirpParams{
+		ID:        scheduledID,
+		UserID:    userID,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not cancel scheduled chirp", err)
+		return
+	}
+
+	if cancelled == 0 {
+		cfg.respondScheduledChirpNotPending(w, req, userID, scheduledID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondScheduledChirpNotPending tells apart a chirp that doesn't exist from one
+// that can no longer change because it was published, cancelled or failed
+func (cfg *apiConfig) respondScheduledChirpNotPending(w http.ResponseWriter, req *http.Request, userID, scheduledID uuid.UUID) {
+
+	scheduled, err := cfg.db.GetScheduledChirpForUser(req.Context(), database.GetScheduledChirpForUserParams{
+		ID:     scheduledID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "scheduled chirp does not exist", err)
+		return
+	}
+
+	respondWithError(w, http.StatusConflict, "scheduled chirp is already "+scheduled.Status, nil)
+}
+
+// publishDueChirps is the publish_scheduled_chirps job, it publishes up to schedulerBatchSize due chirps
+func (cfg *apiConfig) publishDueChirps(ctx context.Context, _ struct{}) error {
+	for range schedulerBatchSize {
+		published, err := cfg.publishNextDueChirp(ctx)
+		if err != nil {
+			return fmt.Errorf("scheduler could not publish chirp: %w", err)
+		}
+		if !published {
+			return nil
+		}
+	}
+	return nil
+}
+
+// publishNextDueChirp claims one due chirp with FOR UPDATE SKIP LOCKED and publishes it
+// in the same transaction, so several server instances can run the scheduler without
+// ever posting the same chirp twice. Reports false when nothing was due.
+// A chirp that errors is marked failed rather than left at the head of the queue.
+func (cfg *apiConfig) publishNextDueChirp(ctx context.Context) (bool, error) {
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	due, err := qtx.ClaimDueScheduledChirp(ctx, sql.NullTime{Time: now, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = cfg.publishScheduledChirp(ctx, tx, qtx, due, now)
+	if err == nil {
+		return true, nil
+	}
+	log.Println("could not publish scheduled chirp ", due.ID, ": ", err)
+	tx.Rollback()
+
+	// outside the rolled back transaction, the query skips the row if another
+	// scheduler claimed and published it in the meantime
+	err = cfg.db.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+		LastError: errScheduledChirpFailed.Error(),
+		UpdatedAt: now,
+		ID:        due.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// publishScheduledChirp rechecks a claimed chirp and posts it, committing tx.
+// A chirp that no longer passes the checks is committed as failed instead.
+func (cfg *apiConfig) publishScheduledChirp(ctx context.Context, tx *sql.Tx, qtx *database.Queries, due database.ScheduledChirp, now time.Time) error {
+
+	ent, err := cfg.entitlements.For(ctx, due.UserID)
+	if err != nil {
+		return err
+	}
+	policy := chirpPolicy(ent)
+
+	// the author may have been suspended or posted their hourly allowance since scheduling it
+	err = checkAuthorCanPost(ctx, qtx, due.UserID, ent, now)
+	suspended := &suspendedError{}
+	if errors.Is(err, errChirpRateLimited) || errors.As(err, &suspended) {
+		return failScheduledChirp(ctx, tx, qtx, due.ID, err, now)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the parent may have been deleted, or hidden from the author, since it was scheduled
+	if due.ReplyToID.Valid {
+		parent, err := qtx.GetOneChirp(ctx, due.ReplyToID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return failScheduledChirp(ctx, tx, qtx, due.ID, errReplyParentGone, now)
+		}
+		if err != nil {
+			return err
+		}
+		canView, err := cfg.canViewChirp(ctx, due.UserID, parent)
+		if err != nil {
+			return err
+		}
+		if !canView {
+			return failScheduledChirp(ctx, tx, qtx, due.ID, errReplyParentGone, now)
+		}
+	}
+
+	// rules, or the author's tier, may have changed since it was scheduled
+	moderated, err := cfg.validateChirpBody(due.Body, policy)
+	if err != nil {
+		return failScheduledChirp(ctx, tx, qtx, due.ID, err, now)
+	}
+
+	signals, decision, err := cfg.screenChirp(ctx, qtx, due.UserID, moderated.Text)
+	if err != nil {
+		return err
+	}
+	if decision.Verdict == spam.VerdictReject {
+		if err := recordSpamDecision(ctx, qtx, due.UserID, uuid.NullUUID{}, signals, decision); err != nil {
+			return err
+		}
+		return failScheduledChirp(ctx, tx, qtx, due.ID, errChirpSpam, now)
+	}
+
+	chirp, err := qtx.CreateChirp(ctx, database.CreateChirpParams{
+		ID:            uuid.New(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Body:          moderated.Text,
+		UserID:        due.UserID,
+		ReplyToID:     due.ReplyToID,
+		Visibility:    due.Visibility,
+		Language:      search.DefaultLanguage,
+		MediaUrls:     []string{},
+		HeldForReview: decision.Verdict == spam.VerdictHold,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := flagChirp(ctx, qtx, chirp.ID, moderated); err != nil {
+		return err
+	}
+
+	err = recordSpamDecision(ctx, qtx, due.UserID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, signals, decision)
+	if err != nil {
+		return err
+	}
+
+	if !chirp.HeldForReview {
+		if err := enqueueChirpWebhook(ctx, qtx, outbound.EventChirpCreated, chirp); err != nil {
+			return err
+		}
+		if err := recordChirpStreamEvent(ctx, qtx, stream.TypeChirpCreated, chirp); err != nil {
+			return err
+		}
+	}
+
+	err = qtx.MarkScheduledChirpPublished(ctx, database.MarkScheduledChirpPublishedParams{
+		ChirpID:   chirp.ID,
+		UpdatedAt: now,
+		ID:        due.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if !chirp.HeldForReview {
+		cfg.publishChirpEvents(ctx, chirp)
+	}
+	return nil
+}
+
+// failScheduledChirp records why a due chirp couldn't be published and commits, it isn't retried
+func failScheduledChirp(ctx context.Context, tx *sql.Tx, qtx *database.Queries, id uuid.UUID, reason error, now time.Time) error {
+	err := qtx.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+		LastError: reason.Error(),
+		UpdatedAt: now,
+		ID:        id,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+func TestCreateScheduledChirp(t *testing.T) {
+
+	user := testUser()
+	parent := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "parent", UserID: uuid.New(), Visibility: "public"}
+	later := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	earlier := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"draft", `{"body":"hello"}`, http.StatusCreated, scheduledStatusDraft},
+		{"scheduled", `{"body":"hello","publish_at":"` + later + `"}`, http.StatusCreated, scheduledStatusScheduled},
+		{"reply", `{"body":"hello","reply_to_id":"` + parent.ID.String() + `"}`, http.StatusCreated, scheduledStatusDraft},
+		{"in the past", `{"body":"hello","publish_at":"` + earlier + `"}`, http.StatusBadRequest, ""},
+		{"too long", `{"body":"` + strings.Repeat("a", 141) + `"}`, http.StatusBadRequest, ""},
+		{"missing parent", `{"body":"hello","reply_to_id":"` + uuid.NewString() + `"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("GetUserByID", usersByID(user))
+			fake.answer("GetOneChirp", func(args []driver.Value) fakeResult {
+				if args[0] == parent.ID.String() {
+					return fakeResult{rows: [][]driver.Value{row(parent)}}
+				}
+				return fakeResult{}
+			})
+			fake.answer("CreateScheduledChirp", func(args []driver.Value) fakeResult {
+				// chirp_id and last_error are only set once it publishes
+				saved := append(append(args[:8:8], nil, nil), args[8:]...)
+				return fakeResult{rows: [][]driver.Value{saved}}
+			})
+
+			w := httptest.NewRecorder()
+			cfg.handlerCreateScheduledChirp(w, authorizedRequest(t, user.ID, http.MethodPost, "/api/scheduled_chirps", c.body))
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			calls := fake.calledWith("CreateScheduledChirp")
+			if c.status != http.StatusCreated {
+				if len(calls) != 0 {
+					t.Errorf("expected nothing saved, got %v", calls)
+				}
+				return
+			}
+
+			got := ScheduledChirp{}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != c.want || got.Body != "hello" {
+				t.Errorf("expected a %s chirp, got %+v", c.want, got)
+			}
+			if len(calls) != 1 || calls[0][3] != user.ID.String() {
+				t.Errorf("expected the chirp saved for the user, got %v", calls)
+			}
+		})
+	}
+}
+
+func TestCancelScheduledChirp(t *testing.T) {
+
+	user := uuid.New()
+	scheduledID := uuid.New()
+	published := database.ScheduledChirp{
+		ID:        scheduledID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID:    user,
+		Body:      "hello",
+		Status:    "published",
+	}
+
+	cases := []struct {
+		name      string
+		cancelled bool
+		existing  []driver.Value
+		status    int
+	}{
+		{"cancelled", true, nil, http.StatusNoContent},
+		{"already published", false, row(published), http.StatusConflict},
+		{"missing", false, nil, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			if c.cancelled {
+				fake.returns("CancelPendingScheduledChirp", row(true))
+			}
+			if c.existing != nil {
+				fake.returns("GetScheduledChirpForUser", c.existing)
+			}
+
+			req := authorizedRequest(t, user, http.MethodDelete, "/api/scheduled_chirps/"+scheduledID.String(), "")
+			req.SetPathValue("scheduledID", scheduledID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerCancelScheduledChirp(w, req)
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			if c.status == http.StatusConflict && !strings.Contains(w.Body.String(), "published") {
+				t.Errorf("expected the conflict to name the status, got %s", w.Body)
+			}
+			calls := fake.calledWith("CancelPendingScheduledChirp")
+			if len(calls) != 1 || calls[0][0] != scheduledID.String() || calls[0][1] != user.String() {
+				t.Errorf("expected the user's chirp to be cancelled, got %v", calls)
+			}
+		})
+	}
+}
+
+func TestPublishNextDueChirp(t *testing.T) {
+
+	author := testUser()
+	due := database.ScheduledChirp{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     author.ID,
+		Body:       "hello",
+		PublishAt:  sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		Status:     scheduledStatusScheduled,
+		Visibility: "public",
+	}
+
+	t.Run("nothing due", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+
+		published, err := cfg.publishNextDueChirp(context.Background())
+		if err != nil || published {
+			t.Fatalf("expected nothing published, got %v %v", published, err)
+		}
+		if calls := fake.calledWith("CreateChirp"); len(calls) != 0 {
+			t.Errorf("expected no chirp, got %v", calls)
+		}
+	})
+
+	t.Run("published", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("ClaimDueScheduledChirp", row(due))
+		fake.answer("GetUserByID", usersByID(author))
+		fake.returns("CountChirpsByAuthorSince", row(0))
+		fake.answer("CreateChirp", createdChirp)
+
+		published, err := cfg.publishNextDueChirp(context.Background())
+		if err != nil || !published {
+			t.Fatalf("expected the chirp published, got %v %v", published, err)
+		}
+
+		chirps := fake.calledWith("CreateChirp")
+		if len(chirps) != 1 || chirps[0][3] != "hello" || chirps[0][4] != author.ID.String() {
+			t.Fatalf("expected the chirp posted for its author, got %v", chirps)
+		}
+		marked := fake.calledWith("MarkScheduledChirpPublished")
+		if len(marked) != 1 || marked[0][0] != chirps[0][0] || marked[0][2] != due.ID.String() {
+			t.Errorf("expected the scheduled chirp to point at the new chirp, got %v", marked)
+		}
+	})
+
+	t.Run("no longer valid", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		tooLong := due
+		tooLong.Body = strings.Repeat("a", 141)
+		fake.returns("ClaimDueScheduledChirp", row(tooLong))
+		fake.answer("GetUserByID", usersByID(author))
+		fake.returns("CountChirpsByAuthorSince", row(0))
+
+		published, err := cfg.publishNextDueChirp(context.Background())
+		if err != nil || !published {
+			t.Fatalf("expected the chirp handled, got %v %v", published, err)
+		}
+		if calls := fake.calledWith("CreateChirp"); len(calls) != 0 {
+			t.Errorf("expected no chirp, got %v", calls)
+		}
+		failed := fake.calledWith("MarkScheduledChirpFailed")
+		if len(failed) != 1 || !strings.HasPrefix(failed[0][0].(string), "chirp is too long") || failed[0][2] != due.ID.String() {
+			t.Errorf("expected the scheduled chirp marked failed, got %v", failed)
+		}
+	})
+
+	parent := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "first", UserID: uuid.New(), Visibility: "public"}
+	hidden := parent
+	hidden.Visibility = "followers"
+	reply := due
+	reply.ReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+
+	parents := []struct {
+		name   string
+		parent []database.Chirp
+	}{
+		{name: "reply parent deleted"},
+		{name: "reply parent hidden from the author", parent: []database.Chirp{hidden}},
+	}
+	for _, c := range parents {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.returns("ClaimDueScheduledChirp", row(reply))
+			fake.answer("GetUserByID", usersByID(author))
+			fake.returns("CountChirpsByAuthorSince", row(0))
+			if len(c.parent) > 0 {
+				fake.returns("GetOneChirp", row(c.parent[0]))
+			}
+			fake.answer("CreateChirp", createdChirp)
+
+			published, err := cfg.publishNextDueChirp(context.Background())
+			if err != nil || !published {
+				t.Fatalf("expected the chirp handled, got %v %v", published, err)
+			}
+			if calls := fake.calledWith("CreateChirp"); len(calls) != 0 {
+				t.Errorf("expected no chirp, got %v", calls)
+			}
+			failed := fake.calledWith("MarkScheduledChirpFailed")
+			if len(failed) != 1 || failed[0][0] != errReplyParentGone.Error() || failed[0][2] != due.ID.String() {
+				t.Errorf("expected the scheduled chirp marked failed, got %v", failed)
+			}
+		})
+	}
+
+	t.Run("reply parent still visible", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("ClaimDueScheduledChirp", row(reply))
+		fake.answer("GetUserByID", usersByID(author))
+		fake.returns("CountChirpsByAuthorSince", row(0))
+		fake.returns("GetOneChirp", row(parent))
+		fake.answer("CreateChirp", createdChirp)
+
+		published, err := cfg.publishNextDueChirp(context.Background())
+		if err != nil || !published {
+			t.Fatalf("expected the chirp published, got %v %v", published, err)
+		}
+		chirps := fake.calledWith("CreateChirp")
+		if len(chirps) != 1 || chirps[0][5] != parent.ID.String() {
+			t.Errorf("expected the reply posted under its parent, got %v", chirps)
+		}
+	})
+
+	t.Run("unexpected error", func(t *testing.T) {
+		fake, cfg := newTestConfig(t)
+		fake.returns("ClaimDueScheduledChirp", row(due))
+		fake.answer("GetUserByID", usersByID(author))
+		fake.returns("CountChirpsByAuthorSince", row(0))
+		fake.answer("CreateChirp", func(args []driver.Value) fakeResult {
+			return fakeResult{err: errors.New("connection reset")}
+		})
+
+		published, err := cfg.publishNextDueChirp(context.Background())
+		if err != nil || !published {
+			t.Fatalf("expected the queue to move on, got %v %v", published, err)
+		}
+		failed := fake.calledWith("MarkScheduledChirpFailed")
+		if len(failed) != 1 || failed[0][0] != errScheduledChirpFailed.Error() || failed[0][2] != due.ID.String() {
+			t.Errorf("expected the scheduled chirp marked failed without the internal error, got %v", failed)
+		}
+		if marked := fake.calledWith("MarkScheduledChirpPublished"); len(marked) != 0 {
+			t.Errorf("expected nothing marked published, got %v", marked)
+		}
+	})
+}
+
+func TestAuthorCanPost(t *testing.T) {
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ent := entitlements.Entitlements{ChirpsPerHour: 60}
+
+	suspended := func(until sql.NullTime) database.GetUserSuspensionRow {
+		return database.GetUserSuspensionRow{
+			SuspendedAt:    sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+			SuspendedUntil: until,
+		}
+	}
+
+	cases := []struct {
+		name       string
+		suspension database.GetUserSuspensionRow
+		posted     int64
+		suspended  bool
+		limited    bool
+	}{
+		{name: "allowed", posted: 59},
+		{name: "at the hourly limit", posted: 60, limited: true},
+		{name: "suspended permanently", suspension: suspended(sql.NullTime{}), suspended: true},
+		{name: "suspended until later", suspension: suspended(sql.NullTime{Time: now.Add(time.Hour), Valid: true}), suspended: true},
+		{name: "suspension ran out", suspension: suspended(sql.NullTime{Time: now, Valid: true}), posted: 3},
+		{name: "suspension comes before the limit", suspension: suspended(sql.NullTime{}), posted: 60, suspended: true},
+	}
+
+	for _, c := range cases {
+		err := authorCanPost(c.suspension, c.posted, ent, now)
+		var suspendedErr *suspendedError
+		if got := errors.As(err, &suspendedErr); got != c.suspended {
+			t.Errorf("%s: expected suspended %v, got %v", c.name, c.suspended, err)
+		}
+		if got := errors.Is(err, errChirpRateLimited); got != c.limited {
+			t.Errorf("%s: expected rate limited %v, got %v", c.name, c.limited, err)
+		}
+	}
+}
+
+// fixedEntitlements gives every user the same entitlements
+type fixedEntitlements entitlements.Entitlements
+
+func (f fixedEntitlements) For(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	return entitlements.Entitlements(f), nil
+}
+
+func TestPublishNextDueChirpChecksAuthor(t *testing.T) {
+
+	due := database.ScheduledChirp{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		UserID:     uuid.New(),
+		Body:       "hello later",
+		PublishAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		Status:     scheduledStatusScheduled,
+		Visibility: "public",
+	}
+
+	cases := []struct {
+		name       string
+		suspension database.GetUserSuspensionRow
+		posted     int64
+		want       string
+	}{
+		{name: "suspended", suspension: database.GetUserSuspensionRow{
+			SuspendedAt:      sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true},
+			SuspensionReason: sql.NullString{String: "spam", Valid: true},
+		}, want: "account suspended permanently for spam"},
+		{name: "over the hourly limit", posted: 60, want: errChirpRateLimited.Error()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			cfg.entitlements = fixedEntitlements{ChirpsPerHour: 60}
+			fake.returns("ClaimDueScheduledChirp", row(due))
+			fake.answer("GetUserSuspension", suspensionsOf(map[uuid.UUID]database.GetUserSuspensionRow{due.UserID: c.suspension}))
+			fake.returns("CountChirpsByAuthorSince", row(c.posted))
+
+			published, err := cfg.publishNextDueChirp(context.Background())
+			if err != nil || !published {
+				t.Fatalf("expected the chirp handled, got %v %v", published, err)
+			}
+			if created := fake.calledWith("CreateChirp"); len(created) != 0 {
+				t.Errorf("expected nothing published, got %v", created)
+			}
+			failed := fake.calledWith("MarkScheduledChirpFailed")
+			if len(failed) != 1 || failed[0][0] != c.want || failed[0][2] != due.ID.String() {
+				t.Errorf("expected the scheduled chirp failed with %q, got %v", c.want, failed)
+			}
+		})
+	}
+}
+
+func TestScheduledPublishAt(t *testing.T) {
+
+	now := time.Now()
+
+	draft, err := scheduledPublishAt(nil, now)
+	if err != nil || draft.Valid {
+		t.Errorf("expected no publish_at to be a draft, got %v %v", draft, err)
+	}
+
+	for _, past := range []time.Time{now, now.Add(-time.Minute)} {
+		if _, err := scheduledPublishAt(&past, now); err == nil {
+			t.Errorf("expected %v to be rejected as not in the future", past)
+		}
+	}
+
+	// a client in another zone means the same instant, stored as the server's wall clock
+	zone := time.FixedZone("UTC+9", 9*60*60)
+	publishAt := now.Add(time.Hour).In(zone)
+	got, err := scheduledPublishAt(&publishAt, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Valid || !got.Time.Equal(publishAt) || got.Time.Location() != time.Local {
+		t.Errorf("expected %v in the server's zone, got %v", publishAt, got.Time)
+	}
+}
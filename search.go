@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/search"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+)
+
+type ChirpSearchResult struct {
+	Chirp
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type UserSearchResult struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Rank        float32   `json:"rank"`
+}
+
+// handlerSearch ranks chirps and accounts against q, see search.Parse for the query syntax.
+// lang picks the stemming used for q, snippets wrap matches in <mark></mark>.
+func (cfg *apiConfig) handlerSearch(w http.ResponseWriter, req *http.Request) {
+
+	query, err := search.Parse(req.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	language, err := search.ParseLanguage(req.URL.Query().Get("lang"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	pageSize, err := parsePageSize(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirpResults := []ChirpSearchResult{}
+	userResults := []UserSearchResult{}
+
+	searchParams := database.SearchChirpsParams{
+		Language: language,
+		Query:    query.TSQuery,
+		Since: sql.NullTime{
+			Time:  dbTime(query.Since),
+			Valid: !query.Since.IsZero(),
+		},
+		PageSize: pageSize,
+	}
+
+	if query.FromHandle != "" {
+		authors, err := cfg.db.GetUsersByHandles(req.Context(), []string{query.FromHandle})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not search chirps", err)
+			return
+		}
+		if len(authors) == 0 {
+			respondWithSearchResults(w, chirpResults, userResults)
+			return
+		}
+		searchParams.AuthorID = uuid.NullUUID{
+			UUID:  authors[0].ID,
+			Valid: true,
+		}
+	}
+
+	rows, err := cfg.db.SearchChirps(req.Context(), searchParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not search chirps", err)
+		return
+	}
+
+	matches := []database.Chirp{}
+	matchesByID := map[uuid.UUID]database.SearchChirpsRow{}
+	for _, row := range rows {
+		matches = append(matches, row.Chirp)
+		matchesByID[row.Chirp.ID] = row
+	}
+
+	viewerID := cfg.optionalUserID(req)
+	visible, err := cfg.filterVisibleChirps(req.Context(), viewerID, matches, visibility.SurfacePublicFeed)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not search chirps", err)
+		return
+	}
+
+	chirps := []Chirp{}
+	for _, chirp := range visible {
+		chirps = append(chirps, chirpFromDB(chirp))
+	}
+
+	err = cfg.attachPolls(req.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve polls", err)
+		return
+	}
+
+	for _, chirp := range chirps {
+		match := matchesByID[chirp.ID]
+		chirpResults = append(chirpResults, ChirpSearchResult{
+			Chirp:   chirp,
+			Rank:    match.Rank,
+			Snippet: snippetHTML(match.Snippet),
+		})
+	}
+
+	// from: and since: only make sense for chirps
+	if query.TSQuery != "" && query.FromHandle == "" && query.Since.IsZero() {
+		users, err := cfg.db.SearchUsers(req.Context(), database.SearchUsersParams{
+			Query:    query.TSQuery,
+			PageSize: pageSize,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "could not search users", err)
+			return
+		}
+		for _, user := range users {
+			userResults = append(userResults, UserSearchResult{
+				ID:          user.ID,
+				Handle:      user.Handle.String,
+				DisplayName: user.DisplayName.String,
+				Rank:        user.Rank,
+			})
+		}
+	}
+
+	respondWithSearchResults(w, chirpResults, userResults)
+}
+
+// markers SearchChirps puts around matches in a snippet
+const (
+	snippetStartSel = "\uE000"
+	snippetStopSel  = "\uE001"
+)
+
+// snippetHTML escapes a headline from SearchChirps so the chirp text can't carry markup,
+// only the match markers become <mark> tags
+func snippetHTML(headline string) string {
+
+	snippet := strings.Builder{}
+	open := false
+	for {
+		i := strings.IndexAny(headline, snippetStartSel+snippetStopSel)
+		if i < 0 {
+			break
+		}
+		snippet.WriteString(html.EscapeString(headline[:i]))
+		marker := headline[i : i+len(snippetStartSel)]
+		headline = headline[i+len(marker):]
+
+		if marker == snippetStartSel && !open {
+			snippet.WriteString("<mark>")
+			open = true
+		} else if marker == snippetStopSel && open {
+			snippet.WriteString("</mark>")
+			open = false
+		}
+	}
+	snippet.WriteString(html.EscapeString(headline))
+	if open {
+		snippet.WriteString("</mark>")
+	}
+	return snippet.String()
+}
+
+func respondWithSearchResults(w http.ResponseWriter, chirps []ChirpSearchResult, users []UserSearchResult) {
+	respondWithJSON(w, http.StatusOK, struct {
+		Chirps []ChirpSearchResult `json:"chirps"`
+		Users  []UserSearchResult  `json:"users"`
+	}{
+		Chirps: chirps,
+		Users:  users,
+	})
+}
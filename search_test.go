@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSnippetHTML(t *testing.T) {
+
+	mark := func(s string) string {
+		return snippetStartSel + s + snippetStopSel
+	}
+
+	cases := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{"plain", "just some words", "just some words"},
+		{"match", "the " + mark("quick") + " fox", "the <mark>quick</mark> fox"},
+		{"script", "<script>alert(1)</script> " + mark("fox"), "&lt;script&gt;alert(1)&lt;/script&gt; <mark>fox</mark>"},
+		{"attribute", `<img src=x onerror="alert(1)"> ` + mark("fox"), "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>fox</mark>"},
+		{"literal mark tags", "<mark>not a match</mark>", "&lt;mark&gt;not a match&lt;/mark&gt;"},
+		{"match inside markup", "<b>" + mark("fox") + "</b>", "&lt;b&gt;<mark>fox</mark>&lt;/b&gt;"},
+		{"unbalanced markers", snippetStopSel + mark("a") + snippetStartSel + "b", "<mark>a</mark><mark>b</mark>"},
+	}
+
+	for _, c := range cases {
+		if got := snippetHTML(c.headline); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaults for the HTTP server, each can be overridden from the environment
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	// streams set their own deadline per write, so this only bounds ordinary responses
+	defaultWriteTimeout   = 30 * time.Second
+	defaultIdleTimeout    = 2 * time.Minute
+	defaultMaxHeaderBytes = 64 << 10
+	// how long a shutdown waits on in-flight requests and running jobs
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+type serverConfig struct {
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	shutdownTimeout   time.Duration
+}
+
+// serverConfigFromEnv reads HTTP_READ_HEADER_TIMEOUT, HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT,
+// HTTP_IDLE_TIMEOUT and SHUTDOWN_TIMEOUT as durations like "15s", and HTTP_MAX_HEADER_BYTES
+func serverConfigFromEnv() serverConfig {
+
+	duration := func(name string, fallback time.Duration) time.Duration {
+		raw := os.Getenv(name)
+		if raw == "" {
+			return fallback
+		}
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			log.Println("ignoring invalid ", name, ": ", raw)
+			return fallback
+		}
+		return value
+	}
+
+	maxHeaderBytes := defaultMaxHeaderBytes
+	if raw := os.Getenv("HTTP_MAX_HEADER_BYTES"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			log.Println("ignoring invalid HTTP_MAX_HEADER_BYTES: ", raw)
+		} else {
+			maxHeaderBytes = value
+		}
+	}
+
+	return serverConfig{
+		readHeaderTimeout: duration("HTTP_READ_HEADER_TIMEOUT", defaultReadHeaderTimeout),
+		readTimeout:       duration("HTTP_READ_TIMEOUT", defaultReadTimeout),
+		writeTimeout:      duration("HTTP_WRITE_TIMEOUT", defaultWriteTimeout),
+		idleTimeout:       duration("HTTP_IDLE_TIMEOUT", defaultIdleTimeout),
+		maxHeaderBytes:    maxHeaderBytes,
+		shutdownTimeout:   duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+	}
+}
+
+func (c serverConfig) newServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: c.readHeaderTimeout,
+		ReadTimeout:       c.readTimeout,
+		WriteTimeout:      c.writeTimeout,
+		IdleTimeout:       c.idleTimeout,
+		MaxHeaderBytes:    c.maxHeaderBytes,
+	}
+}
+
+// serve runs srv until ctx is cancelled, then stops accepting connections and gives in-flight
+// requests until the shutdown timeout to finish before closing what is left
+func (c serverConfig) serve(ctx context.Context, srv *http.Server) error {
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down, waiting up to ", c.shutdownTimeout, " for requests to finish")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("requests still running after shutdown timeout, closing them: ", err)
+		srv.Close()
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerConfigFromEnv(t *testing.T) {
+
+	defaults := serverConfig{
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		readTimeout:       defaultReadTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		idleTimeout:       defaultIdleTimeout,
+		maxHeaderBytes:    defaultMaxHeaderBytes,
+		shutdownTimeout:   defaultShutdownTimeout,
+	}
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		want func(c *serverConfig)
+	}{
+		{name: "unset", want: func(c *serverConfig) {}},
+		{name: "valid", env: map[string]string{
+			"HTTP_READ_HEADER_TIMEOUT": "2s",
+			"HTTP_READ_TIMEOUT":        "10s",
+			"HTTP_WRITE_TIMEOUT":       "1m",
+			"HTTP_IDLE_TIMEOUT":        "90s",
+			"HTTP_MAX_HEADER_BYTES":    "8192",
+			"SHUTDOWN_TIMEOUT":         "500ms",
+		}, want: func(c *serverConfig) {
+			c.readHeaderTimeout = 2 * time.Second
+			c.readTimeout = 10 * time.Second
+			c.writeTimeout = time.Minute
+			c.idleTimeout = 90 * time.Second
+			c.maxHeaderBytes = 8192
+			c.shutdownTimeout = 500 * time.Millisecond
+		}},
+		{name: "no unit", env: map[string]string{"HTTP_READ_TIMEOUT": "15"}, want: func(c *serverConfig) {}},
+		{name: "not a duration", env: map[string]string{"SHUTDOWN_TIMEOUT": "soon"}, want: func(c *serverConfig) {}},
+		{name: "zero", env: map[string]string{"HTTP_WRITE_TIMEOUT": "0s"}, want: func(c *serverConfig) {}},
+		{name: "negative", env: map[string]string{"HTTP_IDLE_TIMEOUT": "-1m"}, want: func(c *serverConfig) {}},
+		{name: "header bytes not a number", env: map[string]string{"HTTP_MAX_HEADER_BYTES": "64k"}, want: func(c *serverConfig) {}},
+		{name: "header bytes negative", env: map[string]string{"HTTP_MAX_HEADER_BYTES": "-1"}, want: func(c *serverConfig) {}},
+		{name: "one invalid among valid", env: map[string]string{
+			"HTTP_READ_TIMEOUT": "nope",
+			"SHUTDOWN_TIMEOUT":  "5s",
+		}, want: func(c *serverConfig) {
+			c.shutdownTimeout = 5 * time.Second
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, name := range []string{
+				"HTTP_READ_HEADER_TIMEOUT", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT",
+				"HTTP_IDLE_TIMEOUT", "HTTP_MAX_HEADER_BYTES", "SHUTDOWN_TIMEOUT",
+			} {
+				t.Setenv(name, c.env[name])
+			}
+
+			want := defaults
+			c.want(&want)
+			if got := serverConfigFromEnv(); got != want {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestServeCancelledContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := serverConfig{shutdownTimeout: time.Second}
+	srv := config.newServer("127.0.0.1:0", http.NotFoundHandler())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- config.serve(ctx, srv)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after its context was cancelled")
+	}
+}
+
+func TestServeClosesRequestsAfterShutdownTimeout(t *testing.T) {
+
+	// find a free port, serve listens on an address rather than a listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	hanging := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-req.Context().Done()
+	})
+
+	config := serverConfig{shutdownTimeout: 50 * time.Millisecond}
+	srv := config.newServer(addr, hanging)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- config.serve(ctx, srv)
+	}()
+
+	// wait for the listener to come up before sending the request that hangs
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request never reached the handler")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected serve to close the hanging request and return cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve waited on the hanging request past its shutdown timeout")
+	}
+
+	select {
+	case err := <-requestErr:
+		if err == nil {
+			t.Error("expected the hanging request's connection to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the hanging request was never closed")
+	}
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/google/uuid"
+)
+
+const (
+	followStatusPending  = "pending"
+	followStatusAccepted = "accepted"
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]{3,30})`)
+
+func validateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return errors.New("handle must be 3-30 letters, digits or underscores")
+	}
+	return nil
+}
+
+const maxDisplayNameLength = 50
+
+func validateDisplayName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxDisplayNameLength {
+		return "", errors.New("display name must be 1-50 characters")
+	}
+	return name, nil
+}
+
+// mentionedHandles returns every distinct @handle in a chirp body, in order of appearance
+func mentionedHandles(body string) []string {
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			handles = append(handles, match[1])
+		}
+	}
+	return handles
+}
+
+// publishTimelineChirp tells websocket timelines about a chirp that has appeared. Only a reference
+// goes out, each connection loads the chirp if its user may see it.
+func (cfg *apiConfig) publishTimelineChirp(ctx context.Context, chirp database.Chirp) {
+	cfg.publishRealtime(ctx, realtime.ChannelChirps, realtime.TypeChirpCreated, struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	})
+}
+
+// publishChirpEvents emits the reply and mention events caused by a freshly created chirp
+func (cfg *apiConfig) publishChirpEvents(ctx context.Context, chirp database.Chirp) {
+
+	// the chirp is already committed, a client hanging up shouldn't stop the fan out
+	ctx = context.WithoutCancel(ctx)
+
+	cfg.publishTimelineChirp(ctx, chirp)
+
+	if chirp.ReplyToID.Valid {
+		parent, err := cfg.db.GetOneChirp(ctx, chirp.ReplyToID.UUID)
+		canView := false
+		if err == nil {
+			canView, _ = cfg.canViewChirp(ctx, parent.UserID, chirp)
+		}
+		if canView {
+			cfg.events.Publish(ctx, events.Event{
+				Kind:       events.KindReply,
+				ActorID:    chirp.UserID,
+				UserID:     parent.UserID,
+				ChirpID:    parent.ID,
+				OccurredAt: chirp.CreatedAt,
+			})
+		}
+	}
+
+	handles := mentionedHandles(chirp.Body)
+	if len(handles) == 0 {
+		return
+	}
+
+	mentioned, err := cfg.db.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return
+	}
+
+	for _, user := range mentioned {
+		// nobody hears about a chirp they aren't allowed to read
+		if canView, err := cfg.canViewChirp(ctx, user.ID, chirp); err != nil || !canView {
+			continue
+		}
+		cfg.events.Publish(ctx, events.Event{
+			Kind:       events.KindMention,
+			ActorID:    chirp.UserID,
+			UserID:     user.ID,
+			ChirpID:    chirp.ID,
+			OccurredAt: chirp.CreatedAt,
+		})
+	}
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, req *http.Request) {
+
+	followerID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	if followeeID == followerID {
+		respondWithError(w, http.StatusBadRequest, "cannot follow yourself", nil)
+		return
+	}
+
+	followee, err := cfg.db.GetUserByID(req.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	}
+
+	// blocking severs follows both ways, so neither side can follow again while it stands
+	blocked, err := cfg.db.IsBlockedEitherWay(req.Context(), database.IsBlockedEitherWayParams{
+		UserID:   followerID,
+		OtherIds: []uuid.UUID{followeeID},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "cannot follow a blocked user", nil)
+		return
+	}
+
+	// protected accounts approve their followers
+	status := followStatusAccepted
+	kind := events.KindFollow
+	if followee.IsProtected {
+		status = followStatusPending
+		kind = events.KindFollowRequest
+	}
+
+	now := time.Now()
+	created, err := cfg.db.FollowUser(req.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		CreatedAt:  now,
+		Status:     status,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not follow user", err)
+		return
+	}
+
+	if created > 0 {
+		cfg.events.Publish(req.Context(), events.Event{
+			Kind:       kind,
+			ActorID:    followerID,
+			UserID:     followeeID,
+			OccurredAt: now,
+		})
+	}
+
+	if status == followStatusPending {
+		respondWithJSON(w, http.StatusAccepted, struct {
+			Status string `json:"status"`
+		}{
+			Status: status,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type FollowRequest struct {
+	FollowerID  uuid.UUID `json:"follower_id"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+func (cfg *apiConfig) handlerGetFollowRequests(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	pending, err := cfg.db.GetPendingFollowRequests(req.Context(), database.GetPendingFollowRequestsParams{
+		FolloweeID: userID,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve follow requests", err)
+		return
+	}
+	pending = reversePage(p, pending)
+
+	requests := []FollowRequest{}
+	for _, follow := range pending {
+		requests = append(requests, FollowRequest{
+			FollowerID:  follow.FollowerID,
+			RequestedAt: follow.CreatedAt,
+		})
+	}
+
+	next, prev := p.cursors(len(pending), func(i int) string {
+		return encodeCursor(pending[i].CreatedAt, pending[i].FollowerID)
+	})
+	setPageLinks(w, req, next, prev)
+
+	respondWithJSON(w, http.StatusOK, requests)
+}
+
+func (cfg *apiConfig) handlerApproveFollowRequest(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	followerID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	approved, err := cfg.db.ApproveFollowRequest(req.Context(), database.ApproveFollowRequestParams{
+		FollowerID: followerID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not approve follow request", err)
+		return
+	}
+	if approved == 0 {
+		respondWithError(w, http.StatusNotFound, "no pending follow request from that user", nil)
+		return
+	}
+
+	cfg.events.Publish(req.Context(), events.Event{
+		Kind:    events.KindFollow,
+		ActorID: followerID,
+		UserID:  userID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRejectFollowRequest(w http.ResponseWriter, req *http.Request) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	followerID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	rejected, err := cfg.db.RejectFollowRequest(req.Context(), database.RejectFollowRequestParams{
+		FollowerID: followerID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not reject follow request", err)
+		return
+	}
+	if rejected == 0 {
+		respondWithError(w, http.StatusNotFound, "no pending follow request from that user", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setUserProtected switches an account between public and protected within the caller's
+// transaction, going public approves every follow request that was still waiting. The caller
+// publishes the approved follows once it commits.
+func setUserProtected(ctx context.Context, qtx *database.Queries, userID uuid.UUID, protected bool) (database.User, []database.Follow, error) {
+
+	user, err := qtx.SetUserProtected(ctx, database.SetUserProtectedParams{
+		IsProtected: protected,
+		UpdatedAt:   time.Now(),
+		ID:          userID,
+	})
+	if err != nil || protected {
+		return user, nil, err
+	}
+
+	approved, err := qtx.ApproveAllFollowRequests(ctx, userID)
+	if err != nil {
+		return user, nil, err
+	}
+	return user, approved, nil
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, req *http.Request) {
+
+	followerID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	_, err = cfg.db.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not unfollow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	created, err := cfg.db.LikeChirp(req.Context(), database.LikeChirpParams{
+		UserID:    userID,
+		ChirpID:   chirp.ID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not like chirp", err)
+		return
+	}
+
+	if created > 0 {
+		cfg.events.Publish(req.Context(), events.Event{
+			Kind:       events.KindLike,
+			ActorID:    userID,
+			UserID:     chirp.UserID,
+			ChirpID:    chirp.ID,
+			OccurredAt: now,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not unlike chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	created, err := cfg.db.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:    userID,
+		ChirpID:   chirp.ID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not rechirp", err)
+		return
+	}
+
+	if created > 0 {
+		cfg.events.Publish(req.Context(), events.Event{
+			Kind:       events.KindRechirp,
+			ActorID:    userID,
+			UserID:     chirp.UserID,
+			ChirpID:    chirp.ID,
+			OccurredAt: now,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUndoRechirp(w http.ResponseWriter, req *http.Request) {
+
+	userID, chirp, ok := cfg.chirpInteraction(w, req)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not undo rechirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// chirpInteraction authenticates the caller and loads the chirp named in the url,
+// writing the error response itself when either fails
+func (cfg *apiConfig) chirpInteraction(w http.ResponseWriter, req *http.Request) (uuid.UUID, database.Chirp, bool) {
+
+	userID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract chirp id from url", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	chirp, err := cfg.db.GetOneChirp(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp does not exist", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	canView, err := cfg.canViewChirp(req.Context(), userID, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve chirp", err)
+		return uuid.Nil, database.Chirp{}, false
+	}
+	if !canView {
+		respondWithError(w, http.StatusNotFound, "chirp does not exist", nil)
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	return userID, chirp, true
+}
+
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, req *http.Request) {
+
+	blockerID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	blockedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	if blockedID == blockerID {
+		respondWithError(w, http.StatusBadRequest, "cannot block yourself", nil)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(req.Context(), blockedID); err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	}
+
+	err = cfg.db.BlockUser(req.Context(), database.BlockUserParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not block user", err)
+		return
+	}
+
+	// a block also severs the follow relationship in both directions
+	err = cfg.db.RemoveFollowsBetween(req.Context(), database.RemoveFollowsBetweenParams{
+		UserA: blockerID,
+		UserB: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove follows", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, req *http.Request) {
+
+	blockerID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	blockedID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not extract user id from url", err)
+		return
+	}
+
+	err = cfg.db.UnblockUser(req.Context(), database.UnblockUserParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not unblock user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/colfarl/chirpy-server/internal/events"
+	"github.com/google/uuid"
+)
+
+func TestMentionedHandles(t *testing.T) {
+
+	cases := []struct {
+		body string
+		want []string
+	}{
+		{"no mentions here", []string{}},
+		{"@alice hi", []string{"alice"}},
+		{"hi @alice and @bob_2, @alice again", []string{"alice", "bob_2"}},
+		{"(@carol) @dave!", []string{"carol", "dave"}},
+		{"email me at me@example.com", []string{}},
+		{"@@eve and x@frank", []string{}},
+		{"@ab is too short", []string{}},
+	}
+
+	for _, c := range cases {
+		if got := mentionedHandles(c.body); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %v, got %v", c.body, c.want, got)
+		}
+	}
+}
+
+func TestValidateHandle(t *testing.T) {
+
+	for _, handle := range []string{"abc", "Alice_99", "a_very_long_handle_of_30_chars"} {
+		if err := validateHandle(handle); err != nil {
+			t.Errorf("expected %q to be valid, got %v", handle, err)
+		}
+	}
+	for _, handle := range []string{"", "ab", "has space", "dash-ed", "a_very_long_handle_of_31_chars_", "émile"} {
+		if err := validateHandle(handle); err == nil {
+			t.Errorf("expected %q to be rejected", handle)
+		}
+	}
+}
+
+func TestValidateDisplayName(t *testing.T) {
+
+	if name, err := validateDisplayName("  Ada Lovelace  "); err != nil || name != "Ada Lovelace" {
+		t.Errorf("expected the name trimmed, got %q %v", name, err)
+	}
+	fifty := ""
+	for range 50 {
+		fifty += "é"
+	}
+	if _, err := validateDisplayName(fifty); err != nil {
+		t.Errorf("expected 50 characters to be allowed, got %v", err)
+	}
+	for _, name := range []string{"", "   ", fifty + "e"} {
+		if _, err := validateDisplayName(name); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestFollowUser(t *testing.T) {
+
+	follower, followee := testUser(), testUser()
+
+	cases := []struct {
+		name     string
+		target   uuid.UUID
+		already  bool
+		blocked  bool
+		want     int
+		notified bool
+	}{
+		{name: "new follow", target: followee.ID, want: http.StatusNoContent, notified: true},
+		{name: "already following", target: followee.ID, already: true, want: http.StatusNoContent},
+		{name: "yourself", target: follower.ID, want: http.StatusBadRequest},
+		{name: "nobody", target: uuid.New(), want: http.StatusNotFound},
+		{name: "blocked either way", target: followee.ID, blocked: true, want: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		fake, cfg := newTestConfig(t)
+		fake.answer("GetUserByID", usersByID(follower, followee))
+		fake.returns("IsBlockedEitherWay", row(c.blocked))
+		if !c.already {
+			fake.returns("FollowUser", row())
+		}
+
+		req := authorizedRequest(t, follower.ID, http.MethodPost, "/api/users/"+c.target.String()+"/follow", "")
+		req.SetPathValue("userID", c.target.String())
+		w := httptest.NewRecorder()
+		cfg.handlerFollowUser(w, req)
+
+		if w.Code != c.want {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.want, w.Code, w.Body)
+		}
+		if c.blocked {
+			checked := fake.calledWith("IsBlockedEitherWay")
+			if len(checked) != 1 || checked[0][0] != follower.ID.String() || !strings.Contains(checked[0][1].(string), followee.ID.String()) {
+				t.Errorf("%s: expected the block checked between both users, got %v", c.name, checked)
+			}
+		}
+		followed := fake.calledWith("FollowUser")
+		if (len(followed) == 1) != (c.want == http.StatusNoContent) {
+			t.Errorf("%s: expected a follow only for a valid target, got %v", c.name, followed)
+		}
+
+		notified := fake.calledWith("UpsertNotification")
+		if !c.notified {
+			if len(notified) != 0 {
+				t.Errorf("%s: expected no notification, got %v", c.name, notified)
+			}
+			continue
+		}
+		if len(notified) != 1 || notified[0][3] != followee.ID.String() || notified[0][4] != string(events.KindFollow) {
+			t.Errorf("%s: expected %s notified of the follow, got %v", c.name, followee.ID, notified)
+		}
+	}
+}
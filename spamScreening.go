@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/spam"
+	"github.com/colfarl/chirpy-server/internal/stream"
+	"github.com/google/uuid"
+)
+
+const (
+	// chirps by the same author inside this window count towards posting velocity
+	spamVelocityWindow = 10 * time.Minute
+	// chirps screened inside this window are compared for near duplicates
+	spamDuplicateWindow = time.Hour
+	spamMaxRecentHashes = 500
+)
+
+const (
+	moderationActionRelease    = "release_chirp"
+	moderationActionRemoveHeld = "remove_held_chirp"
+)
+
+var errChirpSpam = errors.New("chirp looks like spam")
+
+type SpamDecision struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ChirpID   *uuid.UUID      `json:"chirp_id,omitempty"`
+	Body      string          `json:"body"`
+	SimHash   string          `json:"simhash"`
+	Score     float64         `json:"score"`
+	Verdict   string          `json:"verdict"`
+	Hits      json.RawMessage `json:"hits"`
+}
+
+func spamDecisionFromDB(decision database.SpamDecision) SpamDecision {
+	formatted := SpamDecision{
+		ID:        decision.ID,
+		CreatedAt: decision.CreatedAt,
+		UserID:    decision.UserID,
+		Body:      decision.Body,
+		SimHash:   fmt.Sprintf("%016x", uint64(decision.Simhash)),
+		Score:     decision.Score,
+		Verdict:   decision.Verdict,
+		Hits:      decision.Hits,
+	}
+	if decision.ChirpID.Valid {
+		formatted.ChirpID = &decision.ChirpID.UUID
+	}
+	return formatted
+}
+
+// spamPipelineFromEnv reads the score thresholds from SPAM_HOLD_SCORE and SPAM_REJECT_SCORE,
+// so moderators can tune them against recorded decisions without a code change
+func spamPipelineFromEnv() spam.Pipeline {
+
+	threshold := func(name string, fallback float64) float64 {
+		raw := os.Getenv(name)
+		if raw == "" {
+			return fallback
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			log.Println("ignoring invalid ", name, ": ", err)
+			return fallback
+		}
+		return value
+	}
+
+	return spam.NewPipeline(
+		threshold("SPAM_HOLD_SCORE", spam.DefaultHoldScore),
+		threshold("SPAM_REJECT_SCORE", spam.DefaultRejectScore),
+	)
+}
+
+// screenChirp gathers what the spam rules need to know about a chirp userID is about to post and scores it
+func (cfg *apiConfig) screenChirp(ctx context.Context, db *database.Queries, userID uuid.UUID, body string) (spam.Signals, spam.Decision, error) {
+
+	now := time.Now()
+	signals := spam.Signals{
+		Body: body,
+		Hash: spam.SimHash(body),
+	}
+
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil {
+		return signals, spam.Decision{}, err
+	}
+	signals.AccountAge = now.Sub(user.CreatedAt)
+
+	recent, err := db.CountChirpsByAuthorSince(ctx, database.CountChirpsByAuthorSinceParams{
+		UserID: userID,
+		Since:  now.Add(-spamVelocityWindow),
+	})
+	if err != nil {
+		return signals, spam.Decision{}, err
+	}
+	signals.RecentChirps = int(recent)
+
+	hashes, err := db.GetRecentSpamHashes(ctx, database.GetRecentSpamHashesParams{
+		Since:     now.Add(-spamDuplicateWindow),
+		MaxHashes: spamMaxRecentHashes,
+	})
+	if err != nil {
+		return signals, spam.Decision{}, err
+	}
+	for _, hash := range hashes {
+		if hash.UserID == userID {
+			signals.AuthorHashes = append(signals.AuthorHashes, uint64(hash.Simhash))
+		} else {
+			signals.OtherHashes = append(signals.OtherHashes, uint64(hash.Simhash))
+		}
+	}
+
+	return signals, cfg.spam.Evaluate(signals), nil
+}
+
+// recordSpamDecision keeps every screening outcome, rejected chirps included, both for tuning the
+// thresholds and as the history the duplicate rule compares against
+func recordSpamDecision(ctx context.Context, db *database.Queries, userID uuid.UUID, chirpID uuid.NullUUID, signals spam.Signals, decision spam.Decision) error {
+
+	hits, err := json.Marshal(decision.Hits)
+	if err != nil {
+		return err
+	}
+
+	return db.RecordSpamDecision(ctx, database.RecordSpamDecisionParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		UserID:    userID,
+		ChirpID:   chirpID,
+		Body:      signals.Body,
+		Simhash:   int64(signals.Hash),
+		Score:     decision.Score,
+		Verdict:   string(decision.Verdict),
+		Hits:      hits,
+	})
+}
+
+func (cfg *apiConfig) handlerGetSpamDecisions(w http.ResponseWriter, req *http.Request) {
+
+	verdict := sql.NullString{}
+	switch raw := req.URL.Query().Get("verdict"); spam.Verdict(raw) {
+	case "":
+	case spam.VerdictAccept, spam.VerdictHold, spam.VerdictReject:
+		verdict = sql.NullString{String: raw, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "verdict must be accept, hold or reject", nil)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetSpamDecisions(req.Context(), database.GetSpamDecisionsParams{
+		Verdict:    verdict,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve spam decisions", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	decisions := []SpamDecision{}
+	for _, decision := range unformatted {
+		decisions = append(decisions, spamDecisionFromDB(decision))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, decisions)
+}
+
+func (cfg *apiConfig) handlerGetHeldChirps(w http.ResponseWriter, req *http.Request) {
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetHeldChirps(req.Context(), database.GetHeldChirpsParams{
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve held chirps", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	chirps := []Chirp{}
+	for _, chirp := range unformatted {
+		chirps = append(chirps, chirpFromDB(chirp))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].CreatedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// handlerReleaseHeldChirp publishes a held chirp to everyone it was meant for, only now do the
+// people it replies to or mentions hear about it
+func (cfg *apiConfig) handlerReleaseHeldChirp(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, chirpID, ok := cfg.heldChirpAction(w, req)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.ReleaseHeldChirp(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "chirp is not held for review", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:        moderationActionRelease,
+		TargetUserID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		TargetChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+
+	if err := enqueueChirpWebhook(req.Context(), qtx, outbound.EventChirpCreated, chirp); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+	if err := recordChirpStreamEvent(req.Context(), qtx, stream.TypeChirpCreated, chirp); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not release chirp", err)
+		return
+	}
+
+	cfg.publishChirpEvents(req.Context(), chirp)
+	respondWithJSON(w, http.StatusOK, chirpFromDB(chirp))
+}
+
+func (cfg *apiConfig) handlerRemoveHeldChirp(w http.ResponseWriter, req *http.Request) {
+
+	moderatorID, chirpID, ok := cfg.heldChirpAction(w, req)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.db.GetOneChirp(req.Context(), chirpID)
+	if err != nil || !chirp.HeldForReview {
+		respondWithError(w, http.StatusNotFound, "chirp is not held for review", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.SoftDeleteChirp(req.Context(), database.SoftDeleteChirpParams{
+		ID:                 chirp.ID,
+		DeletedAt:          time.Now(),
+		RemovedByModerator: true,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove chirp", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:        moderationActionRemoveHeld,
+		TargetUserID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		TargetChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Note:          chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not remove chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// heldChirpAction reads the moderator and chirp a held chirp endpoint acts on
+func (cfg *apiConfig) heldChirpAction(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return moderatorID, chirpID, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestRemoveHeldChirp(t *testing.T) {
+
+	moderator := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "buy now", UserID: uuid.New(), Visibility: "public"}
+
+	cases := []struct {
+		name   string
+		held   bool
+		status int
+	}{
+		{name: "held", held: true, status: http.StatusNoContent},
+		{name: "not held", status: http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			found := chirp
+			found.HeldForReview = c.held
+			fake.returns("GetOneChirp", row(found))
+
+			req := authorizedRequest(t, moderator, http.MethodDelete, "/admin/spam/held/"+chirp.ID.String(), "")
+			req.SetPathValue("chirpID", chirp.ID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerRemoveHeldChirp(w, req)
+
+			if w.Code != c.status {
+				t.Fatalf("expected %d, got %d %s", c.status, w.Code, w.Body)
+			}
+			removed := fake.calledWith("SoftDeleteChirp")
+			logged := fake.calledWith("LogModerationAction")
+			if c.status != http.StatusNoContent {
+				if len(removed) != 0 || len(logged) != 0 {
+					t.Errorf("expected the chirp left alone, got %v %v", removed, logged)
+				}
+				return
+			}
+			if len(removed) != 1 || removed[0][2] != chirp.ID.String() || removed[0][1] != true {
+				t.Errorf("expected the chirp removed by a moderator, got %v", removed)
+			}
+			if calls := fake.calledWith("DeleteChirp"); len(calls) != 0 {
+				t.Errorf("expected the chirp not to be deleted for good, got %v", calls)
+			}
+			if len(logged) != 1 || !containsValue(logged[0], moderationActionRemoveHeld) {
+				t.Errorf("expected the removal logged, got %v", logged)
+			}
+		})
+	}
+}
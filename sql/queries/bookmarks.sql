@@ -0,0 +1,29 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+SELECT sqlc.embed(chirps), bookmarks.created_at AS bookmarked_at
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+    AND chirps.deleted_at IS NULL
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (bookmarks.created_at, bookmarks.chirp_id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN bookmarks.created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN bookmarks.chirp_id END ASC,
+    bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(page_size);
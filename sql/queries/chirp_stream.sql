@@ -0,0 +1,36 @@
+-- name: RecordChirpStreamEvent :exec
+-- only chirps anyone may see are streamed. The notification carries the event's id and is
+-- delivered to every instance listening when the transaction commits.
+WITH recorded AS (
+    INSERT INTO chirp_stream_events (created_at, event_type, chirp_id, author_id, unlisted, payload)
+    SELECT sqlc.arg(created_at)::timestamp, sqlc.arg(event_type)::text, sqlc.arg(chirp_id)::uuid, users.id,
+        sqlc.arg(visibility)::text = 'unlisted', sqlc.arg(payload)::jsonb
+    FROM users
+    WHERE users.id = sqlc.arg(author_id)::uuid
+        AND NOT users.is_protected
+        AND users.shadow_banned_at IS NULL
+        AND sqlc.arg(visibility)::text IN ('public', 'unlisted')
+    RETURNING id
+)
+SELECT pg_notify('chirp_stream', id::text)
+FROM recorded;
+
+-- name: GetChirpStreamEvent :one
+SELECT *
+FROM chirp_stream_events
+WHERE id = $1;
+
+-- name: GetChirpStreamEventsAfter :many
+SELECT *
+FROM chirp_stream_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(max_events);
+
+-- name: GetLatestChirpStreamEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint
+FROM chirp_stream_events;
+
+-- name: PurgeChirpStreamEvents :execrows
+DELETE FROM chirp_stream_events
+WHERE created_at < sqlc.arg(before)::timestamp;
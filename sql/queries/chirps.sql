@@ -1,30 +1,87 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, visibility, language, media_urls, held_for_review)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
 )
 RETURNING *;
 
--- name: GetAllChirps :many
-SELECT * 
-FROM chirps
-ORDER BY created_at ASC;
-
 -- name: GetOneChirp :one
 SELECT * 
 FROM chirps
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1;
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = sqlc.arg(deleted_at)::timestamp,
+    updated_at = sqlc.arg(deleted_at)::timestamp,
+    removed_by_moderator = sqlc.arg(removed_by_moderator)
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL;
 
--- name: GetAllChirpsByAuthor :many
-SELECT * 
+-- name: GetChirpsPageByAuthor :many
+SELECT c.*
+FROM chirps c
+WHERE c.user_id = sqlc.arg(user_id)
+    AND c.deleted_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1
+        FROM pinned_chirps p
+        WHERE p.user_id = c.user_id AND p.chirp_id = c.id
+    )
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (c.created_at, c.id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (c.created_at, c.id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN c.created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN c.id END ASC,
+    c.created_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTrashedChirpsByAuthor :many
+SELECT *
 FROM chirps
-WHERE user_id = $1;
+WHERE user_id = sqlc.arg(user_id)
+    AND deleted_at > sqlc.arg(deleted_after)::timestamp
+    AND NOT removed_by_moderator
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (deleted_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (deleted_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN deleted_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    deleted_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+    AND user_id = sqlc.arg(user_id)
+    AND deleted_at > sqlc.arg(deleted_after)::timestamp
+    AND NOT removed_by_moderator
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= sqlc.arg(deleted_before)::timestamp;
+
+-- name: EditChirp :one
+-- an edit that screens as suspicious holds the chirp, an edit never releases one
+UPDATE chirps
+SET body = sqlc.arg(body),
+    updated_at = sqlc.arg(updated_at),
+    held_for_review = held_for_review OR sqlc.arg(hold)::boolean
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+RETURNING *;
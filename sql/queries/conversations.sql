@@ -0,0 +1,102 @@
+-- name: CreateConversation :one
+-- returns no rows when the one-to-one conversation for direct_key already exists
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group, direct_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    sqlc.narg(direct_key)
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+    $1,
+    $2,
+    $3
+);
+
+-- name: FindDirectConversation :one
+SELECT *
+FROM conversations
+WHERE direct_key = sqlc.arg(direct_key)::text;
+
+-- name: GetConversationForMember :one
+SELECT c.*
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE c.id = $1 AND m.user_id = $2;
+
+-- name: ListConversationsForUser :many
+SELECT c.*, (
+    SELECT COUNT(*)
+    FROM direct_messages d
+    WHERE d.conversation_id = c.id
+        AND d.sender_id <> m.user_id
+        AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at)
+) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (c.updated_at, c.id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (c.updated_at, c.id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN c.updated_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN c.id END ASC,
+    c.updated_at DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListConversationMembers :many
+SELECT *
+FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY joined_at ASC;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = $2
+WHERE id = $1;
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: ListDirectMessages :many
+SELECT d.*
+FROM direct_messages d
+WHERE d.conversation_id = sqlc.arg(conversation_id)
+    AND NOT EXISTS (
+        SELECT 1
+        FROM blocks b
+        WHERE b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = d.sender_id
+    )
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (d.created_at, d.id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (d.created_at, d.id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN d.created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN d.id END ASC,
+    d.created_at DESC, d.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = sqlc.arg(read_at)::timestamp
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id)
+    AND (last_read_at IS NULL OR last_read_at < sqlc.arg(read_at)::timestamp);
@@ -0,0 +1,86 @@
+-- name: EnqueueJob :exec
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJob :one
+-- locks the next due job until lease_until, skipping jobs other workers hold
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = sqlc.arg(lease_until)::timestamp,
+    updated_at = sqlc.arg(now)::timestamp
+WHERE id = (
+    SELECT id
+    FROM jobs
+    WHERE kind = ANY(sqlc.arg(kinds)::text[])
+        AND (
+            (status = 'pending' AND run_at <= sqlc.arg(now)::timestamp)
+            OR (status = 'running' AND locked_until < sqlc.arg(now)::timestamp)
+        )
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'completed',
+    locked_until = NULL,
+    last_error = NULL,
+    finished_at = sqlc.arg(now)::timestamp,
+    updated_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id);
+
+-- name: FailJob :exec
+-- a dead job stays put until an admin retries it, any other goes back in the queue at run_at
+UPDATE jobs
+SET status = CASE WHEN sqlc.arg(dead)::boolean THEN 'dead' ELSE 'pending' END,
+    run_at = sqlc.arg(run_at)::timestamp,
+    locked_until = NULL,
+    last_error = sqlc.arg(last_error)::text,
+    finished_at = CASE WHEN sqlc.arg(dead)::boolean THEN sqlc.arg(now)::timestamp END,
+    updated_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id);
+
+-- name: GetJob :one
+SELECT *
+FROM jobs
+WHERE id = $1;
+
+-- name: GetJobs :many
+SELECT *
+FROM jobs
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+    AND (sqlc.narg(kind)::text IS NULL OR kind = sqlc.narg(kind)::text)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: RetryJob :one
+-- puts a dead job back in the queue with a fresh set of attempts
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = $1, finished_at = NULL, updated_at = $1
+WHERE id = $2 AND status = 'dead'
+RETURNING *;
+
+-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'completed' AND finished_at < sqlc.arg(before)::timestamp;
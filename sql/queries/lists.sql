@@ -0,0 +1,74 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetListForOwner :one
+SELECT *
+FROM lists
+WHERE id = $1 AND owner_id = $2;
+
+-- name: GetListsByOwner :many
+SELECT *
+FROM lists
+WHERE owner_id = sqlc.arg(owner_id)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: RenameList :one
+UPDATE lists
+SET name = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, added_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT *
+FROM list_members
+WHERE list_id = $1
+ORDER BY added_at ASC;
+
+-- name: GetListMembersPage :many
+SELECT *
+FROM list_members
+WHERE list_id = sqlc.arg(list_id)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (added_at, user_id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (added_at, user_id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN added_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN user_id END DESC,
+    added_at ASC, user_id ASC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,68 @@
+-- name: GetModerationRules :many
+SELECT word, action
+FROM moderation_words;
+
+-- name: GetModerationWords :many
+SELECT *
+FROM moderation_words
+WHERE (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: CreateModerationWord :one
+INSERT INTO moderation_words (id, created_at, updated_at, word, action)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: UpdateModerationWord :one
+UPDATE moderation_words
+SET action = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteModerationWord :one
+DELETE FROM moderation_words
+WHERE id = $1
+RETURNING *;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, created_at, words)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (chirp_id) DO NOTHING;
+
+-- name: GetChirpFlags :many
+SELECT sqlc.embed(chirp_flags), sqlc.embed(chirps)
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (chirp_flags.created_at, chirp_flags.chirp_id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (chirp_flags.created_at, chirp_flags.chirp_id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN chirp_flags.created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN chirp_flags.chirp_id END DESC,
+    chirp_flags.created_at ASC, chirp_flags.chirp_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: DismissChirpFlag :execrows
+DELETE FROM chirp_flags
+WHERE chirp_id = $1;
@@ -0,0 +1,73 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, kind, chirp_id, group_key)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (notification_id, actor_id)
+DO UPDATE SET created_at = EXCLUDED.created_at;
+
+-- name: ListNotifications :many
+-- pages by created_at, which stays put while updated_at moves each time an actor joins the group
+SELECT n.*, (
+    SELECT COUNT(*)
+    FROM notification_actors a
+    WHERE a.notification_id = n.id
+) AS actor_count
+FROM notifications n
+WHERE n.user_id = sqlc.arg(user_id)
+    AND (NOT sqlc.arg(unread_only)::boolean OR n.read_at IS NULL)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (n.created_at, n.id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (n.created_at, n.id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN n.created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN n.id END ASC,
+    n.created_at DESC, n.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListRecentNotificationActors :many
+SELECT ranked.notification_id, ranked.actor_id
+FROM (
+    SELECT notification_id, actor_id, row_number() OVER (
+        PARTITION BY notification_id
+        ORDER BY created_at DESC
+    ) AS position
+    FROM notification_actors
+    WHERE notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+) ranked
+WHERE ranked.position <= sqlc.arg(per_notification)::bigint
+ORDER BY ranked.notification_id, ranked.position;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = sqlc.arg(read_at)::timestamp
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = sqlc.arg(read_at)::timestamp
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL;
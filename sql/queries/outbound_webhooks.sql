@@ -0,0 +1,135 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, scope, url, secret, event_types)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT *
+FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: GetWebhookEndpointsByUser :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = sqlc.arg(user_id)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET enabled = true, consecutive_failures = 0, disabled_at = NULL, updated_at = $1
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- one delivery for every enabled endpoint that subscribed to the event and may see it
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), sqlc.arg(created_at)::timestamp, sqlc.arg(created_at)::timestamp, id,
+    sqlc.arg(event_id)::uuid, sqlc.arg(event_type)::text, sqlc.arg(payload)::jsonb, sqlc.arg(created_at)::timestamp
+FROM webhook_endpoints
+WHERE enabled
+    AND sqlc.arg(event_type)::text = ANY(event_types)
+    AND (scope = 'global' OR user_id = sqlc.arg(subject_user_id)::uuid);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- leases due deliveries by pushing next_attempt_at past the send timeout, so a crashed
+-- dispatcher's deliveries are picked up again once the lease runs out
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT d.id
+    FROM webhook_deliveries d
+    JOIN webhook_endpoints e ON e.id = d.endpoint_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= sqlc.arg(now)::timestamp AND e.enabled
+    ORDER BY d.next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE OF d SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+);
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    last_status_code = sqlc.narg(last_status_code),
+    last_error = sqlc.narg(last_error),
+    delivered_at = sqlc.narg(delivered_at),
+    updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id);
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures > 0;
+
+-- name: RecordWebhookEndpointFailure :one
+-- switches the endpoint off once it has failed disable_after times in a row
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND consecutive_failures + 1 < sqlc.arg(disable_after)::integer,
+    disabled_at = CASE
+        WHEN enabled AND consecutive_failures + 1 >= sqlc.arg(disable_after)::integer THEN sqlc.arg(now)::timestamp
+        ELSE disabled_at
+    END,
+    updated_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1 AND endpoint_id = $2;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
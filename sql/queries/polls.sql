@@ -0,0 +1,51 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, chirp_id, position, label)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetPoll :one
+SELECT *
+FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+SELECT *
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: GetPollOptionTallies :many
+SELECT o.id, o.chirp_id, o.position, o.label, COUNT(v.user_id) AS votes
+FROM poll_options o
+LEFT JOIN poll_votes v ON v.option_id = o.id
+WHERE o.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY o.id
+ORDER BY o.chirp_id, o.position;
+
+-- name: GetVotesByUser :many
+SELECT *
+FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: CastPollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, option_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
@@ -0,0 +1,3 @@
+-- name: NotifyRealtime :exec
+-- relays a websocket message to every instance, payloads are limited to 8000 bytes
+SELECT pg_notify('realtime', sqlc.arg(payload)::text);
@@ -19,3 +19,8 @@ WHERE token = $1;
 UPDATE refresh_tokens
 SET updated_at = $2, revoked_at = $2
 WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = $2, revoked_at = $2
+WHERE user_id = $1 AND revoked_at IS NULL;
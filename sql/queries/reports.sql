@@ -0,0 +1,88 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, target_user_id, target_chirp_id, chirp_body, reason, details)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT *
+FROM reports
+WHERE id = $1;
+
+-- name: GetReportQueue :many
+SELECT *
+FROM reports
+WHERE status = ANY(sqlc.arg(statuses)::text[])
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed', claimed_by = sqlc.arg(moderator_id), updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id) AND status = 'open'
+RETURNING *;
+
+-- name: CloseReport :one
+-- a claimed report can only be closed by the moderator who claimed it
+UPDATE reports
+SET status = sqlc.arg(status),
+    resolution = sqlc.narg(resolution),
+    claimed_by = sqlc.arg(moderator_id)::uuid,
+    closed_at = sqlc.arg(closed_at)::timestamp,
+    updated_at = sqlc.arg(closed_at)
+WHERE id = sqlc.arg(id)
+    AND (status = 'open' OR (status = 'claimed' AND claimed_by = sqlc.arg(moderator_id)::uuid))
+RETURNING *;
+
+-- name: LogModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, action, target_user_id, target_chirp_id, note)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+);
+
+-- name: GetModerationActions :many
+SELECT *
+FROM moderation_actions
+WHERE (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = sqlc.arg(suspended_at)::timestamp,
+    suspended_until = sqlc.narg(suspended_until),
+    suspension_reason = sqlc.arg(reason)::text,
+    updated_at = sqlc.arg(suspended_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
@@ -0,0 +1,64 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, reply_to_id, publish_at, status, visibility)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+RETURNING *;
+
+-- name: GetScheduledChirpForUser :one
+SELECT *
+FROM scheduled_chirps
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetScheduledChirpsByUser :many
+SELECT *
+FROM scheduled_chirps
+WHERE user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: UpdatePendingScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $1, reply_to_id = $2, publish_at = $3, status = $4, visibility = $5, updated_at = $6
+WHERE id = $7 AND user_id = $8 AND status IN ('draft', 'scheduled')
+RETURNING *;
+
+-- name: CancelPendingScheduledChirp :execrows
+UPDATE scheduled_chirps
+SET status = 'cancelled', updated_at = $3
+WHERE id = $1 AND user_id = $2 AND status IN ('draft', 'scheduled');
+
+-- name: ClaimDueScheduledChirp :one
+SELECT *
+FROM scheduled_chirps
+WHERE status = 'scheduled' AND publish_at <= $1
+ORDER BY publish_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkScheduledChirpPublished :exec
+UPDATE scheduled_chirps
+SET status = 'published', chirp_id = sqlc.arg(chirp_id)::uuid, updated_at = sqlc.arg(updated_at), last_error = NULL
+WHERE id = sqlc.arg(id);
+
+-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET status = 'failed', last_error = sqlc.arg(last_error)::text, updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id) AND status = 'scheduled';
@@ -0,0 +1,25 @@
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    ts_rank_cd(chirp_search_vector(chirps.language, chirps.body), to_tsquery(sqlc.arg(language)::text::regconfig, sqlc.arg(query)::text))::real AS rank,
+    -- matches are marked with private use characters the body can't contain, so the body can be
+    -- escaped before the marks become <mark> tags
+    ts_headline(chirps.language::regconfig, translate(chirps.body, E'\uE000\uE001', ''), to_tsquery(sqlc.arg(language)::text::regconfig, sqlc.arg(query)::text),
+        E'StartSel="\uE000", StopSel="\uE001", MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+FROM chirps
+WHERE chirps.deleted_at IS NULL
+    AND (
+        sqlc.arg(query)::text = ''
+        OR chirp_search_vector(chirps.language, chirps.body) @@ to_tsquery(sqlc.arg(language)::text::regconfig, sqlc.arg(query)::text)
+    )
+    AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+    AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since)::timestamp)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: SearchUsers :many
+SELECT id, handle, display_name,
+    ts_rank_cd(user_search_vector(handle, display_name), to_tsquery('simple', sqlc.arg(query)::text))::real AS rank
+FROM users
+WHERE user_search_vector(handle, display_name) @@ to_tsquery('simple', sqlc.arg(query)::text)
+ORDER BY rank DESC, handle ASC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,102 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at, status)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetPendingFollowRequests :many
+SELECT *
+FROM follows
+WHERE followee_id = sqlc.arg(followee_id) AND status = 'pending'
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, follower_id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, follower_id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN follower_id END DESC,
+    created_at ASC, follower_id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ApproveFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted'
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: ApproveAllFollowRequests :many
+UPDATE follows
+SET status = 'accepted'
+WHERE followee_id = $1 AND status = 'pending'
+RETURNING *;
+
+-- name: RejectFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending';
+
+-- name: GetFollowedAmong :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = sqlc.arg(follower_id)
+    AND status = 'accepted'
+    AND followee_id = ANY(sqlc.arg(followee_ids)::uuid[]);
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: CreateRechirp :execrows
+INSERT INTO rechirps (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRechirp :execrows
+DELETE FROM rechirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_a) AND followee_id = sqlc.arg(user_b))
+    OR (follower_id = sqlc.arg(user_b) AND followee_id = sqlc.arg(user_a));
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::uuid[]))
+        OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::uuid[]))
+);
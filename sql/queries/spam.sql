@@ -0,0 +1,61 @@
+-- name: RecordSpamDecision :exec
+INSERT INTO spam_decisions (id, created_at, user_id, chirp_id, body, simhash, score, verdict, hits)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+);
+
+-- name: GetRecentSpamHashes :many
+SELECT user_id, simhash
+FROM spam_decisions
+WHERE created_at >= sqlc.arg(since)::timestamp
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_hashes);
+
+-- name: CountChirpsByAuthorSince :one
+SELECT COUNT(*)
+FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND created_at >= sqlc.arg(since)::timestamp;
+
+-- name: GetSpamDecisions :many
+SELECT *
+FROM spam_decisions
+WHERE (sqlc.narg(verdict)::text IS NULL OR verdict = sqlc.narg(verdict)::text)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetHeldChirps :many
+SELECT *
+FROM chirps
+WHERE held_for_review AND deleted_at IS NULL
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (created_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (created_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN created_at END DESC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END DESC,
+    created_at ASC, id ASC
+LIMIT sqlc.arg(page_size);
+
+-- name: ReleaseHeldChirp :one
+UPDATE chirps
+SET held_for_review = false
+WHERE id = $1 AND held_for_review AND deleted_at IS NULL
+RETURNING *;
@@ -0,0 +1,40 @@
+-- name: GetSubscriptionByUser :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1;
+
+-- name: LockSubscriptionByUser :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: SaveSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at, canceled_at)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
+)
+ON CONFLICT (user_id) DO UPDATE
+SET tier = EXCLUDED.tier,
+    status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    expires_at = EXCLUDED.expires_at,
+    canceled_at = EXCLUDED.canceled_at,
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = sqlc.arg(now)::timestamp
+WHERE status <> 'expired' AND expires_at <= sqlc.arg(now)::timestamp
+RETURNING user_id;
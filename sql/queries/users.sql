@@ -12,13 +12,14 @@ RETURNING *;
 DELETE FROM users;
 
 -- name: CreateUserWithPassWord :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     $1,
     $2,
     $3,
     $4,
-    $5
+    $5,
+    $6
 )
 RETURNING *;
 
@@ -38,7 +39,58 @@ SET  email = $1, hashed_password = $2, updated_at = $4
 where id = $3
 RETURNING *;
 
--- name: UpgradeChirpyRed :exec
+-- name: SetUserHandle :one
 UPDATE users
-SET is_chirpy_red = true
+SET handle = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: SetUserDisplayName :one
+UPDATE users
+SET display_name = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: GetUsersByHandles :many
+SELECT *
+FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[]);
+
+-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: GetProtectedAmong :many
+SELECT id
+FROM users
+WHERE is_protected AND id = ANY(sqlc.arg(user_ids)::uuid[]);
+
+-- name: LockUser :exec
+SELECT id
+FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: GetUserSuspension :one
+SELECT suspended_at, suspended_until, suspension_reason
+FROM users
 WHERE id = $1;
+
+-- name: LiftSuspension :one
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL, updated_at = $1
+WHERE id = $2
+RETURNING *;
+
+-- name: SetShadowBan :one
+UPDATE users
+SET shadow_banned_at = sqlc.narg(shadow_banned_at), updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetShadowBannedAmong :many
+SELECT id
+FROM users
+WHERE shadow_banned_at IS NOT NULL AND id = ANY(sqlc.arg(user_ids)::uuid[]);
@@ -0,0 +1,52 @@
+-- name: RecordWebhookEvent :one
+-- a redelivered event returns the row stored the first time
+INSERT INTO webhook_events (id, received_at, updated_at, provider, event_id, event_type, payload)
+VALUES (
+    $1,
+    $2,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (provider, event_id) DO UPDATE
+SET updated_at = webhook_events.updated_at
+RETURNING *;
+
+-- name: ClaimWebhookEvent :one
+-- locks an event that still needs processing, concurrent deliveries of the same event skip it
+SELECT *
+FROM webhook_events
+WHERE id = $1 AND status IN ('pending', 'failed')
+FOR UPDATE SKIP LOCKED;
+
+-- name: CompleteWebhookEvent :exec
+UPDATE webhook_events
+SET status = $1, attempts = attempts + 1, last_error = NULL, processed_at = $2, updated_at = $2
+WHERE id = $3;
+
+-- name: FailWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'failed', attempts = attempts + 1, last_error = $1, updated_at = $2
+WHERE id = $3;
+
+-- name: GetWebhookEvent :one
+SELECT *
+FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEvents :many
+SELECT *
+FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+    AND (
+        sqlc.narg(cursor_time)::timestamp IS NULL
+        OR (NOT sqlc.arg(backward)::boolean AND (received_at, id) < (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+        OR (sqlc.arg(backward)::boolean AND (received_at, id) > (sqlc.narg(cursor_time)::timestamp, sqlc.narg(cursor_id)::uuid))
+    )
+ORDER BY
+    CASE WHEN sqlc.arg(backward)::boolean THEN received_at END ASC,
+    CASE WHEN sqlc.arg(backward)::boolean THEN id END ASC,
+    received_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,47 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT UNIQUE;
+
+ALTER TABLE chirps
+ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE follows (
+    follower_id 	UUID NOT NULL,
+    followee_id 	UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (follower_id, followee_id),
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE chirp_likes (
+    user_id 		UUID NOT NULL,
+    chirp_id 		UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE TABLE rechirps (
+    user_id 		UUID NOT NULL,
+    chirp_id 		UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE rechirps;
+DROP TABLE chirp_likes;
+DROP TABLE follows;
+
+ALTER TABLE chirps
+DROP COLUMN reply_to_id;
+
+ALTER TABLE users
+DROP COLUMN handle;
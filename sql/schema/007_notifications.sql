@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    updated_at 		TIMESTAMP NOT NULL,
+    user_id 		UUID NOT NULL,
+    kind 		TEXT NOT NULL,
+    chirp_id 		UUID,
+    group_key 		TEXT NOT NULL,
+    read_at 		TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+-- only one unread notification per group, once read a new group starts
+CREATE UNIQUE INDEX notifications_unread_group_idx
+ON notifications (user_id, group_key)
+WHERE read_at IS NULL;
+
+CREATE INDEX notifications_user_updated_idx
+ON notifications (user_id, updated_at DESC, id DESC);
+
+CREATE TABLE notification_actors (
+    notification_id 	UUID NOT NULL,
+    actor_id 		UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (notification_id, actor_id),
+    FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE notification_actors;
+DROP TABLE notifications;
@@ -0,0 +1,54 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id 		UUID NOT NULL,
+    blocked_id 		UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE conversations (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    updated_at 		TIMESTAMP NOT NULL,
+    created_by 		UUID NOT NULL,
+    is_group 		BOOLEAN NOT NULL,
+
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE conversation_members (
+    conversation_id 	UUID NOT NULL,
+    user_id 		UUID NOT NULL,
+    joined_at 		TIMESTAMP NOT NULL,
+    last_read_at 	TIMESTAMP,
+
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_members_user_idx
+ON conversation_members (user_id);
+
+CREATE TABLE direct_messages (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    conversation_id 	UUID NOT NULL,
+    sender_id 		UUID NOT NULL,
+    body 		TEXT NOT NULL,
+
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX direct_messages_conversation_idx
+ON direct_messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
+DROP TABLE blocks;
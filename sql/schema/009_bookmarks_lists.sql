@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+    user_id 		UUID NOT NULL,
+    chirp_id 		UUID NOT NULL,
+    created_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_user_created_idx
+ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+
+CREATE TABLE lists (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    updated_at 		TIMESTAMP NOT NULL,
+    owner_id 		UUID NOT NULL,
+    name 		TEXT NOT NULL,
+
+    UNIQUE (owner_id, name),
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE list_members (
+    list_id 		UUID NOT NULL,
+    user_id 		UUID NOT NULL,
+    added_at 		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (list_id, user_id),
+    FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE bookmarks;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    updated_at 		TIMESTAMP NOT NULL,
+    user_id 		UUID NOT NULL,
+    body 		TEXT NOT NULL,
+    reply_to_id 	UUID,
+    publish_at 		TIMESTAMP,
+    status 		TEXT NOT NULL,
+    chirp_id 		UUID,
+    last_error 		TEXT,
+
+    CHECK (status IN ('draft', 'scheduled', 'published', 'cancelled', 'failed')),
+    CHECK (status <> 'scheduled' OR publish_at IS NOT NULL),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (reply_to_id) REFERENCES chirps(id) ON DELETE SET NULL,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE SET NULL
+);
+
+CREATE INDEX scheduled_chirps_due_idx
+ON scheduled_chirps (publish_at)
+WHERE status = 'scheduled';
+
+CREATE INDEX scheduled_chirps_user_idx
+ON scheduled_chirps (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
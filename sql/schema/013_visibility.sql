@@ -0,0 +1,28 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+CHECK (visibility IN ('public', 'followers', 'unlisted', 'private'));
+
+ALTER TABLE scheduled_chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+CHECK (visibility IN ('public', 'followers', 'unlisted', 'private'));
+
+ALTER TABLE users
+ADD COLUMN is_protected BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE follows
+ADD COLUMN status TEXT NOT NULL DEFAULT 'accepted'
+CHECK (status IN ('pending', 'accepted'));
+
+-- +goose Down
+ALTER TABLE follows
+DROP COLUMN status;
+
+ALTER TABLE users
+DROP COLUMN is_protected;
+
+ALTER TABLE scheduled_chirps
+DROP COLUMN visibility;
+
+ALTER TABLE chirps
+DROP COLUMN visibility;
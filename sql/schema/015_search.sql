@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN language TEXT NOT NULL DEFAULT 'english'
+CHECK (language IN ('simple', 'english', 'spanish', 'french', 'german', 'italian', 'portuguese', 'dutch'));
+
+ALTER TABLE users
+ADD COLUMN display_name TEXT;
+
+-- IMMUTABLE so they can back expression indexes, the text to regconfig cast is safe
+-- because language is limited to the built in configurations above
+-- +goose StatementBegin
+CREATE FUNCTION chirp_search_vector(language TEXT, body TEXT) RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT to_tsvector(language::regconfig, body)
+$$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION user_search_vector(handle TEXT, display_name TEXT) RETURNS tsvector
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$
+    SELECT to_tsvector('simple', coalesce(handle, '') || ' ' || coalesce(display_name, ''))
+$$;
+-- +goose StatementEnd
+
+CREATE INDEX chirps_search_idx ON chirps USING GIN (chirp_search_vector(language, body));
+CREATE INDEX users_search_idx ON users USING GIN (user_search_vector(handle, display_name));
+
+-- +goose Down
+DROP INDEX users_search_idx;
+DROP INDEX chirps_search_idx;
+
+DROP FUNCTION user_search_vector(TEXT, TEXT);
+DROP FUNCTION chirp_search_vector(TEXT, TEXT);
+
+ALTER TABLE users
+DROP COLUMN display_name;
+
+ALTER TABLE chirps
+DROP COLUMN language;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE INDEX chirps_created_at_idx ON chirps (created_at, id)
+WHERE deleted_at IS NULL;
+
+CREATE INDEX chirps_user_created_at_idx ON chirps (user_id, created_at, id)
+WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_created_at_idx;
+DROP INDEX chirps_created_at_idx;
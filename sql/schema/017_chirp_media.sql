@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN media_urls TEXT[] NOT NULL DEFAULT '{}'
+CHECK (cardinality(media_urls) <= 4);
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN media_urls;
@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE moderation_words (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    word TEXT NOT NULL UNIQUE,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'flag', 'reject'))
+);
+
+CREATE INDEX moderation_words_page_idx ON moderation_words (created_at, id);
+
+INSERT INTO moderation_words (id, created_at, updated_at, word, action)
+VALUES
+    (gen_random_uuid(), now(), now(), 'kerfuffle', 'mask'),
+    (gen_random_uuid(), now(), now(), 'sharbert', 'mask'),
+    (gen_random_uuid(), now(), now(), 'fornax', 'mask');
+
+CREATE TABLE chirp_flags (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    words TEXT[] NOT NULL
+);
+
+CREATE INDEX chirp_flags_page_idx ON chirp_flags (created_at, chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_words;
+
+ALTER TABLE users
+DROP COLUMN role;
@@ -0,0 +1,52 @@
+-- +goose Up
+-- suspended_until stays NULL for a permanent suspension
+ALTER TABLE users
+ADD COLUMN suspended_at TIMESTAMP,
+ADD COLUMN suspended_until TIMESTAMP,
+ADD COLUMN suspension_reason TEXT;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    target_user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- no foreign key so the report outlives a removed chirp, chirp_body keeps what was reported
+    target_chirp_id UUID,
+    chirp_body TEXT,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'self_harm', 'impersonation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+    claimed_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    resolution TEXT CHECK (resolution IN ('remove_chirp', 'warn_user', 'suspend_user')),
+    closed_at TIMESTAMP
+);
+
+-- one pending report per reporter and target
+CREATE UNIQUE INDEX reports_pending_idx
+ON reports (reporter_id, target_user_id, COALESCE(target_chirp_id, '00000000-0000-0000-0000-000000000000'))
+WHERE status IN ('open', 'claimed');
+
+CREATE INDEX reports_queue_idx ON reports (status, created_at, id);
+
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    target_user_id UUID,
+    target_chirp_id UUID,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_page_idx ON moderation_actions (created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN suspension_reason,
+DROP COLUMN suspended_until,
+DROP COLUMN suspended_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN shadow_banned_at TIMESTAMP;
+
+CREATE INDEX users_shadow_banned_idx ON users (id) WHERE shadow_banned_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_shadow_banned_idx;
+
+ALTER TABLE users
+DROP COLUMN shadow_banned_at;
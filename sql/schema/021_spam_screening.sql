@@ -0,0 +1,28 @@
+-- +goose Up
+-- held chirps are only shown to their author until a moderator releases them
+ALTER TABLE chirps
+ADD COLUMN held_for_review BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX chirps_held_idx ON chirps (created_at, id) WHERE held_for_review;
+
+CREATE TABLE spam_decisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- NULL for rejected chirps, which are never written
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    simhash BIGINT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    verdict TEXT NOT NULL CHECK (verdict IN ('accept', 'hold', 'reject')),
+    hits JSONB NOT NULL
+);
+
+CREATE INDEX spam_decisions_page_idx ON spam_decisions (created_at DESC, id DESC);
+CREATE INDEX spam_decisions_user_idx ON spam_decisions (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE spam_decisions;
+
+ALTER TABLE chirps
+DROP COLUMN held_for_review;
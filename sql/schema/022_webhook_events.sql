@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    -- the provider's own id for the event, retried deliveries share it
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'ignored', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    processed_at TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_page_idx ON webhook_events (received_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    tier TEXT NOT NULL DEFAULT 'red',
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    -- when the perks stop unless another event arrives, the period end plus any grace period
+    expires_at TIMESTAMP NOT NULL,
+    canceled_at TIMESTAMP
+);
+
+CREATE INDEX subscriptions_expiry_idx ON subscriptions (expires_at) WHERE status <> 'expired';
+
+-- existing upgrades never recorded a period, they get a fresh one from today
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, tier, status, current_period_start, current_period_end, expires_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', NOW(), NOW() + INTERVAL '30 days', NOW() + INTERVAL '33 days'
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users
+SET is_chirpy_red = true
+WHERE id IN (SELECT user_id FROM subscriptions WHERE status <> 'expired' AND expires_at > NOW());
+
+DROP TABLE subscriptions;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    -- a running job whose lock has run out belonged to a worker that died, it is due again
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    finished_at TIMESTAMP,
+    -- recurring jobs carry kind@slot so every instance enqueuing the same slot makes one job
+    unique_key TEXT UNIQUE
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX jobs_locked_idx ON jobs (locked_until) WHERE status = 'running';
+CREATE INDEX jobs_page_idx ON jobs (created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- the changes GET /api/stream/chirps pushes, kept for a day so reconnecting clients can resume
+CREATE TABLE chirp_stream_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    event_type TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    unlisted BOOLEAN NOT NULL,
+    payload JSONB NOT NULL
+);
+
+CREATE INDEX chirp_stream_events_created_idx ON chirp_stream_events (created_at);
+
+-- +goose Down
+DROP TABLE chirp_stream_events;
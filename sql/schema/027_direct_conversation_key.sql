@@ -0,0 +1,26 @@
+-- +goose Up
+-- one-to-one conversations are keyed by their ordered member pair, so two people can only ever
+-- have one even when both start it at the same time
+ALTER TABLE conversations ADD COLUMN direct_key TEXT;
+
+-- pairs that already have more than one keep the oldest as their conversation
+UPDATE conversations
+SET direct_key = pairs.direct_key
+FROM (
+    SELECT DISTINCT ON (direct_key) id, direct_key
+    FROM (
+        SELECT c.id, c.created_at, min(m.user_id::text) || ':' || max(m.user_id::text) AS direct_key
+        FROM conversations c
+        JOIN conversation_members m ON m.conversation_id = c.id
+        WHERE NOT c.is_group
+        GROUP BY c.id, c.created_at
+    ) keyed
+    ORDER BY direct_key, created_at, id
+) pairs
+WHERE conversations.id = pairs.id;
+
+CREATE UNIQUE INDEX conversations_direct_key_idx ON conversations (direct_key);
+
+-- +goose Down
+DROP INDEX conversations_direct_key_idx;
+ALTER TABLE conversations DROP COLUMN direct_key;
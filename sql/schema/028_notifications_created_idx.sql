@@ -0,0 +1,10 @@
+-- +goose Up
+-- notifications page by created_at, which a new actor joining the group doesn't move
+DROP INDEX notifications_user_updated_idx;
+CREATE INDEX notifications_user_created_idx
+ON notifications (user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX notifications_user_created_idx;
+CREATE INDEX notifications_user_updated_idx
+ON notifications (user_id, updated_at DESC, id DESC);
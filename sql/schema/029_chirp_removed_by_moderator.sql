@@ -0,0 +1,9 @@
+-- +goose Up
+-- a chirp a moderator removed sits in the author's trash like any other deleted chirp
+-- until it's purged, but the author can't see or restore it
+ALTER TABLE chirps
+ADD COLUMN removed_by_moderator BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN removed_by_moderator;
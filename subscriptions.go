@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/outbound"
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+// how often subscriptions past their grace period are marked expired
+const subscriptionExpiryInterval = 10 * time.Minute
+
+func subscriptionFromDB(sub database.Subscription) subscription.Subscription {
+	return subscription.Subscription{
+		Tier:        subscription.Tier(sub.Tier),
+		Status:      subscription.Status(sub.Status),
+		PeriodStart: sub.CurrentPeriodStart,
+		PeriodEnd:   sub.CurrentPeriodEnd,
+		ExpiresAt:   sub.ExpiresAt,
+		CanceledAt:  sub.CanceledAt.Time,
+	}
+}
+
+// userSubscription is userID's subscription, the zero Subscription carries no perks
+// so users who never subscribed don't need a row
+func userSubscription(ctx context.Context, db *database.Queries, userID uuid.UUID) (subscription.Subscription, error) {
+	sub, err := db.GetSubscriptionByUser(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription.Subscription{}, nil
+	}
+	if err != nil {
+		return subscription.Subscription{}, err
+	}
+	return subscriptionFromDB(sub), nil
+}
+
+// subscriptionTiers is where entitlements learn which tier a user's perks come from
+type subscriptionTiers struct {
+	db *database.Queries
+}
+
+func (s subscriptionTiers) Tier(ctx context.Context, userID uuid.UUID) (subscription.Tier, error) {
+	sub, err := userSubscription(ctx, s.db, userID)
+	if err != nil {
+		return "", err
+	}
+	return sub.ActiveTier(time.Now()), nil
+}
+
+// applySubscriptionEvent moves userID's subscription on by a billing event, periodEnd is zero
+// unless the provider said when the paid period ends
+func applySubscriptionEvent(ctx context.Context, qtx *database.Queries, userID uuid.UUID, event subscription.Event, periodEnd time.Time) error {
+
+	if _, err := qtx.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errWebhookUnknownUser
+		}
+		return err
+	}
+
+	var current *subscription.Subscription
+	id := uuid.New()
+	existing, err := qtx.LockSubscriptionByUser(ctx, userID)
+	switch {
+	case err == nil:
+		sub := subscriptionFromDB(existing)
+		current = &sub
+		id = existing.ID
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	now := time.Now()
+	next, err := subscription.DefaultPolicy.Apply(current, event, periodEnd, now)
+	if err != nil {
+		return err
+	}
+
+	saved, err := qtx.SaveSubscription(ctx, database.SaveSubscriptionParams{
+		ID:                 id,
+		CreatedAt:          now,
+		UserID:             userID,
+		Tier:               string(next.Tier),
+		Status:             string(next.Status),
+		CurrentPeriodStart: next.PeriodStart,
+		CurrentPeriodEnd:   next.PeriodEnd,
+		ExpiresAt:          next.ExpiresAt,
+		CanceledAt:         sql.NullTime{Time: next.CanceledAt, Valid: !next.CanceledAt.IsZero()},
+	})
+	if err != nil {
+		return err
+	}
+
+	if event != subscription.EventUpgraded {
+		return nil
+	}
+	return enqueueWebhookEvent(ctx, qtx, outbound.EventUserUpgraded, userID, struct {
+		UserID           uuid.UUID `json:"user_id"`
+		Tier             string    `json:"tier"`
+		CurrentPeriodEnd time.Time `json:"current_period_end"`
+	}{
+		UserID:           userID,
+		Tier:             saved.Tier,
+		CurrentPeriodEnd: saved.CurrentPeriodEnd,
+	})
+}
+
+// expireSubscriptions is the expire_subscriptions job, it marks subscriptions expired once their
+// period and grace period have run out. Perks already stop at expires_at, this keeps the status honest.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context, _ struct{}) error {
+
+	expired, err := cfg.db.ExpireSubscriptions(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		log.Printf("expired %d subscriptions", len(expired))
+	}
+	return nil
+}
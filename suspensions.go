@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+// moderation actions taken on an account directly rather than through a report
+const (
+	moderationActionLiftSuspension = "lift_suspension"
+	moderationActionShadowBan      = "shadow_ban"
+	moderationActionLiftShadowBan  = "lift_shadow_ban"
+)
+
+// suspendedError is why a suspended user is turned away, its message is safe to show them
+type suspendedError struct {
+	until  sql.NullTime
+	reason string
+}
+
+func (e *suspendedError) Error() string {
+	message := "account suspended permanently"
+	if e.until.Valid {
+		message = "account suspended until " + e.until.Time.UTC().Format(time.RFC3339)
+	}
+	if e.reason != "" {
+		message += " for " + e.reason
+	}
+	return message
+}
+
+// activeSuspension returns a *suspendedError while a suspension is in force and nil once it has run out
+func activeSuspension(suspendedAt, suspendedUntil sql.NullTime, reason sql.NullString, now time.Time) error {
+	if !suspendedAt.Valid {
+		return nil
+	}
+	if suspendedUntil.Valid && !suspendedUntil.Time.After(now) {
+		return nil
+	}
+	return &suspendedError{until: suspendedUntil, reason: reason.String}
+}
+
+// validateAccessToken checks a JWT and that the account behind it is still allowed in,
+// so suspending someone cuts off the access tokens they already hold
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	suspension, err := cfg.db.GetUserSuspension(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := activeSuspension(suspension.SuspendedAt, suspension.SuspendedUntil, suspension.SuspensionReason, time.Now()); err != nil {
+		return uuid.Nil, err
+	}
+
+	return userID, nil
+}
+
+// suspendUser suspends an account and signs it out everywhere
+func suspendUser(ctx context.Context, qtx *database.Queries, userID uuid.UUID, until sql.NullTime, reason string) error {
+
+	now := time.Now()
+	if until.Valid {
+		until.Time = dbTime(until.Time)
+	}
+	_, err := qtx.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedAt:    now,
+		SuspendedUntil: until,
+		Reason:         reason,
+		ID:             userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return qtx.RevokeUserRefreshTokens(ctx, database.RevokeUserRefreshTokensParams{
+		UserID:    userID,
+		UpdatedAt: now,
+	})
+}
+
+// moderateAccount runs change against the account in the url and logs it, in one transaction.
+// Moderators can only act on accounts below their own role.
+func (cfg *apiConfig) moderateAccount(w http.ResponseWriter, req *http.Request, action, note string,
+	change func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error) {
+
+	moderatorID, err := cfg.authenticatedUserID(req)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	moderator, err := cfg.db.GetUserByID(req.Context(), moderatorID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid authorization", err)
+		return
+	}
+	target, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	}
+	if !canModerate(moderator.Role, target.Role) {
+		respondWithError(w, http.StatusForbidden, "you cannot moderate this account", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update account", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := change(req.Context(), qtx, userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update account", err)
+		return
+	}
+
+	err = logModerationAction(req.Context(), qtx, database.LogModerationActionParams{
+		ModeratorID:  uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Note:         note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update account", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not update account", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, req *http.Request) {
+
+	type parameters struct {
+		// leaving SuspendedUntil out suspends permanently
+		SuspendedUntil *time.Time `json:"suspended_until"`
+		Reason         string     `json:"reason"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	until := sql.NullTime{}
+	if params.SuspendedUntil != nil {
+		if !params.SuspendedUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+			return
+		}
+		until = sql.NullTime{Time: *params.SuspendedUntil, Valid: true}
+	}
+
+	note := "permanent"
+	if until.Valid {
+		note = fmt.Sprintf("until %s", until.Time.UTC().Format(time.RFC3339))
+	}
+	if params.Reason != "" {
+		note += ": " + params.Reason
+	}
+
+	cfg.moderateAccount(w, req, resolutionSuspendUser, note, func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		return suspendUser(ctx, qtx, userID, until, params.Reason)
+	})
+}
+
+func (cfg *apiConfig) handlerLiftSuspension(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateAccount(w, req, moderationActionLiftSuspension, "", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		_, err := qtx.LiftSuspension(ctx, database.LiftSuspensionParams{
+			UpdatedAt: time.Now(),
+			ID:        userID,
+		})
+		return err
+	})
+}
+
+// handlerShadowBanUser lets a user keep posting while hiding their chirps from everyone else
+func (cfg *apiConfig) handlerShadowBanUser(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateAccount(w, req, moderationActionShadowBan, "", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		now := time.Now()
+		_, err := qtx.SetShadowBan(ctx, database.SetShadowBanParams{
+			ShadowBannedAt: sql.NullTime{Time: now, Valid: true},
+			UpdatedAt:      now,
+			ID:             userID,
+		})
+		return err
+	})
+}
+
+func (cfg *apiConfig) handlerLiftShadowBan(w http.ResponseWriter, req *http.Request) {
+	cfg.moderateAccount(w, req, moderationActionLiftShadowBan, "", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		_, err := qtx.SetShadowBan(ctx, database.SetShadowBanParams{
+			UpdatedAt: time.Now(),
+			ID:        userID,
+		})
+		return err
+	})
+}
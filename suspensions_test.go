@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestActiveSuspension(t *testing.T) {
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	at := func(t time.Time) sql.NullTime {
+		return sql.NullTime{Time: t, Valid: true}
+	}
+	suspendedAt := at(now.Add(-time.Hour))
+
+	cases := []struct {
+		name           string
+		suspendedAt    sql.NullTime
+		suspendedUntil sql.NullTime
+		reason         string
+		want           string
+	}{
+		{name: "never suspended"},
+		{name: "permanent", suspendedAt: suspendedAt, reason: "spam", want: "account suspended permanently for spam"},
+		{name: "active", suspendedAt: suspendedAt, suspendedUntil: at(now.Add(time.Hour)), want: "account suspended until 2026-10-18T13:00:00Z"},
+		{name: "ends now", suspendedAt: suspendedAt, suspendedUntil: at(now)},
+		{name: "expired", suspendedAt: suspendedAt, suspendedUntil: at(now.Add(-time.Minute))},
+	}
+
+	for _, c := range cases {
+		err := activeSuspension(c.suspendedAt, c.suspendedUntil, sql.NullString{String: c.reason, Valid: c.reason != ""}, now)
+		if c.want == "" {
+			if err != nil {
+				t.Errorf("%s: expected no suspension, got %v", c.name, err)
+			}
+			continue
+		}
+		suspended := &suspendedError{}
+		if !errors.As(err, &suspended) || err.Error() != c.want {
+			t.Errorf("%s: expected %q, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestValidateAccessTokenChecksSuspension(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+
+	active, permanent, expired, allowed := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	fake.answer("GetUserSuspension", suspensionsOf(map[uuid.UUID]database.GetUserSuspensionRow{
+		active: {
+			SuspendedAt:    sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+			SuspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+		},
+		permanent: {
+			SuspendedAt: sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+		},
+		expired: {
+			SuspendedAt:    sql.NullTime{Time: now.Add(-2 * time.Hour), Valid: true},
+			SuspendedUntil: sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+		},
+	}))
+
+	for userID, wantSuspended := range map[uuid.UUID]bool{active: true, permanent: true, expired: false, allowed: false} {
+		token, err := auth.MakeJWT(userID, testSecret, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := cfg.validateAccessToken(context.Background(), token)
+		suspended := &suspendedError{}
+		if errors.As(err, &suspended) != wantSuspended {
+			t.Errorf("expected suspended %v, got %v", wantSuspended, err)
+		}
+		if !wantSuspended && (err != nil || got != userID) {
+			t.Errorf("expected %s to be let in, got %s %v", userID, got, err)
+		}
+	}
+
+	calls := len(fake.calledWith("GetUserSuspension"))
+	if _, err := cfg.validateAccessToken(context.Background(), "not a jwt"); err == nil {
+		t.Error("expected a malformed token to be rejected")
+	}
+	if len(fake.calledWith("GetUserSuspension")) != calls {
+		t.Error("expected a malformed token to be rejected before the database is asked")
+	}
+}
+
+func TestSuspendUserRevokesRefreshTokens(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	target := testUser()
+	fake.answer("SuspendUser", usersByIDAt(3, target))
+
+	until := sql.NullTime{Time: time.Now().Add(time.Hour).UTC(), Valid: true}
+	if err := suspendUser(context.Background(), cfg.db, target.ID, until, "spam"); err != nil {
+		t.Fatal(err)
+	}
+
+	suspended := fake.calledWith("SuspendUser")
+	if len(suspended) != 1 || suspended[0][3] != target.ID.String() {
+		t.Fatalf("expected %s to be suspended, got %v", target.ID, suspended)
+	}
+	if storedUntil, ok := suspended[0][1].(time.Time); !ok || !storedUntil.Equal(until.Time) || storedUntil.Location() != time.Local {
+		t.Errorf("expected suspended_until %v in the server's zone, got %v", until.Time, suspended[0][1])
+	}
+
+	revoked := fake.calledWith("RevokeUserRefreshTokens")
+	if len(revoked) != 1 || revoked[0][0] != target.ID.String() {
+		t.Errorf("expected %s's refresh tokens to be revoked, got %v", target.ID, revoked)
+	}
+}
+
+func TestModerateAccountRankRule(t *testing.T) {
+
+	moderator, admin, user := testUser(), testUser(), testUser()
+	moderator.Role, admin.Role, user.Role = roleModerator, roleAdmin, roleUser
+
+	cases := []struct {
+		name   string
+		target database.User
+		want   int
+	}{
+		{"moderator suspends a user", user, http.StatusNoContent},
+		{"moderator suspends an admin", admin, http.StatusForbidden},
+		{"moderator suspends themselves", moderator, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, cfg := newTestConfig(t)
+			fake.answer("GetUserByID", usersByID(moderator, admin, user))
+			fake.answer("SuspendUser", usersByIDAt(3, moderator, admin, user))
+
+			req := authorizedRequest(t, moderator.ID, http.MethodPost, "/admin/users/"+c.target.ID.String()+"/suspension", `{"reason":"spam"}`)
+			req.SetPathValue("userID", c.target.ID.String())
+			w := httptest.NewRecorder()
+			cfg.handlerSuspendUser(w, req)
+
+			if w.Code != c.want {
+				t.Fatalf("expected %d, got %d %s", c.want, w.Code, w.Body)
+			}
+			applied := len(fake.calledWith("SuspendUser")) > 0
+			if applied != (c.want == http.StatusNoContent) {
+				t.Errorf("expected the suspension applied %v, got %v", c.want == http.StatusNoContent, applied)
+			}
+		})
+	}
+}
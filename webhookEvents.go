@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+const webhookProviderPolka = "polka"
+
+// statuses a webhook event moves through
+const (
+	webhookStatusPending   = "pending"
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+var (
+	// errWebhookEventClaimed means the event is already done or another delivery of it is in progress
+	errWebhookEventClaimed = errors.New("webhook event is already being processed")
+	errWebhookUnknownUser  = errors.New("webhook event names a user that does not exist")
+)
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	formatted := WebhookEvent{
+		ID:         event.ID,
+		ReceivedAt: event.ReceivedAt,
+		UpdatedAt:  event.UpdatedAt,
+		Provider:   event.Provider,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		LastError:  event.LastError.String,
+	}
+	if event.ProcessedAt.Valid {
+		formatted.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return formatted
+}
+
+// polkaEvent is the body Polka delivers
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+		// CurrentPeriodEnd is when the period an upgrade or renewal paid for ends, if Polka says
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// polkaEventID is the id retried deliveries of an event share. Events that don't carry
+// one are identified by their body, which is the same on every retry.
+func polkaEventID(event polkaEvent, body []byte) string {
+	if event.ID != "" {
+		return event.ID
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// applyPolkaEvent makes the changes an event asks for and returns the status it ends in
+func applyPolkaEvent(ctx context.Context, qtx *database.Queries, event database.WebhookEvent) (string, error) {
+
+	params := polkaEvent{}
+	if err := json.Unmarshal(event.Payload, &params); err != nil {
+		return "", err
+	}
+
+	if !subscription.Event(params.Event).Valid() {
+		return webhookStatusIgnored, nil
+	}
+
+	periodEnd := time.Time{}
+	if params.Data.CurrentPeriodEnd != nil {
+		periodEnd = dbTime(*params.Data.CurrentPeriodEnd)
+	}
+
+	err := applySubscriptionEvent(ctx, qtx, params.Data.UserID, subscription.Event(params.Event), periodEnd)
+	if err != nil {
+		return "", err
+	}
+	return webhookStatusProcessed, nil
+}
+
+// processWebhookEvent applies a pending or failed event in one transaction. If applying it fails
+// nothing it did is kept and the event is marked failed with the error, ready to be replayed.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, eventID uuid.UUID) error {
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	event, err := qtx.ClaimWebhookEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWebhookEventClaimed
+	}
+	if err != nil {
+		return err
+	}
+
+	status, applyErr := applyPolkaEvent(ctx, qtx, event)
+	if applyErr != nil {
+		tx.Rollback()
+		err := cfg.db.FailWebhookEvent(ctx, database.FailWebhookEventParams{
+			LastError: sql.NullString{String: applyErr.Error(), Valid: true},
+			UpdatedAt: time.Now(),
+			ID:        event.ID,
+		})
+		return errors.Join(applyErr, err)
+	}
+
+	err = qtx.CompleteWebhookEvent(ctx, database.CompleteWebhookEventParams{
+		Status:      status,
+		ProcessedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		ID:          event.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receivePolkaEvent records a verified delivery and processes it unless an earlier delivery of the same event already has
+func (cfg *apiConfig) receivePolkaEvent(w http.ResponseWriter, req *http.Request, body []byte) {
+
+	params := polkaEvent{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "could not decode json", err)
+		return
+	}
+
+	event, err := cfg.db.RecordWebhookEvent(req.Context(), database.RecordWebhookEventParams{
+		ID:         uuid.New(),
+		ReceivedAt: time.Now(),
+		Provider:   webhookProviderPolka,
+		EventID:    polkaEventID(params, body),
+		EventType:  params.Event,
+		Payload:    body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not record webhook", err)
+		return
+	}
+
+	err = cfg.processWebhookEvent(req.Context(), event.ID)
+	switch {
+	case errors.Is(err, errWebhookEventClaimed):
+		// a duplicate, the first delivery handles it
+	case errors.Is(err, errWebhookUnknownUser):
+		respondWithError(w, http.StatusNotFound, "user does not exist", err)
+		return
+	case errors.Is(err, subscription.ErrNoSubscription):
+		respondWithError(w, http.StatusConflict, "user has no subscription", err)
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "could not process webhook", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvents(w http.ResponseWriter, req *http.Request) {
+
+	status := sql.NullString{}
+	switch raw := req.URL.Query().Get("status"); raw {
+	case "":
+	case webhookStatusPending, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed:
+		status = sql.NullString{String: raw, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be pending, processed, ignored or failed", nil)
+		return
+	}
+
+	p, err := parsePage(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	unformatted, err := cfg.db.GetWebhookEvents(req.Context(), database.GetWebhookEventsParams{
+		Status:     status,
+		CursorTime: p.cursor,
+		Backward:   p.backward,
+		CursorID:   p.cursorID,
+		PageSize:   p.size,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not retrieve webhook events", err)
+		return
+	}
+	unformatted = reversePage(p, unformatted)
+
+	events := []WebhookEvent{}
+	for _, event := range unformatted {
+		events = append(events, webhookEventFromDB(event))
+	}
+
+	nextCursor, prevCursor := p.cursors(len(unformatted), func(i int) string {
+		return encodeCursor(unformatted[i].ReceivedAt, unformatted[i].ID)
+	})
+	setPageLinks(w, req, nextCursor, prevCursor)
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvent(w http.ResponseWriter, req *http.Request) {
+
+	eventID, err := uuid.Parse(req.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid event id", err)
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "webhook event does not exist", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
+
+// handlerReplayWebhookEvent processes a failed event again, responding with how it went this time
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, req *http.Request) {
+
+	eventID, err := uuid.Parse(req.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid event id", err)
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "webhook event does not exist", err)
+		return
+	}
+	if event.Status != webhookStatusFailed {
+		respondWithError(w, http.StatusConflict, "only failed webhook events can be replayed", nil)
+		return
+	}
+
+	err = cfg.processWebhookEvent(req.Context(), event.ID)
+	if errors.Is(err, errWebhookEventClaimed) {
+		respondWithError(w, http.StatusConflict, "webhook event is already being processed", err)
+		return
+	}
+	if err != nil {
+		// the event records why it failed again, so the reread below reports it
+		log.Println("replaying webhook event ", eventID, ": ", err)
+	}
+
+	event, err = cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not replay webhook event", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventFromDB(event))
+}
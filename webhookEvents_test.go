@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/subscription"
+	"github.com/google/uuid"
+)
+
+func TestApplyPolkaEventPeriodEnd(t *testing.T) {
+
+	fake, cfg := newTestConfig(t)
+	user := testUser()
+	fake.answer("GetUserByID", usersByID(user))
+	fake.answer("SaveSubscription", func(args []driver.Value) fakeResult {
+		// created_at is written to updated_at too
+		return fakeResult{rows: [][]driver.Value{append(args[:2:2], args[1:]...)}}
+	})
+
+	// Polka sends the period end in its own zone, it is the same instant in the server's
+	periodEnd := time.Date(2026, 11, 18, 17, 0, 0, 0, time.FixedZone("+05:00", 5*60*60))
+	payload, _ := json.Marshal(map[string]any{
+		"id":    "evt_1",
+		"event": string(subscription.EventUpgraded),
+		"data":  map[string]any{"user_id": user.ID, "current_period_end": periodEnd.Format(time.RFC3339)},
+	})
+
+	status, err := applyPolkaEvent(context.Background(), cfg.db, database.WebhookEvent{ID: uuid.New(), Payload: payload})
+	if err != nil || status != webhookStatusProcessed {
+		t.Fatalf("expected the event processed, got %q %v", status, err)
+	}
+
+	saved := fake.calledWith("SaveSubscription")
+	if len(saved) != 1 || saved[0][2] != user.ID.String() {
+		t.Fatalf("expected the user's subscription saved, got %v", saved)
+	}
+	stored, ok := saved[0][6].(time.Time)
+	if !ok || !stored.Equal(periodEnd) || stored.Location() != time.Local {
+		t.Errorf("expected current_period_end %v in the server's zone, got %v", periodEnd, saved[0][6])
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/colfarl/chirpy-server/internal/auth"
+	"github.com/colfarl/chirpy-server/internal/database"
+	"github.com/colfarl/chirpy-server/internal/realtime"
+	"github.com/colfarl/chirpy-server/internal/visibility"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	realtimeNotifyChannel = "realtime"
+	// pg_notify refuses payloads of 8000 bytes or more
+	realtimeMaxNotify = 7999
+
+	// a connection that hasn't authenticated by then is closed
+	wsAuthTimeout       = 10 * time.Second
+	wsHeartbeatInterval = 30 * time.Second
+	// a peer that doesn't answer a ping this fast is gone
+	wsPingTimeout  = 10 * time.Second
+	wsWriteTimeout = 10 * time.Second
+	// reauth_required is sent this long before the token expires
+	wsReauthNotice    = time.Minute
+	wsMaxMessageBytes = 4096
+	// typing indicators are relayed at most this often per conversation
+	wsTypingInterval = 2 * time.Second
+
+	// wsStatusAuthFailed closes a connection that sent a bad token or none in time
+	wsStatusAuthFailed websocket.StatusCode = 4001
+	// wsStatusTokenExpired closes a connection whose token ran out before a new one came
+	wsStatusTokenExpired websocket.StatusCode = 4002
+)
+
+// pgPubSub relays realtime messages between instances with NOTIFY. Every instance, this one
+// included, hands what it hears to its own subscriptions.
+type pgPubSub struct {
+	*realtime.Local
+	db *database.Queries
+}
+
+func (p pgPubSub) Publish(ctx context.Context, msg realtime.Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) > realtimeMaxNotify {
+		return fmt.Errorf("%s message is too large to relay", msg.Type)
+	}
+	return p.db.NotifyRealtime(ctx, string(payload))
+}
+
+// runRealtimeListener delivers realtime messages from every instance to local until ctx is cancelled.
+// Messages are only for whoever is connected, so any sent while the listener is down are lost.
+func (cfg *apiConfig) runRealtimeListener(ctx context.Context, dbURL string, local *realtime.Local) {
+
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println("realtime listener: ", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(realtimeNotifyChannel); err != nil {
+		log.Println("could not listen for realtime messages: ", err)
+		return
+	}
+
+	ticker := time.NewTicker(chirpStreamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go listener.Ping()
+		case notification := <-listener.Notify:
+			if notification == nil {
+				continue
+			}
+			msg := realtime.Message{}
+			if err := json.Unmarshal([]byte(notification.Extra), &msg); err != nil {
+				log.Println("bad realtime message: ", err)
+				continue
+			}
+			local.Deliver(msg)
+		}
+	}
+}
+
+// publishRealtime sends a message to websocket subscribers, it is best effort so failures are only logged
+func (cfg *apiConfig) publishRealtime(ctx context.Context, channel, msgType string, data any) {
+
+	payload, err := json.Marshal(data)
+	if err == nil {
+		err = cfg.realtime.Publish(ctx, realtime.Message{Channel: channel, Type: msgType, Data: payload})
+	}
+	if err != nil {
+		log.Println("could not publish ", msgType, " to websockets: ", err)
+	}
+}
+
+// authenticateSocket checks an access token like any request and reports when it expires
+func (cfg *apiConfig) authenticateSocket(ctx context.Context, token string) (uuid.UUID, time.Time, error) {
+
+	userID, err := cfg.validateAccessToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	expiresAt, err := auth.JWTExpiresAt(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	return userID, expiresAt, nil
+}
+
+// timelineChirp loads a newly posted chirp for userID's timeline, reporting false when it doesn't
+// belong there: the author isn't followed or the chirp isn't visible to them
+func (cfg *apiConfig) timelineChirp(ctx context.Context, userID uuid.UUID, data json.RawMessage) (Chirp, bool) {
+
+	ref := struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{}
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return Chirp{}, false
+	}
+
+	if ref.UserID != userID {
+		followed, err := cfg.db.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+			FollowerID:  userID,
+			FolloweeIds: []uuid.UUID{ref.UserID},
+		})
+		if err != nil || len(followed) == 0 {
+			return Chirp{}, false
+		}
+	}
+
+	chirp, err := cfg.db.GetOneChirp(ctx, ref.ID)
+	if err != nil {
+		return Chirp{}, false
+	}
+	visible, err := cfg.filterVisibleChirps(ctx, userID, []database.Chirp{chirp}, visibility.SurfaceTimeline)
+	if err != nil || len(visible) == 0 {
+		return Chirp{}, false
+	}
+
+	formatted := []Chirp{chirpFromDB(chirp)}
+	if err := cfg.attachPolls(ctx, formatted, userID); err != nil {
+		return Chirp{}, false
+	}
+	return formatted[0], true
+}
+
+// handlerWebSocket upgrades to the websocket API described in the realtime package. A bearer
+// token on the upgrade authenticates it, clients that can't set headers send an auth message
+// within wsAuthTimeout instead.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, req *http.Request) {
+
+	userID, expiresAt := uuid.Nil, time.Time{}
+	if token, err := auth.GetBearerToken(req.Header); err == nil {
+		userID, expiresAt, err = cfg.authenticateSocket(req.Context(), token)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "invalid token", err)
+			return
+		}
+	}
+
+	sub, err := cfg.realtime.Subscribe()
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "server is shutting down", err)
+		return
+	}
+	defer sub.Close()
+
+	// Accept writes its own response when the upgrade fails
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		log.Println("websocket upgrade failed: ", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsMaxMessageBytes)
+
+	session := &wsSession{
+		cfg:        cfg,
+		conn:       conn,
+		sub:        sub,
+		topics:     map[string]string{},
+		lastTyping: map[uuid.UUID]time.Time{},
+	}
+	if userID != uuid.Nil {
+		session.authenticate(userID, expiresAt)
+	} else {
+		session.authDeadline = time.After(wsAuthTimeout)
+	}
+
+	status, reason := session.run(req.Context())
+	conn.Close(status, reason)
+}
+
+// wsSession is one websocket connection, everything but reading happens on the goroutine in run
+type wsSession struct {
+	cfg  *apiConfig
+	conn *websocket.Conn
+	sub  *realtime.Subscription
+
+	userID       uuid.UUID
+	expiresAt    time.Time
+	authDeadline <-chan time.Time
+	reauth       <-chan time.Time
+	expired      <-chan time.Time
+
+	// topics maps each joined channel to the topic the client subscribed to it as
+	topics     map[string]string
+	lastTyping map[uuid.UUID]time.Time
+}
+
+// authenticate starts or extends the session for userID until expiresAt
+func (s *wsSession) authenticate(userID uuid.UUID, expiresAt time.Time) {
+	s.userID = userID
+	s.expiresAt = expiresAt
+	s.authDeadline = nil
+	s.reauth = time.After(time.Until(expiresAt.Add(-wsReauthNotice)))
+	s.expired = time.After(time.Until(expiresAt))
+}
+
+// run serves the connection until it should be closed, returning how
+func (s *wsSession) run(ctx context.Context) (websocket.StatusCode, string) {
+
+	// cancelling a read closes the connection without a close frame, so the reader isn't tied to
+	// ctx and instead ends when the caller closes the connection
+	done := make(chan struct{})
+	defer close(done)
+
+	incoming := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, data, err := s.conn.Read(context.WithoutCancel(ctx))
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case incoming <- data:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if s.userID != uuid.Nil {
+		s.sendAuthenticated(ctx, "")
+	}
+
+	heartbeat := time.NewTicker(wsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return websocket.StatusGoingAway, ""
+		case <-readErr:
+			// the peer closed or vanished, closing again just releases the connection
+			return websocket.StatusNormalClosure, ""
+		case data := <-incoming:
+			if status, reason, done := s.handle(ctx, data); done {
+				return status, reason
+			}
+		case msg, ok := <-s.sub.Messages():
+			if !ok {
+				if errors.Is(s.sub.Err(), realtime.ErrClosed) {
+					return websocket.StatusGoingAway, "server is shutting down"
+				}
+				return websocket.StatusTryAgainLater, "connection fell too far behind"
+			}
+			s.forward(ctx, msg)
+		case <-heartbeat.C:
+			s.send(ctx, realtime.Envelope{Type: realtime.TypeHeartbeat})
+			go func() {
+				pingCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), wsPingTimeout)
+				defer cancel()
+				if err := s.conn.Ping(pingCtx); err != nil {
+					s.conn.CloseNow()
+				}
+			}()
+		case <-s.authDeadline:
+			return wsStatusAuthFailed, "authentication timed out"
+		case <-s.reauth:
+			s.send(ctx, realtime.Envelope{Type: realtime.TypeReauthRequired, Data: s.expiryData()})
+		case <-s.expired:
+			return wsStatusTokenExpired, "token expired"
+		}
+	}
+}
+
+// handle answers one client message, done reports the connection should close
+func (s *wsSession) handle(ctx context.Context, data []byte) (status websocket.StatusCode, reason string, done bool) {
+
+	env := realtime.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		s.sendError(ctx, env, "messages must be JSON envelopes")
+		return 0, "", false
+	}
+
+	if env.Type == realtime.TypePing {
+		s.send(ctx, realtime.Envelope{Type: realtime.TypePong, ID: env.ID})
+		return 0, "", false
+	}
+
+	if env.Type == realtime.TypeAuth {
+		params := struct {
+			Token string `json:"token"`
+		}{}
+		json.Unmarshal(env.Data, &params)
+
+		userID, expiresAt, err := s.cfg.authenticateSocket(ctx, params.Token)
+		if err != nil && s.userID == uuid.Nil {
+			return wsStatusAuthFailed, "invalid token", true
+		}
+		if err != nil {
+			// the session carries on until the token it has expires
+			s.sendError(ctx, env, "invalid token")
+			return 0, "", false
+		}
+		if s.userID != uuid.Nil && userID != s.userID {
+			return wsStatusAuthFailed, "token is for a different user", true
+		}
+		s.authenticate(userID, expiresAt)
+		s.sendAuthenticated(ctx, env.ID)
+		return 0, "", false
+	}
+
+	if s.userID == uuid.Nil {
+		s.sendError(ctx, env, "authenticate first")
+		return 0, "", false
+	}
+
+	switch env.Type {
+	case realtime.TypeSubscribe:
+		s.subscribe(ctx, env)
+	case realtime.TypeUnsubscribe:
+		s.unsubscribe(ctx, env)
+	case realtime.TypeTyping:
+		s.typing(ctx, env)
+	default:
+		s.sendError(ctx, env, "unknown message type")
+	}
+	return 0, "", false
+}
+
+// channelFor is the channel behind a topic, typing topics are only for conversation members
+func (s *wsSession) channelFor(ctx context.Context, topic realtime.Topic) (string, error) {
+
+	switch topic.Name {
+	case realtime.TopicTimeline:
+		return realtime.ChannelChirps, nil
+	case realtime.TopicNotifications:
+		return realtime.NotificationsChannel(s.userID), nil
+	}
+
+	_, err := s.cfg.db.GetConversationForMember(ctx, database.GetConversationForMemberParams{
+		ID:     topic.ConversationID,
+		UserID: s.userID,
+	})
+	if err != nil {
+		return "", errors.New("conversation does not exist")
+	}
+	return realtime.TypingChannel(topic.ConversationID), nil
+}
+
+func (s *wsSession) subscribe(ctx context.Context, env realtime.Envelope) {
+
+	topic, err := realtime.ParseTopic(env.Topic)
+	if err != nil {
+		s.sendError(ctx, env, err.Error())
+		return
+	}
+	channel, err := s.channelFor(ctx, topic)
+	if err != nil {
+		s.sendError(ctx, env, err.Error())
+		return
+	}
+
+	s.sub.Join(channel)
+	s.topics[channel] = env.Topic
+	s.ack(ctx, env)
+}
+
+func (s *wsSession) unsubscribe(ctx context.Context, env realtime.Envelope) {
+
+	for channel, topic := range s.topics {
+		if topic == env.Topic {
+			s.sub.Leave(channel)
+			delete(s.topics, channel)
+		}
+	}
+	s.ack(ctx, env)
+}
+
+// typing tells the rest of a conversation the user is typing
+func (s *wsSession) typing(ctx context.Context, env realtime.Envelope) {
+
+	topic, err := realtime.ParseTopic(env.Topic)
+	if err != nil || topic.ConversationID == uuid.Nil {
+		s.sendError(ctx, env, "typing needs a typing:<conversation id> topic")
+		return
+	}
+
+	// indicators only need to be roughly current, extra ones are dropped
+	if time.Since(s.lastTyping[topic.ConversationID]) < wsTypingInterval {
+		s.ack(ctx, env)
+		return
+	}
+
+	channel, err := s.channelFor(ctx, topic)
+	if err != nil {
+		s.sendError(ctx, env, err.Error())
+		return
+	}
+	s.lastTyping[topic.ConversationID] = time.Now()
+
+	s.cfg.publishRealtime(ctx, channel, realtime.TypeTyping, struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+		UserID         uuid.UUID `json:"user_id"`
+	}{
+		ConversationID: topic.ConversationID,
+		UserID:         s.userID,
+	})
+	s.ack(ctx, env)
+}
+
+// forward sends a published message to the client on the topic it subscribed with
+func (s *wsSession) forward(ctx context.Context, msg realtime.Message) {
+
+	topic, ok := s.topics[msg.Channel]
+	if !ok {
+		return
+	}
+
+	data := msg.Data
+	switch msg.Type {
+	case realtime.TypeChirpCreated:
+		chirp, ok := s.cfg.timelineChirp(ctx, s.userID, msg.Data)
+		if !ok {
+			return
+		}
+		encoded, err := json.Marshal(chirp)
+		if err != nil {
+			return
+		}
+		data = encoded
+	case realtime.TypeTyping:
+		typing := struct {
+			UserID uuid.UUID `json:"user_id"`
+		}{}
+		if json.Unmarshal(msg.Data, &typing) != nil || typing.UserID == s.userID {
+			return
+		}
+	}
+
+	s.send(ctx, realtime.Envelope{Type: msg.Type, Topic: topic, Data: data})
+}
+
+func (s *wsSession) expiryData() json.RawMessage {
+	data, _ := json.Marshal(struct {
+		UserID    uuid.UUID `json:"user_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{
+		UserID:    s.userID,
+		ExpiresAt: s.expiresAt,
+	})
+	return data
+}
+
+func (s *wsSession) sendAuthenticated(ctx context.Context, id string) {
+	s.send(ctx, realtime.Envelope{Type: realtime.TypeAuthenticated, ID: id, Data: s.expiryData()})
+}
+
+// ack confirms a request, only requests with an id get one
+func (s *wsSession) ack(ctx context.Context, env realtime.Envelope) {
+	if env.ID == "" {
+		return
+	}
+	s.send(ctx, realtime.Envelope{Type: realtime.TypeAck, ID: env.ID, Topic: env.Topic})
+}
+
+func (s *wsSession) sendError(ctx context.Context, env realtime.Envelope, msg string) {
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{
+		Error: msg,
+	})
+	s.send(ctx, realtime.Envelope{Type: realtime.TypeError, ID: env.ID, Topic: env.Topic, Data: data})
+}
+
+// send writes one envelope, a connection that can't take it is closed so run returns
+func (s *wsSession) send(ctx context.Context, env realtime.Envelope) {
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	if err := wsjson.Write(ctx, s.conn, env); err != nil {
+		s.conn.CloseNow()
+	}
+}